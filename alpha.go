@@ -0,0 +1,403 @@
+package minecraft
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+var alphaFilename = regexp.MustCompile(`^c\.(-?[0-9a-z]+)\.(-?[0-9a-z]+)\.dat$`)
+
+// AlphaPath is a Path implementation for levels stored in the format used by
+// minecraft Alpha, where each chunk is stored in its own gzipped file, within
+// directories named for the chunk coords, modulo 64, in base 36.
+//
+// Chunks are converted to and from the Anvil format as they are read and
+// written, allowing an Alpha level to be used with a Level. As with McRegion,
+// Alpha chunks are 128 blocks high and only store block IDs up to 255;
+// attempting to save a chunk that does not fit these limits returns
+// ErrNotLegacy.
+//
+// The list of chunks is read from disk by GetRegions, or by the first call to
+// GetChunks, and is then kept up to date by SetChunk and RemoveChunk; chunk
+// files added or removed by other programs are found on the next call to
+// GetRegions.
+type AlphaPath struct {
+	dirname string
+
+	mu      sync.Mutex
+	regions map[[2]int32]map[[2]int32]struct{}
+}
+
+// NewAlphaPath creates a new AlphaPath for the given directory, creating it
+// if it does not exist.
+func NewAlphaPath(dirname string) (*AlphaPath, error) {
+	if err := os.MkdirAll(dirname, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &AlphaPath{dirname: dirname}, nil
+}
+
+func (p *AlphaPath) getChunkPath(x, z int32) string {
+	return filepath.Join(p.dirname, strconv.FormatInt(int64(x&63), 36), strconv.FormatInt(int64(z&63), 36), "c."+strconv.FormatInt(int64(x), 36)+"."+strconv.FormatInt(int64(z), 36)+".dat")
+}
+
+// GetChunk returns the chunk at chunk coords x, z, converted to the Anvil
+// format.
+func (p *AlphaPath) GetChunk(x, z int32) (nbt.Tag, error) {
+	data, err := p.GetAlphaChunk(x, z)
+	if err != nil || data.TagID() == 0 {
+		return data, err
+	}
+
+	return ConvertMcRegionChunk(data)
+}
+
+// GetAlphaChunk returns the unconverted chunk at chunk coords x, z.
+func (p *AlphaPath) GetAlphaChunk(x, z int32) (nbt.Tag, error) {
+	f, err := os.Open(p.getChunkPath(x, z))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+
+		return nbt.Tag{}, err
+	}
+
+	defer f.Close()
+
+	g, err := gzip.NewReader(f)
+	if err != nil {
+		return nbt.Tag{}, err
+	}
+
+	return nbt.Decode(g)
+}
+
+// SetChunk converts the given chunks to the Alpha format and saves them.
+func (p *AlphaPath) SetChunk(data ...nbt.Tag) error {
+	for _, d := range data {
+		x, z, err := chunkCoords(d)
+		if err != nil {
+			return err
+		}
+
+		alpha, err := convertAnvilChunk(x, z, d)
+		if err != nil {
+			return FilePathSetError{x, z, err}
+		}
+
+		name := p.getChunkPath(x, z)
+
+		if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		} else if err = writeFileAtomic(name, func(w io.Writer) error {
+			g := gzip.NewWriter(w)
+
+			if err := nbt.Encode(g, alpha); err != nil {
+				return err
+			}
+
+			return g.Close()
+		}); err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+
+		if p.regions != nil {
+			region := [2]int32{x >> 5, z >> 5}
+
+			if p.regions[region] == nil {
+				p.regions[region] = make(map[[2]int32]struct{})
+			}
+
+			p.regions[region][[2]int32{x, z}] = struct{}{}
+		}
+
+		p.mu.Unlock()
+	}
+
+	return nil
+}
+
+// RemoveChunk deletes the chunk at chunk coords x, z.
+func (p *AlphaPath) RemoveChunk(x, z int32) error {
+	if err := os.Remove(p.getChunkPath(x, z)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	p.mu.Lock()
+
+	if p.regions != nil {
+		region := [2]int32{x >> 5, z >> 5}
+
+		if delete(p.regions[region], [2]int32{x, z}); len(p.regions[region]) == 0 {
+			delete(p.regions, region)
+		}
+	}
+
+	p.mu.Unlock()
+
+	return nil
+}
+
+// ReadLevelDat returns the level data.
+func (p *AlphaPath) ReadLevelDat() (nbt.Tag, error) {
+	return loadLevelDat(os.DirFS(p.dirname))
+}
+
+// WriteLevelDat writes the level data.
+func (p *AlphaPath) WriteLevelDat(data nbt.Tag) error {
+	return writeLevelDat(p.dirname, data)
+}
+
+// chunks returns the coords of all of the chunk files in the level.
+func (p *AlphaPath) chunks() ([][2]int32, error) {
+	var toRet [][2]int32
+
+	dirs, err := os.ReadDir(p.dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		subdirs, err := os.ReadDir(filepath.Join(p.dirname, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subdir := range subdirs {
+			if !subdir.IsDir() {
+				continue
+			}
+
+			files, err := os.ReadDir(filepath.Join(p.dirname, dir.Name(), subdir.Name()))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+
+			for _, file := range files {
+				nums := alphaFilename.FindStringSubmatch(file.Name())
+				if nums == nil || file.IsDir() {
+					continue
+				}
+
+				x, errx := strconv.ParseInt(nums[1], 36, 32)
+				z, errz := strconv.ParseInt(nums[2], 36, 32)
+
+				if errx == nil && errz == nil {
+					toRet = append(toRet, [2]int32{int32(x), int32(z)})
+				}
+			}
+		}
+	}
+
+	return toRet, nil
+}
+
+// Chunks returns an iterator over all chunks that match all of the given
+// filters.
+//
+// The Modified time and Size of each chunk are those of its file.
+func (p *AlphaPath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	coords, err := p.chunks()
+	if err != nil {
+		return &ChunkIterator{err: err}
+	}
+
+	infos := make([]ChunkInfo, len(coords))
+
+	for n, c := range coords {
+		infos[n] = ChunkInfo{X: c[0], Z: c[1], Compression: GZip}
+	}
+
+	regions, grouped := groupChunks(infos)
+
+	return newChunkIterator(ctx, regions, func(x, z int32) ([]ChunkInfo, error) {
+		var chunks []ChunkInfo
+
+		for _, c := range grouped[[2]int32{x, z}] {
+			fi, err := os.Stat(p.getChunkPath(c.X, c.Z))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			c.Modified = fi.ModTime()
+			c.Size = fi.Size()
+			chunks = append(chunks, c)
+		}
+
+		return chunks, nil
+	}, filters)
+}
+
+// readRegions walks the level directory once, grouping all of the chunks by
+// region.
+//
+// The lock must be held when calling this method.
+func (p *AlphaPath) readRegions() error {
+	p.regions = nil
+
+	chunks, err := p.chunks()
+	if err != nil {
+		return err
+	}
+
+	p.regions = make(map[[2]int32]map[[2]int32]struct{})
+
+	for _, c := range chunks {
+		region := [2]int32{c[0] >> 5, c[1] >> 5}
+
+		if p.regions[region] == nil {
+			p.regions[region] = make(map[[2]int32]struct{})
+		}
+
+		p.regions[region][c] = struct{}{}
+	}
+
+	return nil
+}
+
+// GetRegions returns a list of region x,z coords of all regions that contain
+// chunks.
+func (p *AlphaPath) GetRegions() [][2]int32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.readRegions()
+
+	toRet := make([][2]int32, 0, len(p.regions))
+
+	for r := range p.regions {
+		toRet = append(toRet, r)
+	}
+
+	sortCoords(toRet)
+
+	return toRet
+}
+
+// GetChunks returns a list of all chunks within a region with coords x,z.
+func (p *AlphaPath) GetChunks(x, z int32) ([][2]int32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.regions == nil {
+		if err := p.readRegions(); err != nil {
+			return nil, err
+		}
+	}
+
+	var toRet [][2]int32
+
+	for c := range p.regions[[2]int32{x, z}] {
+		toRet = append(toRet, c)
+	}
+
+	sortCoords(toRet)
+
+	return toRet, nil
+}
+
+// convertAnvilChunk converts an Anvil chunk to the single column format used
+// by Alpha and McRegion chunks.
+func convertAnvilChunk(x, z int32, data nbt.Tag) (nbt.Tag, error) {
+	c, err := newChunk(x, z, data.Copy())
+	if err != nil {
+		return nbt.Tag{}, err
+	} else if c.format != legacyBlocks {
+		return nbt.Tag{}, ErrNotLegacy
+	}
+
+	var (
+		blocks     = make(nbt.ByteArray, mcRegionHeight*256)
+		blockData  = make(nbt.ByteArray, mcRegionHeight*128)
+		blockLight = make(nbt.ByteArray, mcRegionHeight*128)
+		skyLight   = make(nbt.ByteArray, mcRegionHeight*128)
+		heightMap  = make(nbt.ByteArray, 256)
+	)
+
+	for sy, s := range c.sections {
+		if s == nil {
+			continue
+		}
+
+		for i := int32(0); i < 4096; i++ {
+			bx, by, bz := i&15, c.minY+int32(sy)<<4|i>>8, i>>4&15
+			b := s.GetBlock(bx, by, bz)
+
+			if b.ID > 255 || (by >= mcRegionHeight && b.ID != 0) {
+				return nbt.Tag{}, ErrNotLegacy
+			} else if by >= mcRegionHeight {
+				continue
+			}
+
+			j := bx<<11 | bz<<7 | by
+			blocks[j] = int8(b.ID)
+
+			setXZYNibble(blockData, j, b.Data)
+			setXZYNibble(blockLight, j, s.GetBlockLight(bx, by, bz))
+			setXZYNibble(skyLight, j, s.GetSkyLight(bx, by, bz))
+		}
+	}
+
+	for bx := int32(0); bx < 16; bx++ {
+		for bz := int32(0); bz < 16; bz++ {
+			h := c.GetHeight(bx, bz)
+			if h > mcRegionHeight {
+				h = mcRegionHeight
+			}
+
+			heightMap[bz<<4|bx] = int8(h)
+		}
+	}
+
+	anvil := c.GetNBT().Data().(nbt.Compound).Get("Level").Data().(nbt.Compound)
+	level := nbt.Compound{
+		nbt.NewTag("xPos", nbt.Int(x)),
+		nbt.NewTag("zPos", nbt.Int(z)),
+		nbt.NewTag("Blocks", blocks),
+		nbt.NewTag("Data", blockData),
+		nbt.NewTag("BlockLight", blockLight),
+		nbt.NewTag("SkyLight", skyLight),
+		nbt.NewTag("HeightMap", heightMap),
+		nbt.NewTag("Entities", nbt.NewEmptyList(nbt.TagCompound)),
+	}
+
+	for _, name := range [...]string{"Entities", "TileEntities", "TileTicks", "LastUpdate", "TerrainPopulated"} {
+		if tag := anvil.Get(name); tag.TagID() != 0 {
+			level.Set(tag)
+		}
+	}
+
+	return nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", level)}), nil
+}
+
+func setXZYNibble(arr nbt.ByteArray, i int32, data byte) {
+	old := byte(arr[i>>1])
+
+	if i&1 == 0 {
+		old = old&240 | data&15
+	} else {
+		old = old&15 | data<<4
+	}
+
+	arr[i>>1] = int8(old)
+}
@@ -0,0 +1,174 @@
+package minecraft
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestAlphaPath(t *testing.T) {
+	dir := t.TempDir()
+
+	p, err := NewAlphaPath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, c := range [...][2]int32{{0, 0}, {-1, 2}} {
+		chunk, err := ConvertMcRegionChunk(mcRegionChunk(c[0], c[1]))
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if err = p.SetChunk(chunk); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err = p.WriteLevelDat(nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Data", nbt.Compound{
+			nbt.NewTag("LevelName", nbt.String("alpha")),
+			nbt.NewTag("SpawnX", nbt.Int(0)),
+			nbt.NewTag("SpawnY", nbt.Int(64)),
+			nbt.NewTag("SpawnZ", nbt.Int(0)),
+		}),
+	})); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, name := range [...]string{
+		filepath.Join("0", "0", "c.0.0.dat"),
+		filepath.Join("1r", "2", "c.-1.2.dat"),
+	} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expecting chunk file %s: %s", name, err)
+		}
+	}
+
+	if raw, err := p.GetAlphaChunk(0, 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !raw.Data().(nbt.Compound).Get("Level").Data().(nbt.Compound).Get("Blocks").Equal(mcRegionChunk(0, 0).Data().(nbt.Compound).Get("Level").Data().(nbt.Compound).Get("Blocks")) {
+		t.Error("alpha block data does not match")
+	}
+
+	if regions := p.GetRegions(); len(regions) != 2 || regions[0] != [2]int32{-1, 0} || regions[1] != [2]int32{0, 0} {
+		t.Errorf("expecting regions -1,0 and 0,0, got %v", regions)
+	}
+
+	testMcRegionLevel(t, p)
+
+	l, err := NewLevel(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = l.SetBlock(70*16+3, 127, -5, Block{ID: 4}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = l.Save(); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = os.Stat(filepath.Join(dir, "6", "1r", "c.1y.-1.dat")); err != nil {
+		t.Errorf("expecting new chunk file: %s", err)
+	}
+
+	if l, err = NewLevel(p); err != nil {
+		t.Fatal(err.Error())
+	} else if b, err := l.GetBlock(70*16+3, 127, -5); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !b.EqualBlock(Block{ID: 4}) {
+		t.Errorf("expecting block 4, got %s", b)
+	}
+
+	for _, b := range [...]struct {
+		y     int32
+		block Block
+	}{
+		{128, Block{ID: 1}},
+		{10, Block{ID: 256}},
+	} {
+		if err = l.SetBlock(0, b.y, 0, b.block); err != nil {
+			t.Fatal(err.Error())
+		} else if err = l.Save(); err == nil {
+			t.Errorf("%d: expecting error saving unsupported block", b.y)
+		} else if ferr, ok := err.(FilePathSetError); !ok || ferr.Err != ErrNotLegacy {
+			t.Errorf("%d: expecting ErrNotLegacy, got %v", b.y, err)
+		}
+
+		if err = l.SetBlock(0, b.y, 0, Block{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err = p.RemoveChunk(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if chunk, err := p.GetChunk(0, 0); err != nil || chunk.TagID() != 0 {
+		t.Errorf("expecting no chunk, got %v", err)
+	}
+}
+
+func TestAlphaPathGetChunks(t *testing.T) {
+	dir := t.TempDir()
+
+	p, err := NewAlphaPath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, c := range [...][2]int32{{0, 0}, {31, 5}, {32, 0}, {-1, 2}} {
+		chunk, err := ConvertMcRegionChunk(mcRegionChunk(c[0], c[1]))
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if err = p.SetChunk(chunk); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if regions := p.GetRegions(); fmt.Sprint(regions) != "[[-1 0] [0 0] [1 0]]" {
+		t.Errorf("expecting regions -1,0, 0,0 and 1,0, got %v", regions)
+	}
+
+	// A chunk file written by another program is not seen until the level is
+	// rescanned by GetRegions.
+	other, err := NewAlphaPath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chunk, err := ConvertMcRegionChunk(mcRegionChunk(1, 1))
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = other.SetChunk(chunk); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if chunk, err = ConvertMcRegionChunk(mcRegionChunk(2, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = p.SetChunk(chunk); err != nil {
+		t.Fatal(err.Error())
+	} else if err = p.RemoveChunk(31, 5); err != nil {
+		t.Fatal(err.Error())
+	} else if err = p.RemoveChunk(-1, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for n, test := range [...]struct {
+		x, z   int32
+		chunks string
+	}{
+		{0, 0, "[[0 0] [2 2]]"},
+		{1, 0, "[[32 0]]"},
+		{-1, 0, "[]"},
+	} {
+		if chunks, err := p.GetChunks(test.x, test.z); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if fmt.Sprint(chunks) != test.chunks {
+			t.Errorf("test %d: expecting chunks %s, got %v", n+1, test.chunks, chunks)
+		}
+	}
+
+	if regions := p.GetRegions(); fmt.Sprint(regions) != "[[0 0] [1 0]]" {
+		t.Errorf("expecting regions 0,0 and 1,0, got %v", regions)
+	} else if chunks, err := p.GetChunks(0, 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if fmt.Sprint(chunks) != "[[0 0] [1 1] [2 2]]" {
+		t.Errorf("expecting chunks [[0 0] [1 1] [2 2]], got %v", chunks)
+	}
+}
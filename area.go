@@ -0,0 +1,61 @@
+package minecraft
+
+// BlockReader is implemented by types that blocks can be read from, such as
+// a Level or a schematic.
+type BlockReader interface {
+	GetBlock(x, y, z int32) (Block, error)
+}
+
+// BlockWriter is implemented by types that blocks can be written to, such as
+// a Level or a schematic.
+type BlockWriter interface {
+	SetBlock(x, y, z int32, block Block) error
+}
+
+// Area is a cuboid of blocks, starting at the block coords X, Y, Z and
+// extending Width blocks along the x axis, Height blocks along the y axis and
+// Length blocks along the z axis.
+type Area struct {
+	X, Y, Z               int32
+	Width, Height, Length int32
+}
+
+// Contains returns whether the given block coords are within the area.
+func (a Area) Contains(x, y, z int32) bool {
+	return x >= a.X && x < a.X+a.Width && y >= a.Y && y < a.Y+a.Height && z >= a.Z && z < a.Z+a.Length
+}
+
+// Volume returns the number of blocks in the area.
+func (a Area) Volume() int64 {
+	if a.Width <= 0 || a.Height <= 0 || a.Length <= 0 {
+		return 0
+	}
+
+	return int64(a.Width) * int64(a.Height) * int64(a.Length)
+}
+
+// CopyArea copies all of the blocks, including their metadata and ticks,
+// within the given area of src to dst, such that the block at the corner of
+// the area is placed at block coords x, y, z.
+//
+// Blocks are copied layer by layer, from the bottom up, so that blocks that
+// rely on the block below them are supported when placed.
+//
+// Unmapped blocks, whose block states have no mapping to an ID and Data, are
+// copied by block state.
+func CopyArea(dst BlockWriter, x, y, z int32, src BlockReader, area Area) error {
+	for j := int32(0); j < area.Height; j++ {
+		for k := int32(0); k < area.Length; k++ {
+			for i := int32(0); i < area.Width; i++ {
+				b, err := src.GetBlock(area.X+i, area.Y+j, area.Z+k)
+				if err != nil {
+					return err
+				} else if err = dst.SetBlock(x+i, y+j, z+k, b); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package minecraft
+
+import (
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestArea(t *testing.T) {
+	a := Area{X: -2, Y: 10, Z: 5, Width: 3, Height: 2, Length: 4}
+
+	if v := a.Volume(); v != 24 {
+		t.Errorf("expecting volume 24, got %d", v)
+	}
+
+	for _, test := range [...]struct {
+		x, y, z  int32
+		contains bool
+	}{
+		{-2, 10, 5, true},
+		{0, 11, 8, true},
+		{1, 11, 8, false},
+		{0, 12, 8, false},
+		{0, 11, 9, false},
+		{-3, 10, 5, false},
+	} {
+		if c := a.Contains(test.x, test.y, test.z); c != test.contains {
+			t.Errorf("%d,%d,%d: expecting %v, got %v", test.x, test.y, test.z, test.contains, c)
+		}
+	}
+
+	src, err := NewLevel(NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dst, err := NewLevel(NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i, pos := range [...][3]int32{{-2, 10, 5}, {0, 11, 8}, {-1, 10, 6}} {
+		if err = src.SetBlock(pos[0], pos[1], pos[2], Block{ID: uint16(i + 1)}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err = CopyArea(dst, 100, 50, -100, src, a); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i, pos := range [...][3]int32{{100, 50, -100}, {102, 51, -97}, {101, 50, -99}} {
+		if b, err := dst.GetBlock(pos[0], pos[1], pos[2]); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if b.ID != uint16(i+1) {
+			t.Errorf("%v: expecting block %d, got %d", pos, i+1, b.ID)
+		}
+	}
+}
+
+func TestCopyAreaUnmapped(t *testing.T) {
+	chunk := palettedChunk(0, 0, 2975, containerBlocks)
+	palette := chunk.Data().(nbt.Compound).Get("sections").Data().(*nbt.ListCompound)
+	states := (*palette)[1].Get("block_states").Data().(nbt.Compound).Get("palette").Data().(*nbt.ListCompound)
+
+	(*states)[2] = append((*states)[2], nbt.NewTag("extra", nbt.Int(7)))
+
+	p := NewMemPath()
+
+	if err := p.SetChunk(chunk); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	src, err := NewLevel(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dst, err := NewLevel(NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dst.levelData.Set(nbt.NewTag("DataVersion", nbt.Int(2975)))
+
+	if err = CopyArea(dst, 10, 0, 10, src, Area{Width: 4, Height: 1, Length: 1}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if b, err := dst.GetBlock(12, 0, 10); err != nil {
+		t.Fatal(err.Error())
+	} else if state, ok := b.Unmapped(); !ok || state.String() != "minecraft:unknown_block" {
+		t.Errorf("expecting unmapped block state to be copied, got %s", b)
+	} else if b, err = dst.GetBlock(11, 0, 10); err != nil {
+		t.Fatal(err.Error())
+	} else if !b.EqualBlock(Block{ID: 1}) {
+		t.Errorf("expecting stone, got %s", b)
+	} else if err = dst.Save(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := dst.path.GetChunk(0, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var found bool
+
+	for _, s := range *data.Data().(nbt.Compound).Get("sections").Data().(*nbt.ListCompound) {
+		if bs, ok := s.Get("block_states").Data().(nbt.Compound); ok {
+			for _, e := range *bs.Get("palette").Data().(*nbt.ListCompound) {
+				if e.Get("Name").Data().(nbt.String) == "minecraft:unknown_block" {
+					found = e.Get("extra").Data() == nbt.Int(7)
+				}
+			}
+		}
+	}
+
+	if !found {
+		t.Error("expecting palette entry of unmapped block state to be copied unchanged")
+	}
+
+	legacy, err := NewLevel(NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = CopyArea(legacy, 10, 0, 10, src, Area{Width: 4, Height: 1, Length: 1}); err != (UnknownBlockState{State: "minecraft:unknown_block"}) {
+		t.Errorf("expecting UnknownBlockState error copying to a legacy level, got %v", err)
+	}
+}
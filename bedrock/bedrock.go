@@ -0,0 +1,307 @@
+// Package bedrock reads worlds saved by the Bedrock edition of minecraft,
+// which are stored in Mojang's variant of LevelDB, and reads and writes its
+// .mcstructure structure files.
+package bedrock // import "vimagination.zapto.org/minecraft/bedrock"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// Dimension identifies one of the dimensions of a world.
+type Dimension int32
+
+// Dimensions.
+const (
+	Overworld Dimension = 0
+	Nether    Dimension = 1
+	End       Dimension = 2
+)
+
+// KeyTag identifies the kind of data stored in a chunk key.
+type KeyTag byte
+
+// Chunk key tags.
+const (
+	KeyData3D        KeyTag = 43
+	KeyVersion       KeyTag = 44
+	KeyData2D        KeyTag = 45
+	KeySubChunk      KeyTag = 47
+	KeyBlockEntity   KeyTag = 49
+	KeyEntity        KeyTag = 50
+	KeyPendingTicks  KeyTag = 51
+	KeyLegacyVersion KeyTag = 118
+)
+
+// ChunkPos is the position of a chunk within a world.
+type ChunkPos struct {
+	X, Z      int32
+	Dimension Dimension
+}
+
+// World is a read-only Bedrock world.
+type World struct {
+	dir string
+	db  *db
+}
+
+// Open opens the world in the given directory, which should contain the
+// level.dat file and the db directory.
+//
+// The world should not be modified, such as by the game, while it is open.
+func Open(dir string) (*World, error) {
+	d, err := openDB(filepath.Join(dir, "db"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &World{dir: dir, db: d}, nil
+}
+
+// Close closes the database files.
+func (w *World) Close() error {
+	return w.db.close()
+}
+
+// ReadLevelDat returns the level data, decoded from the little-endian
+// level.dat file.
+func (w *World) ReadLevelDat() (nbt.Tag, error) {
+	f, err := os.Open(filepath.Join(w.dir, "level.dat"))
+	if err != nil {
+		return nbt.Tag{}, err
+	}
+
+	defer f.Close()
+
+	var header [8]byte
+
+	if _, err = io.ReadFull(f, header[:]); err != nil {
+		return nbt.Tag{}, err
+	}
+
+	r := io.LimitReader(f, int64(binary.LittleEndian.Uint32(header[4:])))
+
+	return nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: r}).Decode()
+}
+
+// Get returns the raw value stored under the given database key, or nil if
+// there is no such key.
+func (w *World) Get(key []byte) ([]byte, error) {
+	return w.db.get(key)
+}
+
+// Each calls fn, in key order, with each key in the database and its value.
+//
+// The key and value must not be modified or retained after fn returns.
+func (w *World) Each(fn func(key, value []byte) error) error {
+	return w.db.each(fn)
+}
+
+func chunkKey(pos ChunkPos, tag KeyTag, extra ...byte) []byte {
+	key := make([]byte, 12, 14+len(extra))
+
+	binary.LittleEndian.PutUint32(key, uint32(pos.X))
+	binary.LittleEndian.PutUint32(key[4:], uint32(pos.Z))
+
+	if pos.Dimension == Overworld {
+		key = key[:8]
+	} else {
+		binary.LittleEndian.PutUint32(key[8:], uint32(pos.Dimension))
+	}
+
+	key = append(key, byte(tag))
+
+	return append(key, extra...)
+}
+
+// Chunks returns the positions of all chunks in the world, sorted by
+// dimension, z and then x.
+func (w *World) Chunks() ([]ChunkPos, error) {
+	var chunks []ChunkPos
+
+	if err := w.db.each(func(key, _ []byte) error {
+		var pos ChunkPos
+
+		switch len(key) {
+		case 13:
+			pos.Dimension = Dimension(binary.LittleEndian.Uint32(key[8:]))
+		case 9:
+		default:
+			return nil
+		}
+
+		if tag := KeyTag(key[len(key)-1]); tag != KeyVersion && tag != KeyLegacyVersion {
+			return nil
+		}
+
+		pos.X = int32(binary.LittleEndian.Uint32(key))
+		pos.Z = int32(binary.LittleEndian.Uint32(key[4:]))
+		chunks = append(chunks, pos)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i], chunks[j]
+
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		} else if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+
+		return a.X < b.X
+	})
+
+	return chunks, nil
+}
+
+// Raw returns the raw data stored for a chunk under the given tag, or nil if
+// there is none.
+func (w *World) Raw(pos ChunkPos, tag KeyTag) ([]byte, error) {
+	return w.db.get(chunkKey(pos, tag))
+}
+
+// SubChunk returns the decoded sub-chunk at the given vertical index, or nil
+// if it does not exist.
+func (w *World) SubChunk(pos ChunkPos, y int8) (*SubChunk, error) {
+	data, err := w.db.get(chunkKey(pos, KeySubChunk, byte(y)))
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return decodeSubChunk(data, y)
+}
+
+// HeightMap is the height of the highest block in each column of a chunk,
+// indexed by z<<4|x.
+type HeightMap [256]int16
+
+// HeightMap returns the height map of the chunk, from either its Data3D or
+// Data2D record. The returned bool is false if the chunk has neither.
+func (w *World) HeightMap(pos ChunkPos) (HeightMap, bool, error) {
+	var h HeightMap
+
+	data, err := w.db.get(chunkKey(pos, KeyData3D))
+	if err == nil && data == nil {
+		data, err = w.db.get(chunkKey(pos, KeyData2D))
+	}
+
+	if err != nil || data == nil {
+		return h, false, err
+	} else if len(data) < 512 {
+		return h, false, ErrCorrupt
+	}
+
+	for i := range h {
+		h[i] = int16(binary.LittleEndian.Uint16(data[i<<1:]))
+	}
+
+	return h, true, nil
+}
+
+// Biomes returns the raw biome data of the chunk, which follows the height
+// map in its Data3D or Data2D record. The returned bool is true when the data
+// came from a Data3D record, which holds paletted, per sub-chunk, biomes
+// rather than the 256 biome ids of Data2D.
+func (w *World) Biomes(pos ChunkPos) ([]byte, bool, error) {
+	data, err := w.db.get(chunkKey(pos, KeyData3D))
+	if err != nil {
+		return nil, false, err
+	} else if data != nil {
+		if len(data) < 512 {
+			return nil, false, ErrCorrupt
+		}
+
+		return data[512:], true, nil
+	}
+
+	if data, err = w.db.get(chunkKey(pos, KeyData2D)); err != nil || data == nil {
+		return nil, false, err
+	} else if len(data) < 768 {
+		return nil, false, ErrCorrupt
+	}
+
+	return data[512:768], false, nil
+}
+
+// BlockEntities returns the block entities stored in the chunk.
+func (w *World) BlockEntities(pos ChunkPos) ([]nbt.Tag, error) {
+	data, err := w.db.get(chunkKey(pos, KeyBlockEntity))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTags(data)
+}
+
+// Entities returns the entities in the chunk.
+//
+// Entities are read from both the legacy per-chunk record and the newer
+// per-entity "actorprefix" records listed in the chunk's "digp" record.
+func (w *World) Entities(pos ChunkPos) ([]nbt.Tag, error) {
+	data, err := w.db.get(chunkKey(pos, KeyEntity))
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := decodeTags(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := chunkKey(pos, 0)
+
+	ids, err := w.db.get(append([]byte("digp"), key[:len(key)-1]...))
+	if err != nil {
+		return nil, err
+	} else if len(ids)%8 != 0 {
+		return nil, ErrCorrupt
+	}
+
+	for ; len(ids) > 0; ids = ids[8:] {
+		data, err := w.db.get(append([]byte("actorprefix"), ids[:8]...))
+		if err != nil {
+			return nil, err
+		} else if data == nil {
+			continue
+		}
+
+		tags, err := decodeTags(data)
+		if err != nil {
+			return nil, err
+		}
+
+		entities = append(entities, tags...)
+	}
+
+	return entities, nil
+}
+
+// decodeTags decodes a sequence of little-endian NBT tags.
+func decodeTags(data []byte) ([]nbt.Tag, error) {
+	var tags []nbt.Tag
+
+	r := bytes.NewReader(data)
+	d := nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: r})
+
+	for r.Len() > 0 {
+		t, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, t)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,182 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func encodeTags(t *testing.T, tags ...nbt.Tag) string {
+	var buf bytes.Buffer
+
+	e := nbt.NewEncoderEndian(&byteio.LittleEndianWriter{Writer: &buf})
+
+	for _, tag := range tags {
+		if err := e.Encode(tag); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	return buf.String()
+}
+
+func blockState(name string) nbt.Tag {
+	return nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("name", nbt.String(name)),
+		nbt.NewTag("states", nbt.Compound{}),
+		nbt.NewTag("version", nbt.Int(17959425)),
+	})
+}
+
+func TestWorld(t *testing.T) {
+	dir := t.TempDir()
+
+	levelDat := nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("LevelName", nbt.String("bedrock")),
+		nbt.NewTag("StorageVersion", nbt.Int(10)),
+	})
+
+	ld := encodeTags(t, levelDat)
+	header := appendUint32(appendUint32(nil, 10), uint32(len(ld)))
+
+	if err := os.WriteFile(filepath.Join(dir, "level.dat"), append(header, ld...), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	overworld := ChunkPos{X: -1, Z: 2}
+	nether := ChunkPos{X: 3, Z: -4, Dimension: Nether}
+
+	// A version 9 sub-chunk, with one bit per block, where the blocks
+	// alternate between stone and air along the y axis.
+	subChunk := []byte{9, 1, 0xfe, 1 << 1}
+
+	for i := 0; i < 128; i++ {
+		subChunk = appendUint32(subChunk, 0xaaaaaaaa)
+	}
+
+	subChunk = append(appendUint32(subChunk, 2), encodeTags(t, blockState("minecraft:air"), blockState("minecraft:stone"))...)
+
+	data3D := make([]byte, 512, 520)
+
+	for i := 0; i < 256; i++ {
+		binary.LittleEndian.PutUint16(data3D[i<<1:], uint16(64+i%16))
+	}
+
+	data3D = append(data3D, 1, 2, 3)
+
+	chest := nbt.NewTag("", nbt.Compound{nbt.NewTag("id", nbt.String("Chest"))})
+	cow := nbt.NewTag("", nbt.Compound{nbt.NewTag("identifier", nbt.String("minecraft:cow"))})
+	pig := nbt.NewTag("", nbt.Compound{nbt.NewTag("identifier", nbt.String("minecraft:pig"))})
+	sheep := nbt.NewTag("", nbt.Compound{nbt.NewTag("identifier", nbt.String("minecraft:sheep"))})
+
+	actorID := string(appendUint64(nil, 0x1234))
+	netherKey := chunkKey(nether, 0)
+
+	writeTestDB(t, filepath.Join(dir, "db"), []testTable{
+		{number: 5, compression: compressionZlibRaw, entries: []keyValue{
+			{key: internalKey(string(chunkKey(overworld, KeyData3D)), 1, keyTypeValue), value: data3D},
+			{key: internalKey(string(chunkKey(overworld, KeyVersion)), 2, keyTypeValue), value: []byte{40}},
+			{key: internalKey(string(chunkKey(overworld, KeySubChunk, 0xfe)), 3, keyTypeValue), value: subChunk},
+			{key: internalKey(string(chunkKey(overworld, KeyBlockEntity)), 4, keyTypeValue), value: []byte(encodeTags(t, chest))},
+			{key: internalKey(string(chunkKey(overworld, KeyEntity)), 5, keyTypeValue), value: []byte(encodeTags(t, cow, pig))},
+		}},
+	}, 6,
+		encodeBatch(10,
+			batchOp{key: string(chunkKey(nether, KeyLegacyVersion)), value: "\x07"},
+			batchOp{key: "digp" + string(netherKey[:len(netherKey)-1]), value: actorID},
+			batchOp{key: "actorprefix" + actorID, value: encodeTags(t, sheep)},
+			batchOp{key: "~local_player", value: "\x00"},
+		),
+	)
+
+	w, err := Open(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer w.Close()
+
+	if l, err := w.ReadLevelDat(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !l.Equal(levelDat) {
+		t.Error("level.dat does not match")
+	}
+
+	if chunks, err := w.Chunks(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if len(chunks) != 2 || chunks[0] != overworld || chunks[1] != nether {
+		t.Errorf("expecting chunks %v and %v, got %v", overworld, nether, chunks)
+	}
+
+	if s, err := w.SubChunk(overworld, -2); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if s == nil {
+		t.Error("expecting sub-chunk")
+	} else if s.Y != -2 || len(s.Layers) != 1 || len(s.Layers[0].Palette) != 2 {
+		t.Errorf("unexpected sub-chunk: y = %d, %d layers", s.Y, len(s.Layers))
+	} else {
+		for y := 0; y < 16; y++ {
+			expected := "minecraft:air"
+			if y&1 == 1 {
+				expected = "minecraft:stone"
+			}
+
+			if name := s.Block(7, y, 9).Data().(nbt.Compound).Get("name").Data(); name != nbt.String(expected) {
+				t.Errorf("y = %d: expecting %s, got %v", y, expected, name)
+			}
+		}
+	}
+
+	if s, err := w.SubChunk(overworld, 0); err != nil || s != nil {
+		t.Errorf("expecting no sub-chunk, got %v, %v", s, err)
+	}
+
+	if h, ok, err := w.HeightMap(overworld); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !ok {
+		t.Error("expecting height map")
+	} else if h[0] != 64 || h[255] != 79 {
+		t.Errorf("unexpected heights: %d, %d", h[0], h[255])
+	}
+
+	if b, is3D, err := w.Biomes(overworld); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !is3D || !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Errorf("unexpected biome data: %v, %v", b, is3D)
+	}
+
+	if _, ok, err := w.HeightMap(nether); err != nil || ok {
+		t.Errorf("expecting no height map, got %v, %v", ok, err)
+	}
+
+	if be, err := w.BlockEntities(overworld); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if len(be) != 1 || !be[0].Equal(chest) {
+		t.Errorf("unexpected block entities: %v", be)
+	}
+
+	for _, test := range [...]struct {
+		pos      ChunkPos
+		entities []nbt.Tag
+	}{
+		{overworld, []nbt.Tag{cow, pig}},
+		{nether, []nbt.Tag{sheep}},
+	} {
+		if e, err := w.Entities(test.pos); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if len(e) != len(test.entities) {
+			t.Errorf("%v: expecting %d entities, got %d", test.pos, len(test.entities), len(e))
+		} else {
+			for n := range e {
+				if !e[n].Equal(test.entities[n]) {
+					t.Errorf("%v: entity %d does not match", test.pos, n)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package bedrock
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Errors.
+var (
+	// ErrCorrupt is returned when the database contains malformed data.
+	ErrCorrupt = errors.New("corrupt database")
+
+	// ErrBadChecksum is returned when a table block fails its checksum.
+	ErrBadChecksum = errors.New("block checksum mismatch")
+
+	// ErrBadManifest is returned when the CURRENT file does not name a valid
+	// manifest.
+	ErrBadManifest = errors.New("invalid manifest")
+
+	// ErrUnsupportedComparator is returned when the database was created
+	// with a key ordering other than the default bytewise comparator.
+	ErrUnsupportedComparator = errors.New("unsupported key comparator")
+
+	// ErrRuntimeIDs is returned when a sub-chunk stores its palette as
+	// network runtime IDs, which are not used for saved worlds.
+	ErrRuntimeIDs = errors.New("sub-chunk uses runtime ids")
+
+	// ErrBadBitsPerBlock is returned when a sub-chunk block storage has an
+	// invalid number of bits per block.
+	ErrBadBitsPerBlock = errors.New("invalid bits per block")
+
+	// ErrInvalidSize is returned when a structure has a negative size, or
+	// holds more blocks than can be indexed.
+	ErrInvalidSize = errors.New("invalid structure size")
+)
+
+// UnsupportedCompression is an error returned when a table block uses an
+// unknown compression type.
+type UnsupportedCompression struct {
+	Type byte
+}
+
+func (u UnsupportedCompression) Error() string {
+	return "unsupported block compression type: " + strconv.FormatUint(uint64(u.Type), 10)
+}
+
+// UnsupportedSubChunkVersion is an error returned when a sub-chunk uses a
+// format that cannot be decoded.
+type UnsupportedSubChunkVersion struct {
+	Version byte
+}
+
+func (u UnsupportedSubChunkVersion) Error() string {
+	return "unsupported sub-chunk version: " + strconv.FormatUint(uint64(u.Version), 10)
+}
@@ -0,0 +1,565 @@
+package bedrock
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	logBlockSize  = 32768
+	logHeaderSize = 7
+
+	recordFull   = 1
+	recordFirst  = 2
+	recordMiddle = 3
+	recordLast   = 4
+
+	bytewiseComparator = "leveldb.BytewiseComparator"
+)
+
+// readLogRecords splits the contents of a log file into its records. A
+// damaged or incomplete record, as left by an interrupted write, ends the
+// log.
+func readLogRecords(data []byte) [][]byte {
+	var (
+		records  [][]byte
+		record   []byte
+		inRecord bool
+	)
+
+	for start := 0; start < len(data); start += logBlockSize {
+		end := start + logBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		for b := data[start:end]; len(b) >= logHeaderSize; {
+			length := int(binary.LittleEndian.Uint16(b[4:]))
+			typ := b[6]
+
+			if typ == 0 && length == 0 {
+				// Preallocated, unwritten, space.
+				break
+			} else if logHeaderSize+length > len(b) || unmaskCRC(binary.LittleEndian.Uint32(b)) != crc32.Checksum(b[6:logHeaderSize+length], crcTable) {
+				return records
+			}
+
+			fragment := b[logHeaderSize : logHeaderSize+length]
+			b = b[logHeaderSize+length:]
+
+			switch typ {
+			case recordFull:
+				records = append(records, fragment)
+				inRecord = false
+			case recordFirst:
+				record = append([]byte(nil), fragment...)
+				inRecord = true
+			case recordMiddle, recordLast:
+				if !inRecord {
+					return records
+				}
+
+				record = append(record, fragment...)
+
+				if typ == recordLast {
+					records = append(records, record)
+					inRecord = false
+				}
+			default:
+				return records
+			}
+		}
+	}
+
+	return records
+}
+
+// recordReader reads the varint encoded fields of manifest and log records.
+type recordReader struct {
+	data []byte
+	err  error
+}
+
+func (r *recordReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrCorrupt
+
+		return 0
+	}
+
+	r.data = r.data[n:]
+
+	return v
+}
+
+func (r *recordReader) byte() byte {
+	if r.err != nil {
+		return 0
+	} else if len(r.data) == 0 {
+		r.err = ErrCorrupt
+
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+
+	return b
+}
+
+func (r *recordReader) bytes() []byte {
+	length := r.uvarint()
+	if r.err != nil {
+		return nil
+	} else if length > uint64(len(r.data)) {
+		r.err = ErrCorrupt
+
+		return nil
+	}
+
+	b := r.data[:length]
+	r.data = r.data[length:]
+
+	return b
+}
+
+func (r *recordReader) internalKey() []byte {
+	key := r.bytes()
+	if r.err == nil && len(key) < 8 {
+		r.err = ErrCorrupt
+	}
+
+	return key
+}
+
+// tableMeta describes a table file listed in the manifest.
+type tableMeta struct {
+	number            uint64
+	smallest, largest []byte
+	t                 *table
+}
+
+func (t *tableMeta) contains(key []byte) bool {
+	return bytes.Compare(key, t.smallest[:len(t.smallest)-8]) >= 0 && bytes.Compare(key, t.largest[:len(t.largest)-8]) <= 0
+}
+
+// version is the set of live files, built by replaying the manifest.
+type version struct {
+	logNumber, prevLogNumber uint64
+	files                    map[uint64]*tableMeta
+}
+
+func (v *version) apply(edit []byte) error {
+	r := recordReader{data: edit}
+
+	for len(r.data) > 0 && r.err == nil {
+		switch r.uvarint() {
+		case 1:
+			if c := string(r.bytes()); r.err == nil && c != bytewiseComparator {
+				return ErrUnsupportedComparator
+			}
+		case 2:
+			v.logNumber = r.uvarint()
+		case 3, 4:
+			r.uvarint()
+		case 5:
+			r.uvarint()
+			r.internalKey()
+		case 6:
+			r.uvarint()
+			delete(v.files, r.uvarint())
+		case 7:
+			r.uvarint()
+
+			t := &tableMeta{number: r.uvarint()}
+
+			r.uvarint()
+
+			t.smallest = r.internalKey()
+			t.largest = r.internalKey()
+
+			if r.err == nil {
+				v.files[t.number] = t
+			}
+		case 9:
+			v.prevLogNumber = r.uvarint()
+		default:
+			return ErrBadManifest
+		}
+	}
+
+	return r.err
+}
+
+type memEntry struct {
+	seq     uint64
+	value   []byte
+	deleted bool
+}
+
+// db is a read-only view of a LevelDB database.
+//
+// The database is read as it was when opened; it should not be modified
+// while open.
+type db struct {
+	mem    map[string]memEntry
+	tables []*tableMeta
+}
+
+func openDB(dir string) (*db, error) {
+	current, err := os.ReadFile(filepath.Join(dir, "CURRENT"))
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(string(current), "\n")
+	if !strings.HasPrefix(name, "MANIFEST-") || strings.ContainsAny(name, "/\\") {
+		return nil, ErrBadManifest
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	v := version{files: make(map[uint64]*tableMeta)}
+
+	for _, record := range readLogRecords(manifest) {
+		if err = v.apply(record); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &db{mem: make(map[string]memEntry)}
+
+	for _, t := range v.files {
+		d.tables = append(d.tables, t)
+	}
+
+	sort.Slice(d.tables, func(i, j int) bool {
+		return d.tables[i].number > d.tables[j].number
+	})
+
+	for _, t := range d.tables {
+		if t.t, err = openTable(tableName(dir, t.number)); err != nil {
+			d.close()
+
+			return nil, err
+		}
+	}
+
+	if err = d.readLogs(dir, &v); err != nil {
+		d.close()
+
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func tableName(dir string, number uint64) string {
+	num := strconv.FormatUint(number, 10)
+	if len(num) < 6 {
+		num = strings.Repeat("0", 6-len(num)) + num
+	}
+
+	name := filepath.Join(dir, num+".ldb")
+
+	if _, err := os.Stat(name); err != nil {
+		return filepath.Join(dir, num+".sst")
+	}
+
+	return name
+}
+
+// readLogs replays the writes, in any current log files, that have not yet
+// been written to a table.
+func (d *db) readLogs(dir string, v *version) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type logFile struct {
+		number uint64
+		name   string
+	}
+
+	var logs []logFile
+
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+
+		number, err := strconv.ParseUint(strings.TrimSuffix(name, ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if number >= v.logNumber || (v.prevLogNumber != 0 && number == v.prevLogNumber) {
+			logs = append(logs, logFile{number, name})
+		}
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].number < logs[j].number
+	})
+
+	for _, l := range logs {
+		data, err := os.ReadFile(filepath.Join(dir, l.name))
+		if err != nil {
+			return err
+		}
+
+		for _, batch := range readLogRecords(data) {
+			if err = d.applyBatch(batch); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *db) applyBatch(batch []byte) error {
+	if len(batch) < 12 {
+		return ErrCorrupt
+	}
+
+	seq := binary.LittleEndian.Uint64(batch)
+	count := binary.LittleEndian.Uint32(batch[8:])
+	r := recordReader{data: batch[12:]}
+
+	for ; count > 0; count-- {
+		var e memEntry
+
+		typ := r.byte()
+		key := r.bytes()
+
+		switch typ {
+		case keyTypeValue:
+			e.value = r.bytes()
+		case keyTypeDeletion:
+			e.deleted = true
+		default:
+			return ErrCorrupt
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		e.seq = seq
+		seq++
+
+		if old, ok := d.mem[string(key)]; !ok || old.seq <= e.seq {
+			d.mem[string(key)] = e
+		}
+	}
+
+	return nil
+}
+
+// get returns the value for the given key, or nil if the key does not exist.
+//
+// The value is a copy, as values read from a table share the table's cached
+// block.
+func (d *db) get(key []byte) ([]byte, error) {
+	best, found := d.mem[string(key)]
+	ikey := lookupKey(key)
+
+	for _, t := range d.tables {
+		if !t.contains(key) {
+			continue
+		}
+
+		it := t.t.iterator()
+
+		if !it.seek(ikey) {
+			if it.err != nil {
+				return nil, it.err
+			}
+
+			continue
+		}
+
+		e := it.entry()
+
+		if !bytes.Equal(e.key[:len(e.key)-8], key) {
+			continue
+		}
+
+		trailer := binary.LittleEndian.Uint64(e.key[len(e.key)-8:])
+
+		if !found || trailer>>8 > best.seq {
+			best = memEntry{seq: trailer >> 8, value: e.value, deleted: trailer&0xff == keyTypeDeletion}
+			found = true
+		}
+	}
+
+	if !found || best.deleted {
+		return nil, nil
+	}
+
+	value := make([]byte, len(best.value))
+
+	copy(value, best.value)
+
+	return value, nil
+}
+
+type iterator interface {
+	next() bool
+	entry() keyValue
+	error() error
+}
+
+func (i *tableIterator) error() error {
+	return i.err
+}
+
+type sliceIterator struct {
+	entries []keyValue
+	pos     int
+}
+
+func (s *sliceIterator) next() bool {
+	s.pos++
+
+	return s.pos < len(s.entries)
+}
+
+func (s *sliceIterator) entry() keyValue {
+	return s.entries[s.pos]
+}
+
+func (sliceIterator) error() error {
+	return nil
+}
+
+func (d *db) memIterator() *sliceIterator {
+	entries := make([]keyValue, 0, len(d.mem))
+
+	for key, e := range d.mem {
+		ikey := make([]byte, len(key)+8)
+		trailer := e.seq << 8
+
+		if !e.deleted {
+			trailer |= keyTypeValue
+		}
+
+		copy(ikey, key)
+		binary.LittleEndian.PutUint64(ikey[len(key):], trailer)
+
+		entries = append(entries, keyValue{key: ikey, value: e.value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return compareInternal(entries[i].key, entries[j].key) < 0
+	})
+
+	return &sliceIterator{entries: entries, pos: -1}
+}
+
+type iteratorHeap []iterator
+
+func (h iteratorHeap) Len() int {
+	return len(h)
+}
+
+func (h iteratorHeap) Less(i, j int) bool {
+	return compareInternal(h[i].entry().key, h[j].entry().key) < 0
+}
+
+func (h iteratorHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *iteratorHeap) Push(x interface{}) {
+	*h = append(*h, x.(iterator))
+}
+
+func (h *iteratorHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// each calls fn, in key order, with every key in the database and its value.
+func (d *db) each(fn func(key, value []byte) error) error {
+	h := make(iteratorHeap, 0, len(d.tables)+1)
+	its := []iterator{d.memIterator()}
+
+	for _, t := range d.tables {
+		its = append(its, t.t.iterator())
+	}
+
+	for _, it := range its {
+		if it.next() {
+			h = append(h, it)
+		} else if err := it.error(); err != nil {
+			return err
+		}
+	}
+
+	heap.Init(&h)
+
+	var last []byte
+
+	for len(h) > 0 {
+		it := h[0]
+		e := it.entry()
+		key := e.key[:len(e.key)-8]
+
+		// Only the first, and so newest, version of each key is used.
+		if last == nil || !bytes.Equal(key, last) {
+			last = key
+
+			if e.key[len(key)] != keyTypeDeletion {
+				if err := fn(key, e.value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if it.next() {
+			heap.Fix(&h, 0)
+		} else if err := it.error(); err != nil {
+			return err
+		} else {
+			heap.Pop(&h)
+		}
+	}
+
+	return nil
+}
+
+func (d *db) close() error {
+	var err error
+
+	for _, t := range d.tables {
+		if t.t != nil {
+			if cerr := t.t.close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+
+	return err
+}
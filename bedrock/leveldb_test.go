@@ -0,0 +1,573 @@
+package bedrock
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func maskCRC(c uint32) uint32 {
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+
+	binary.LittleEndian.PutUint32(b[:], v)
+
+	return append(buf, b[:]...)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+
+	binary.LittleEndian.PutUint64(b[:], v)
+
+	return append(buf, b[:]...)
+}
+
+func internalKey(key string, seq uint64, typ byte) []byte {
+	ikey := make([]byte, len(key)+8)
+
+	copy(ikey, key)
+	binary.LittleEndian.PutUint64(ikey[len(key):], seq<<8|uint64(typ))
+
+	return ikey
+}
+
+func putBytes(buf []byte, b []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(b)))
+
+	return append(buf, b...)
+}
+
+// encodeLog splits the records into fragments, as written to a log file.
+func encodeLog(records ...[]byte) []byte {
+	var log []byte
+
+	for _, record := range records {
+		first := true
+
+		for {
+			left := logBlockSize - len(log)%logBlockSize
+			if left < logHeaderSize {
+				log = append(log, make([]byte, left)...)
+				left = logBlockSize
+			}
+
+			fragment := record
+			if len(fragment) > left-logHeaderSize {
+				fragment = fragment[:left-logHeaderSize]
+			}
+
+			record = record[len(fragment):]
+
+			typ := byte(recordMiddle)
+
+			switch {
+			case first && len(record) == 0:
+				typ = recordFull
+			case first:
+				typ = recordFirst
+			case len(record) == 0:
+				typ = recordLast
+			}
+
+			first = false
+
+			header := make([]byte, logHeaderSize)
+
+			binary.LittleEndian.PutUint16(header[4:], uint16(len(fragment)))
+			header[6] = typ
+			binary.LittleEndian.PutUint32(header, maskCRC(crc32.Checksum(append([]byte{typ}, fragment...), crcTable)))
+
+			log = append(append(log, header...), fragment...)
+
+			if len(record) == 0 {
+				break
+			}
+		}
+	}
+
+	return log
+}
+
+type batchOp struct {
+	key, value string
+	deleted    bool
+}
+
+func encodeBatch(seq uint64, ops ...batchOp) []byte {
+	batch := make([]byte, 12)
+
+	binary.LittleEndian.PutUint64(batch, seq)
+	binary.LittleEndian.PutUint32(batch[8:], uint32(len(ops)))
+
+	for _, op := range ops {
+		if op.deleted {
+			batch = putBytes(append(batch, keyTypeDeletion), []byte(op.key))
+		} else {
+			batch = putBytes(putBytes(append(batch, keyTypeValue), []byte(op.key)), []byte(op.value))
+		}
+	}
+
+	return batch
+}
+
+func encodeBlock(entries []keyValue) []byte {
+	var (
+		block    []byte
+		restarts []uint32
+		last     []byte
+	)
+
+	for n, e := range entries {
+		shared := 0
+
+		if n%4 == 0 {
+			restarts = append(restarts, uint32(len(block)))
+		} else {
+			for shared < len(last) && shared < len(e.key) && last[shared] == e.key[shared] {
+				shared++
+			}
+		}
+
+		block = appendUvarint(block, uint64(shared))
+		block = appendUvarint(block, uint64(len(e.key)-shared))
+		block = appendUvarint(block, uint64(len(e.value)))
+		block = append(append(block, e.key[shared:]...), e.value...)
+		last = e.key
+	}
+
+	if len(restarts) == 0 {
+		restarts = append(restarts, 0)
+	}
+
+	for _, r := range restarts {
+		block = appendUint32(block, r)
+	}
+
+	return appendUint32(block, uint32(len(restarts)))
+}
+
+func compressBlock(t *testing.T, compression byte, data []byte) []byte {
+	var buf bytes.Buffer
+
+	switch compression {
+	case compressionNone:
+		return data
+	case compressionSnappy:
+		// Literal only snappy encoding.
+		out := appendUvarint(nil, uint64(len(data)))
+
+		for len(data) > 0 {
+			n := len(data)
+			if n > 60 {
+				n = 60
+			}
+
+			out = append(append(out, byte(n-1)<<2), data[:n]...)
+			data = data[n:]
+		}
+
+		return out
+	case compressionZlib:
+		w := zlib.NewWriter(&buf)
+
+		w.Write(data)
+		w.Close()
+	case compressionZlibRaw:
+		w, _ := flate.NewWriter(&buf, flate.BestCompression)
+
+		w.Write(data)
+		w.Close()
+	default:
+		t.Fatalf("unknown compression: %d", compression)
+	}
+
+	return buf.Bytes()
+}
+
+// encodeTable creates a table file, with the given compression, containing
+// the internal key entries, which must be sorted.
+func encodeTable(t *testing.T, entries []keyValue, compression byte) []byte {
+	var (
+		file  []byte
+		index []keyValue
+	)
+
+	writeBlock := func(data []byte, compression byte) []byte {
+		data = append(compressBlock(t, compression, data), compression)
+		handle := appendUvarint(appendUvarint(nil, uint64(len(file))), uint64(len(data)-1))
+		file = appendUint32(append(file, data...), maskCRC(crc32.Checksum(data, crcTable)))
+
+		return handle
+	}
+
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > 3 {
+			n = 3
+		}
+
+		handle := writeBlock(encodeBlock(entries[:n]), compression)
+		index = append(index, keyValue{key: entries[n-1].key, value: handle})
+		entries = entries[n:]
+	}
+
+	footer := writeBlock(encodeBlock(nil), compressionNone)
+	footer = append(footer, writeBlock(encodeBlock(index), compressionNone)...)
+	footer = append(footer, make([]byte, 40-len(footer))...)
+
+	return appendUint64(append(file, footer...), tableMagic)
+}
+
+type testTable struct {
+	number      uint64
+	compression byte
+	entries     []keyValue
+}
+
+func padNumber(n uint64) string {
+	num := strconv.FormatUint(n, 10)
+
+	return strings.Repeat("0", 6-len(num)) + num
+}
+
+// writeTestDB writes a database with the given tables and a log file,
+// numbered logNumber, containing the batches.
+func writeTestDB(t *testing.T, dir string, tables []testTable, logNumber uint64, batches ...[]byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	edit := putBytes([]byte{1}, []byte(bytewiseComparator))
+	edit = appendUvarint(append(edit, 2), logNumber)
+	edit = appendUvarint(append(edit, 3), logNumber+1)
+
+	for _, tbl := range tables {
+		if err := os.WriteFile(filepath.Join(dir, padNumber(tbl.number)+".ldb"), encodeTable(t, tbl.entries, tbl.compression), 0o644); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		edit = append(edit, 7, 0)
+		edit = appendUvarint(edit, tbl.number)
+		edit = appendUvarint(edit, 0)
+		edit = putBytes(edit, tbl.entries[0].key)
+		edit = putBytes(edit, tbl.entries[len(tbl.entries)-1].key)
+	}
+
+	// A table that has been compacted away.
+	removed := append(appendUvarint([]byte{7, 1}, 1), 0)
+	removed = putBytes(putBytes(removed, internalKey("a", 1, keyTypeValue)), internalKey("z", 1, keyTypeValue))
+	removed = appendUvarint(append(removed, 6, 1), 1)
+
+	if err := os.WriteFile(filepath.Join(dir, "MANIFEST-000002"), encodeLog(removed, edit), 0o644); err != nil {
+		t.Fatal(err.Error())
+	} else if err = os.WriteFile(filepath.Join(dir, "CURRENT"), []byte("MANIFEST-000002\n"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	} else if err = os.WriteFile(filepath.Join(dir, padNumber(logNumber)+".log"), encodeLog(batches...), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestSnappy(t *testing.T) {
+	for n, test := range [...]struct {
+		input  []byte
+		output string
+		err    error
+	}{
+		{[]byte{12, 8, 'a', 'b', 'c', 0x15, 3}, "abcabcabcabc", nil},
+		{[]byte{7, 4, 'a', 'b', 18, 2, 0}, "abababa", nil},
+		{[]byte{5, 0, 'x', 15, 1, 0, 0, 0}, "xxxxx", nil},
+		{[]byte{6, 8, 'a', 'b', 'c', 0x15, 4}, "", ErrCorrupt},
+		{[]byte{4, 8, 'a', 'b', 'c'}, "", ErrCorrupt},
+	} {
+		output, err := snappyDecode(test.input)
+		if err != test.err {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.err, err)
+		} else if string(output) != test.output {
+			t.Errorf("test %d: expecting output %q, got %q", n+1, test.output, output)
+		}
+	}
+}
+
+func TestLevelDB(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	large := strings.Repeat("0123456789", 8000)
+
+	var older []keyValue
+
+	for c := 'a'; c <= 'p'; c++ {
+		older = append(older, keyValue{key: internalKey(string(c), uint64(c), keyTypeValue), value: []byte("old-" + string(c))})
+	}
+
+	writeTestDB(t, dir, []testTable{
+		{number: 5, compression: compressionZlibRaw, entries: older},
+		{number: 6, compression: compressionZlib, entries: []keyValue{
+			{key: internalKey("q", 200, keyTypeValue), value: []byte("zlib")},
+		}},
+		{number: 7, compression: compressionSnappy, entries: []keyValue{
+			{key: internalKey("b", 300, keyTypeValue), value: []byte("new-b")},
+			{key: internalKey("c", 301, keyTypeDeletion)},
+			{key: internalKey("c", 99, keyTypeValue), value: []byte("older-c")},
+		}},
+	}, 8,
+		encodeBatch(400, batchOp{key: "d", value: "log-d"}, batchOp{key: "e", deleted: true}),
+		encodeBatch(402, batchOp{key: "large", value: large}, batchOp{key: "d", value: "log-d2"}),
+	)
+
+	// A torn write at the end of the log should be ignored.
+	f, err := os.OpenFile(filepath.Join(dir, "000008.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = f.Write([]byte{1, 2, 3, 4, 100, 0, 1, 'x'}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	d, err := openDB(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer d.close()
+
+	expected := map[string]string{
+		"a":     "old-a",
+		"b":     "new-b",
+		"d":     "log-d2",
+		"f":     "old-f",
+		"large": large,
+		"p":     "old-p",
+		"q":     "zlib",
+	}
+
+	for _, key := range [...]string{"a", "b", "c", "d", "e", "f", "large", "p", "q", "r", "0"} {
+		value, err := d.get([]byte(key))
+		if err != nil {
+			t.Errorf("key %q: unexpected error: %s", key, err)
+		} else if exp, ok := expected[key]; !ok && value != nil {
+			t.Errorf("key %q: expecting no value, got %q", key, value)
+		} else if ok && string(value) != exp {
+			t.Errorf("key %q: expecting value %.20q, got %.20q", key, exp, value)
+		}
+	}
+
+	var keys []string
+
+	if err = d.each(func(key, value []byte) error {
+		keys = append(keys, string(key))
+
+		if expected, err := d.get(key); err != nil {
+			return err
+		} else if !bytes.Equal(value, expected) {
+			t.Errorf("key %q: iterated value does not match", key)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if got, exp := strings.Join(keys, ","), "a,b,d,f,g,h,i,j,k,l,large,m,n,o,p,q"; got != exp {
+		t.Errorf("expecting keys %s, got %s", exp, got)
+	}
+}
+
+func TestLevelDBCorruptBlock(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestDB(t, dir, []testTable{
+		{number: 5, compression: compressionNone, entries: []keyValue{
+			{key: internalKey("a", 1, keyTypeValue), value: []byte("value")},
+		}},
+	}, 6)
+
+	name := filepath.Join(dir, "000005.ldb")
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data[10] ^= 0xff
+
+	if err = os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	d, err := openDB(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer d.close()
+
+	if _, err = d.get([]byte("a")); err != ErrBadChecksum {
+		t.Errorf("expecting ErrBadChecksum, got %v", err)
+	}
+}
+
+func TestLevelDBFixture(t *testing.T) {
+	// testdata/world/db was written by github.com/syndtr/goleveldb, with
+	// snappy compressed tables on two levels, and two logs containing
+	// overwrites and deletions that have not yet been compacted.
+	w, err := Open(filepath.Join("testdata", "world"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer w.Close()
+
+	subChunk := func(x, z int32, dim Dimension) string {
+		var sb strings.Builder
+
+		for i := 0; i < 16; i++ {
+			fmt.Fprintf(&sb, "sub-chunk %d,%d,%d:%d;", x, z, dim, i)
+		}
+
+		return sb.String()
+	}
+
+	rewritten := func(z int32) string {
+		var v [1024]byte
+
+		for i := range v {
+			v[i] = byte('a' + (int(z)+4+i)%26)
+		}
+
+		return string(v[:])
+	}
+
+	for n, test := range [...]struct {
+		key   []byte
+		value string
+	}{
+		{[]byte("~local_player"), "player v2"},
+		{[]byte("~local_players"), ""},
+		{chunkKey(ChunkPos{X: 0, Z: 0}, KeyVersion), "\x28"},
+		{chunkKey(ChunkPos{X: 1, Z: -3}, KeySubChunk, 0), subChunk(1, -3, Overworld)},
+		{chunkKey(ChunkPos{X: -2, Z: 3, Dimension: Nether}, KeySubChunk, 0), subChunk(-2, 3, Nether)},
+		{chunkKey(ChunkPos{X: -4, Z: -4}, KeySubChunk, 0), rewritten(-4)},
+		{chunkKey(ChunkPos{X: -4, Z: 3}, KeySubChunk, 0), rewritten(3)},
+		{chunkKey(ChunkPos{X: -4, Z: -4}, KeySubChunk, 1), "sub-chunk 1 again"},
+		{chunkKey(ChunkPos{X: -4, Z: -4, Dimension: Nether}, KeySubChunk, 0), subChunk(-4, -4, Nether)},
+		{chunkKey(ChunkPos{X: 2, Z: 2}, KeyVersion), ""},
+		{chunkKey(ChunkPos{X: 2, Z: 2}, KeySubChunk, 0), subChunk(2, 2, Overworld)},
+		{chunkKey(ChunkPos{X: 3, Z: 3, Dimension: Nether}, KeyVersion), ""},
+		{chunkKey(ChunkPos{X: 3, Z: 3, Dimension: Nether}, KeySubChunk, 0), ""},
+		{chunkKey(ChunkPos{X: 4, Z: 0}, KeyVersion), ""},
+	} {
+		value, err := w.Get(test.key)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if string(value) != test.value {
+			t.Errorf("test %d: expecting value %.30q, got %.30q", n+1, test.value, value)
+		}
+	}
+
+	var (
+		count int
+		last  []byte
+	)
+
+	if err = w.Each(func(key, _ []byte) error {
+		if last != nil && bytes.Compare(last, key) >= 0 {
+			t.Errorf("key %x is not after %x", key, last)
+		}
+
+		last = append(last[:0], key...)
+		count++
+
+		return nil
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if count != 255 {
+		t.Errorf("expecting 255 keys, got %d", count)
+	}
+
+	chunks, err := w.Chunks()
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(chunks) != 126 {
+		t.Fatalf("expecting 126 chunks, got %d", len(chunks))
+	}
+
+	if first := (ChunkPos{X: -4, Z: -4}); chunks[0] != first {
+		t.Errorf("expecting first chunk %v, got %v", first, chunks[0])
+	}
+
+	if last := (ChunkPos{X: 2, Z: 3, Dimension: Nether}); chunks[125] != last {
+		t.Errorf("expecting last chunk %v, got %v", last, chunks[125])
+	}
+}
+
+func TestLevelDBBlockCache(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestDB(t, dir, []testTable{
+		{number: 5, compression: compressionZlib, entries: []keyValue{
+			{key: internalKey("a", 1, keyTypeValue), value: []byte("value-a")},
+			{key: internalKey("b", 2, keyTypeValue), value: []byte("value-b")},
+		}},
+	}, 6)
+
+	d, err := openDB(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer d.close()
+
+	value, err := d.get([]byte("a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if string(value) != "value-a" {
+		t.Fatalf("expecting value %q, got %q", "value-a", value)
+	}
+
+	value[0] = 'X'
+
+	// Corrupting the data block on disk must not affect lookups served by
+	// the cached block.
+	name := filepath.Join(dir, "000005.ldb")
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data[10] ^= 0xff
+
+	if err = os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range [...]string{"a", "b"} {
+		if value, err := d.get([]byte(key)); err != nil {
+			t.Errorf("key %q: unexpected error: %s", key, err)
+		} else if string(value) != "value-"+key {
+			t.Errorf("key %q: expecting value %q, got %q", key, "value-"+key, value)
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package bedrock
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// StructureFormatVersion is the format version written by Structure.Encode.
+const StructureFormatVersion = 1
+
+// Structure is a structure saved by a Bedrock edition structure block, as
+// stored in a .mcstructure file.
+type Structure struct {
+	Size   [3]int32
+	Origin [3]int32
+
+	// Palette contains the block states used by the structure, each a
+	// compound with a "name", a "states" compound and, optionally, a
+	// "version".
+	Palette []nbt.Compound
+
+	// Layers contains the palette index of each block for both layers of
+	// the structure, indexed by (x*height+y)*length+z. The first layer holds
+	// the blocks and the second usually holds waterlogging. An index of -1
+	// marks a structure void.
+	Layers [2][]int32
+
+	// BlockPositionData contains extra data, such as block entities, for
+	// blocks, keyed by their index.
+	BlockPositionData map[int32]nbt.Compound
+
+	Entities []nbt.Compound
+}
+
+// NewStructure creates a new structure of the given size, filled with
+// structure voids.
+//
+// Returns ErrInvalidSize if any dimension is negative, or the structure would
+// hold more blocks than can be indexed.
+func NewStructure(width, height, length int32) (*Structure, error) {
+	s := &Structure{
+		Size:              [3]int32{width, height, length},
+		BlockPositionData: make(map[int32]nbt.Compound),
+	}
+
+	if err := checkSize(s.Size); err != nil {
+		return nil, err
+	}
+
+	for n := range s.Layers {
+		s.Layers[n] = make([]int32, s.volume())
+
+		for i := range s.Layers[n] {
+			s.Layers[n][i] = -1
+		}
+	}
+
+	return s, nil
+}
+
+// ExportStructure creates a new structure from the blocks within the given area
+// of src, using the given mapper to convert the blocks to block states.
+// Unmapped blocks are exported with their own block states. Block metadata is
+// stored as block entity data.
+func ExportStructure(src minecraft.BlockReader, area minecraft.Area, m minecraft.BlockMapper) (*Structure, error) {
+	s, err := NewStructure(area.Width, area.Height, area.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Origin = [3]int32{area.X, area.Y, area.Z}
+
+	if err := minecraft.CopyArea(&structureWriter{Structure: s, mapper: m, indices: make(map[string]int32)}, 0, 0, 0, src, area); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Area returns the area covered by the structure.
+func (s *Structure) Area() minecraft.Area {
+	return minecraft.Area{Width: s.Size[0], Height: s.Size[1], Length: s.Size[2]}
+}
+
+// Paste writes the first layer of blocks in the structure to dst, with the
+// corner of the structure at the given coords, using the given mapper to
+// convert the block states to blocks; block states it has no mapping for are
+// placed as unmapped blocks. Structure voids leave the existing blocks
+// unchanged. Entities and the second layer are not placed.
+func (s *Structure) Paste(dst minecraft.BlockWriter, x, y, z int32, m minecraft.BlockMapper) error {
+	blocks := make([]minecraft.Block, len(s.Palette))
+
+	for n, c := range s.Palette {
+		state := StateFromNBT(c)
+
+		blocks[n] = minecraft.BlockOf(m, state)
+	}
+
+	return minecraft.CopyArea(&voidSkipper{Structure: s, dst: dst, x: x, y: y, z: z}, x, y, z, &structureReader{Structure: s, blocks: blocks}, s.Area())
+}
+
+// checkSize returns ErrInvalidSize if any dimension of the size is negative,
+// or the volume cannot be indexed by an int32.
+func checkSize(size [3]int32) error {
+	volume := int64(1)
+
+	for _, d := range size {
+		if d < 0 {
+			return ErrInvalidSize
+		} else if volume *= int64(d); volume > math.MaxInt32 {
+			return ErrInvalidSize
+		}
+	}
+
+	return nil
+}
+
+func (s *Structure) volume() int {
+	return int(s.Size[0]) * int(s.Size[1]) * int(s.Size[2])
+}
+
+func (s *Structure) index(x, y, z int32) (int32, bool) {
+	if x < 0 || y < 0 || z < 0 || x >= s.Size[0] || y >= s.Size[1] || z >= s.Size[2] {
+		return 0, false
+	}
+
+	return (x*s.Size[1]+y)*s.Size[2] + z, true
+}
+
+type structureReader struct {
+	*Structure
+	blocks []minecraft.Block
+}
+
+func (s *structureReader) GetBlock(x, y, z int32) (minecraft.Block, error) {
+	i, ok := s.index(x, y, z)
+	if !ok {
+		return minecraft.Block{}, minecraft.ErrOOB
+	}
+
+	p := s.Layers[0][i]
+	if p < 0 {
+		return minecraft.Block{}, nil
+	} else if int(p) >= len(s.blocks) {
+		return minecraft.Block{}, minecraft.ErrOOB
+	}
+
+	b := s.blocks[p]
+
+	if data, ok := s.BlockPositionData[i].Get("block_entity_data").Data().(nbt.Compound); ok {
+		b.SetMetadata(data)
+	}
+
+	return b, nil
+}
+
+type voidSkipper struct {
+	*Structure
+	dst     minecraft.BlockWriter
+	x, y, z int32
+}
+
+func (v *voidSkipper) SetBlock(x, y, z int32, b minecraft.Block) error {
+	if i, ok := v.index(x-v.x, y-v.y, z-v.z); ok && v.Layers[0][i] < 0 {
+		return nil
+	}
+
+	return v.dst.SetBlock(x, y, z, b)
+}
+
+type structureWriter struct {
+	*Structure
+	mapper  minecraft.BlockMapper
+	indices map[string]int32
+}
+
+func (s *structureWriter) SetBlock(x, y, z int32, b minecraft.Block) error {
+	i, ok := s.index(x, y, z)
+	if !ok {
+		return minecraft.ErrOOB
+	}
+
+	state, ok := minecraft.StateOf(s.mapper, b)
+	if !ok {
+		return minecraft.UnknownBlock{ID: b.ID, Data: b.Data}
+	}
+
+	key := state.String()
+
+	p, ok := s.indices[key]
+	if !ok {
+		p = int32(len(s.Palette))
+		s.indices[key] = p
+		s.Palette = append(s.Palette, StateToNBT(state))
+	}
+
+	s.Layers[0][i] = p
+
+	if b.HasMetadata() {
+		s.BlockPositionData[i] = nbt.Compound{
+			nbt.NewTag("block_entity_data", append(b.GetMetadata(),
+				nbt.NewTag("x", nbt.Int(s.Origin[0]+x)),
+				nbt.NewTag("y", nbt.Int(s.Origin[1]+y)),
+				nbt.NewTag("z", nbt.Int(s.Origin[2]+z)),
+			)),
+		}
+	}
+
+	return nil
+}
+
+// StateFromNBT converts a Bedrock block state compound into a BlockState.
+// Byte values of 0 and 1 become "false" and "true", and integers are written
+// in decimal.
+func StateFromNBT(c nbt.Compound) minecraft.BlockState {
+	name, _ := c.Get("name").Data().(nbt.String)
+	state := minecraft.BlockState{Name: string(name)}
+
+	if states, ok := c.Get("states").Data().(nbt.Compound); ok && len(states) > 0 {
+		state.Properties = make(map[string]string, len(states))
+
+		for _, t := range states {
+			var v string
+
+			switch d := t.Data().(type) {
+			case nbt.String:
+				v = string(d)
+			case nbt.Byte:
+				switch d {
+				case 0:
+					v = "false"
+				case 1:
+					v = "true"
+				default:
+					v = strconv.Itoa(int(d))
+				}
+			case nbt.Int:
+				v = strconv.FormatInt(int64(d), 10)
+			default:
+				v = d.String()
+			}
+
+			state.Properties[t.Name()] = v
+		}
+	}
+
+	return state
+}
+
+// StateToNBT converts a BlockState into a Bedrock block state compound,
+// reversing the conversion of StateFromNBT.
+func StateToNBT(state minecraft.BlockState) nbt.Compound {
+	states := make(nbt.Compound, 0, len(state.Properties))
+
+	for _, k := range state.PropertyNames() {
+		var d nbt.Data
+
+		v := state.Properties[k]
+
+		switch v {
+		case "false":
+			d = nbt.Byte(0)
+		case "true":
+			d = nbt.Byte(1)
+		default:
+			if i, err := strconv.ParseInt(v, 10, 32); err == nil {
+				d = nbt.Int(i)
+			} else {
+				d = nbt.String(v)
+			}
+		}
+
+		states = append(states, nbt.NewTag(k, d))
+	}
+
+	return nbt.Compound{
+		nbt.NewTag("name", nbt.String(state.Name)),
+		nbt.NewTag("states", states),
+	}
+}
+
+func getTag(c nbt.Compound, name string, tagType nbt.TagID) (nbt.Data, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, minecraft.MissingTagError{TagName: name}
+	} else if tag.TagID() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name, Expecting: tagType, Got: tag.TagID()}
+	}
+
+	return tag.Data(), nil
+}
+
+func getList(c nbt.Compound, name string, tagType nbt.TagID) (nbt.List, error) {
+	d, err := getTag(c, name, nbt.TagList)
+	if err != nil {
+		return nil, err
+	}
+
+	list := d.(nbt.List)
+	if list.Len() > 0 && list.TagType() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name + "->Child", Expecting: tagType, Got: list.TagType()}
+	}
+
+	return list, nil
+}
+
+func getCoords(c nbt.Compound, name string) ([3]int32, error) {
+	var coords [3]int32
+
+	list, err := getList(c, name, nbt.TagInt)
+	if err != nil {
+		return coords, err
+	} else if list.Len() != 3 {
+		return coords, minecraft.UnexpectedValue{TagName: name, Expecting: "3 values", Got: strconv.Itoa(list.Len())}
+	}
+
+	for n := range coords {
+		coords[n] = int32(list.Get(n).(nbt.Int))
+	}
+
+	return coords, nil
+}
+
+func coordList(coords [3]int32) nbt.List {
+	return &nbt.ListInt{nbt.Int(coords[0]), nbt.Int(coords[1]), nbt.Int(coords[2])}
+}
+
+func compoundList(compounds []nbt.Compound) nbt.List {
+	list := make(nbt.ListCompound, len(compounds))
+
+	copy(list, compounds)
+
+	return &list
+}
+
+// DecodeStructure reads a structure from the little-endian NBT of an
+// .mcstructure file.
+func DecodeStructure(r io.Reader) (*Structure, error) {
+	root, err := nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: r}).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := root.Data().(nbt.Compound)
+	if !ok {
+		return nil, minecraft.WrongTypeError{TagName: "[Structure]", Expecting: nbt.TagCompound, Got: root.TagID()}
+	}
+
+	s := &Structure{BlockPositionData: make(map[int32]nbt.Compound)}
+
+	if s.Size, err = getCoords(c, "size"); err != nil {
+		return nil, err
+	} else if err = checkSize(s.Size); err != nil {
+		return nil, err
+	}
+
+	if c.Get("structure_world_origin").TagID() != 0 {
+		if s.Origin, err = getCoords(c, "structure_world_origin"); err != nil {
+			return nil, err
+		}
+	}
+
+	sd, err := getTag(c, "structure", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	structure := sd.(nbt.Compound)
+
+	layers, err := getList(structure, "block_indices", nbt.TagList)
+	if err != nil {
+		return nil, err
+	} else if layers.Len() > len(s.Layers) {
+		return nil, minecraft.UnexpectedValue{TagName: "block_indices", Expecting: "up to 2 layers", Got: strconv.Itoa(layers.Len())}
+	}
+
+	volume := s.volume()
+
+	if layers.Len() == 0 && volume > 0 {
+		return nil, minecraft.UnexpectedValue{TagName: "block_indices", Expecting: strconv.Itoa(volume) + " indices", Got: "0"}
+	}
+
+	for n := 0; n < layers.Len(); n++ {
+		if l := layers.Get(n).(nbt.List).Len(); l != volume {
+			return nil, minecraft.UnexpectedValue{TagName: "block_indices", Expecting: strconv.Itoa(volume) + " indices", Got: strconv.Itoa(l)}
+		}
+	}
+
+	for n := range s.Layers {
+		s.Layers[n] = make([]int32, volume)
+
+		if n >= layers.Len() {
+			for i := range s.Layers[n] {
+				s.Layers[n][i] = -1
+			}
+
+			continue
+		}
+
+		layer := layers.Get(n).(nbt.List)
+		if volume > 0 && layer.TagType() != nbt.TagInt {
+			return nil, minecraft.WrongTypeError{TagName: "block_indices->Child", Expecting: nbt.TagInt, Got: layer.TagType()}
+		}
+
+		for i := range s.Layers[n] {
+			s.Layers[n][i] = int32(layer.Get(i).(nbt.Int))
+		}
+	}
+
+	if structure.Get("entities").TagID() != 0 {
+		entities, err := getList(structure, "entities", nbt.TagCompound)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < entities.Len(); i++ {
+			s.Entities = append(s.Entities, entities.Get(i).(nbt.Compound))
+		}
+	}
+
+	pd, err := getTag(structure, "palette", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	dd, err := getTag(pd.(nbt.Compound), "default", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	def := dd.(nbt.Compound)
+
+	palette, err := getList(def, "block_palette", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Palette = make([]nbt.Compound, palette.Len())
+
+	for n := range s.Palette {
+		s.Palette[n] = palette.Get(n).(nbt.Compound)
+	}
+
+	for _, layer := range s.Layers {
+		for _, p := range layer {
+			if p < -1 || int(p) >= len(s.Palette) {
+				return nil, minecraft.UnexpectedValue{TagName: "block_indices", Expecting: "index less than " + strconv.Itoa(len(s.Palette)), Got: strconv.FormatInt(int64(p), 10)}
+			}
+		}
+	}
+
+	if positionData, ok := def.Get("block_position_data").Data().(nbt.Compound); ok {
+		for _, t := range positionData {
+			i, err := strconv.ParseInt(t.Name(), 10, 32)
+			if err != nil || i < 0 || int(i) >= volume {
+				return nil, minecraft.UnexpectedValue{TagName: "block_position_data", Expecting: "block index", Got: t.Name()}
+			}
+
+			data, ok := t.Data().(nbt.Compound)
+			if !ok {
+				return nil, minecraft.WrongTypeError{TagName: "block_position_data->" + t.Name(), Expecting: nbt.TagCompound, Got: t.TagID()}
+			}
+
+			s.BlockPositionData[int32(i)] = data
+		}
+	}
+
+	return s, nil
+}
+
+// Encode writes the structure, as little-endian NBT, in the .mcstructure
+// format.
+func (s *Structure) Encode(w io.Writer) error {
+	if err := checkSize(s.Size); err != nil {
+		return err
+	}
+
+	volume := s.volume()
+	list := nbt.NewEmptyList(nbt.TagList)
+
+	for _, layer := range s.Layers {
+		indices := make(nbt.ListInt, volume)
+
+		for i := range indices {
+			if i < len(layer) {
+				indices[i] = nbt.Int(layer[i])
+			} else {
+				indices[i] = -1
+			}
+		}
+
+		if err := list.Append(&indices); err != nil {
+			return err
+		}
+	}
+
+	indices := make([]int32, 0, len(s.BlockPositionData))
+
+	for i := range s.BlockPositionData {
+		indices = append(indices, i)
+	}
+
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+
+	positionData := make(nbt.Compound, len(indices))
+
+	for n, i := range indices {
+		positionData[n] = nbt.NewTag(strconv.FormatInt(int64(i), 10), s.BlockPositionData[i])
+	}
+
+	return nbt.NewEncoderEndian(&byteio.LittleEndianWriter{Writer: w}).Encode(nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("format_version", nbt.Int(StructureFormatVersion)),
+		nbt.NewTag("size", coordList(s.Size)),
+		nbt.NewTag("structure", nbt.Compound{
+			nbt.NewTag("block_indices", list),
+			nbt.NewTag("entities", compoundList(s.Entities)),
+			nbt.NewTag("palette", nbt.Compound{
+				nbt.NewTag("default", nbt.Compound{
+					nbt.NewTag("block_palette", compoundList(s.Palette)),
+					nbt.NewTag("block_position_data", positionData),
+				}),
+			}),
+		}),
+		nbt.NewTag("structure_world_origin", coordList(s.Origin)),
+	}))
+}
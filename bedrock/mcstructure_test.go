@@ -0,0 +1,237 @@
+package bedrock
+
+import (
+	"bytes"
+	"testing"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func testStructureMapper(t *testing.T) *minecraft.BlockStateTable {
+	t.Helper()
+
+	m := minecraft.NewBlockStateTable()
+
+	for _, s := range [...]struct {
+		state string
+		block minecraft.Block
+	}{
+		{"air", minecraft.Block{}},
+		{"stone", minecraft.Block{ID: 1}},
+		{"wool[color=red]", minecraft.Block{ID: 35, Data: 14}},
+		{"chest[facing_direction=3]", minecraft.Block{ID: 54, Data: 3}},
+		{"wooden_slab[top_slot_bit=true,wood_type=oak]", minecraft.Block{ID: 126, Data: 8}},
+	} {
+		state, err := minecraft.ParseBlockState(s.state)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		m.Add(state, s.block)
+	}
+
+	return m
+}
+
+func TestStructure(t *testing.T) {
+	m := testStructureMapper(t)
+
+	src, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chest := minecraft.Block{ID: 54, Data: 3}
+	chest.SetMetadata(nbt.Compound{nbt.NewTag("id", nbt.String("Chest"))})
+
+	blocks := []struct {
+		x, y, z int32
+		block   minecraft.Block
+	}{
+		{5, 20, 5, minecraft.Block{ID: 1}},
+		{6, 20, 5, minecraft.Block{ID: 35, Data: 14}},
+		{5, 21, 6, chest},
+		{6, 21, 6, minecraft.Block{ID: 126, Data: 8}},
+	}
+
+	for _, b := range blocks {
+		if err = src.SetBlock(b.x, b.y, b.z, b.block); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	s, err := ExportStructure(src, minecraft.Area{X: 5, Y: 20, Z: 5, Width: 2, Height: 2, Length: 2}, m)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(s.Palette) != 5 {
+		t.Fatalf("expecting 5 palette entries, got %d", len(s.Palette))
+	}
+
+	var buf bytes.Buffer
+
+	if err = s.Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	raw, err := nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: bytes.NewReader(buf.Bytes())}).Decode()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	palette := raw.Data().(nbt.Compound).Get("structure").Data().(nbt.Compound).Get("palette").Data().(nbt.Compound).Get("default").Data().(nbt.Compound).Get("block_palette").Data().(nbt.List)
+
+	for i := 0; i < palette.Len(); i++ {
+		c := palette.Get(i).(nbt.Compound)
+		states := c.Get("states").Data().(nbt.Compound)
+
+		switch c.Get("name").Data().(nbt.String) {
+		case "minecraft:chest":
+			if _, ok := states.Get("facing_direction").Data().(nbt.Int); !ok {
+				t.Errorf("expecting facing_direction to be an Int, got %s", states.Get("facing_direction").TagID())
+			}
+		case "minecraft:wooden_slab":
+			if b, ok := states.Get("top_slot_bit").Data().(nbt.Byte); !ok || b != 1 {
+				t.Errorf("expecting top_slot_bit to be Byte 1, got %s", states.Get("top_slot_bit"))
+			} else if _, ok := states.Get("wood_type").Data().(nbt.String); !ok {
+				t.Errorf("expecting wood_type to be a String, got %s", states.Get("wood_type").TagID())
+			}
+		}
+	}
+
+	if s, err = DecodeStructure(&buf); err != nil {
+		t.Fatal(err.Error())
+	} else if s.Size != [3]int32{2, 2, 2} || s.Origin != [3]int32{5, 20, 5} {
+		t.Fatalf("incorrect size or origin: %v, %v", s.Size, s.Origin)
+	}
+
+	// chest at x=0, y=1, z=1
+	if data, ok := s.BlockPositionData[3].Get("block_entity_data").Data().(nbt.Compound); !ok {
+		t.Fatal("expecting block entity data for chest")
+	} else if data.Get("x").Data().(nbt.Int) != 5 || data.Get("y").Data().(nbt.Int) != 21 || data.Get("z").Data().(nbt.Int) != 6 {
+		t.Errorf("incorrect block entity position: %s", data)
+	}
+
+	// make the air block at x=1, y=1, z=0 a structure void
+	s.Layers[0][6] = -1
+
+	dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = dst.SetBlock(1, 51, 0, minecraft.Block{ID: 4}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = dst.SetBlock(0, 51, 0, minecraft.Block{ID: 4}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = s.Paste(dst, 0, 50, 0, m); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, b := range blocks {
+		got, err := dst.GetBlock(b.x-5, b.y+30, b.z-5)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if !got.EqualBlock(b.block) {
+			t.Errorf("expecting block %s at %d,%d,%d, got %s", b.block, b.x, b.y, b.z, got)
+		}
+	}
+
+	if b, err := dst.GetBlock(1, 51, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if b.ID != 4 {
+		t.Errorf("expecting structure void to leave cobblestone, got %s", b)
+	}
+
+	if b, err := dst.GetBlock(0, 51, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if b.ID != 0 {
+		t.Errorf("expecting air to replace cobblestone, got %s", b)
+	}
+}
+
+func TestStructureEncodeDeterministic(t *testing.T) {
+	s, err := NewStructure(2, 2, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s.Palette = []nbt.Compound{StateToNBT(minecraft.BlockState{
+		Name: "minecraft:bamboo",
+		Properties: map[string]string{
+			"age_bit":                "false",
+			"bamboo_leaf_size":       "small_leaves",
+			"bamboo_stalk_thickness": "thin",
+		},
+	})}
+
+	for i := int32(0); i < 8; i++ {
+		s.Layers[0][i] = 0
+		s.BlockPositionData[i] = nbt.Compound{nbt.NewTag("block_entity_data", nbt.Compound{nbt.NewTag("x", nbt.Int(i))})}
+	}
+
+	var first []byte
+
+	for n := 0; n < 10; n++ {
+		var buf bytes.Buffer
+
+		if err = s.Encode(&buf); err != nil {
+			t.Fatal(err.Error())
+		} else if first == nil {
+			first = buf.Bytes()
+		} else if !bytes.Equal(buf.Bytes(), first) {
+			t.Fatalf("encoding %d differs from the first", n+1)
+		}
+	}
+}
+
+func TestStructureSize(t *testing.T) {
+	if _, err := NewStructure(2, -1, 2); err != ErrInvalidSize {
+		t.Errorf("expecting ErrInvalidSize for negative size, got %v", err)
+	} else if _, err = NewStructure(1<<16, 1<<16, 1); err != ErrInvalidSize {
+		t.Errorf("expecting ErrInvalidSize for large size, got %v", err)
+	}
+
+	s, err := NewStructure(2, 2, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s.Palette = []nbt.Compound{{nbt.NewTag("name", nbt.String("minecraft:air"))}}
+
+	var buf bytes.Buffer
+
+	if err = s.Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	raw, err := nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: bytes.NewReader(buf.Bytes())}).Decode()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for n, test := range [...]struct {
+		size  [3]int32
+		check func(error) bool
+	}{
+		{[3]int32{-1, 2, 2}, func(err error) bool { return err == ErrInvalidSize }},
+		{[3]int32{2, -2, -2}, func(err error) bool { return err == ErrInvalidSize }},
+		{[3]int32{1 << 16, 1 << 16, 1}, func(err error) bool { return err == ErrInvalidSize }},
+		{[3]int32{1 << 20, 1 << 10, 1}, func(err error) bool {
+			_, ok := err.(minecraft.UnexpectedValue)
+
+			return ok
+		}},
+	} {
+		c := raw.Data().(nbt.Compound)
+
+		c.Set(nbt.NewTag("size", coordList(test.size)))
+
+		buf.Reset()
+
+		if err = nbt.NewEncoderEndian(&byteio.LittleEndianWriter{Writer: &buf}).Encode(raw); err != nil {
+			t.Fatal(err.Error())
+		} else if _, err = DecodeStructure(&buf); !test.check(err) {
+			t.Errorf("test %d: unexpected error for size %v: %v", n+1, test.size, err)
+		}
+	}
+}
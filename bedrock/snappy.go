@@ -0,0 +1,93 @@
+package bedrock
+
+import "encoding/binary"
+
+// snappyDecode decodes a block of snappy compressed data.
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 1<<32-1 {
+		return nil, ErrCorrupt
+	}
+
+	dst := make([]byte, 0, length)
+	src = src[n:]
+
+	for len(src) > 0 {
+		tag := src[0]
+
+		var offset, size int
+
+		switch tag & 3 {
+		case 0:
+			size = int(tag >> 2)
+			src = src[1:]
+
+			if size >= 60 {
+				extra := size - 59
+				if len(src) < extra {
+					return nil, ErrCorrupt
+				}
+
+				size = 0
+
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[i])
+				}
+
+				src = src[extra:]
+			}
+
+			size++
+
+			if size > len(src) || uint64(len(dst)+size) > length {
+				return nil, ErrCorrupt
+			}
+
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, ErrCorrupt
+			}
+
+			size = 4 + int(tag>>2&7)
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, ErrCorrupt
+			}
+
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, ErrCorrupt
+			}
+
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+size) > length {
+			return nil, ErrCorrupt
+		}
+
+		// Copies may overlap the data they produce, so must be done byte
+		// by byte.
+		for start := len(dst) - offset; size > 0; size-- {
+			dst = append(dst, dst[start])
+			start++
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, ErrCorrupt
+	}
+
+	return dst, nil
+}
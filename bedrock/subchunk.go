@@ -0,0 +1,151 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// SubChunk is a 16x16x16 section of a chunk.
+type SubChunk struct {
+	Y int8
+
+	// Layers contains the block storages of the sub-chunk. The first layer
+	// holds the blocks, and the second, if present, usually holds
+	// waterlogging.
+	Layers []BlockStorage
+}
+
+// Block returns the block state of the first layer at the given coords
+// within the sub-chunk, each of which is in the range 0-15.
+func (s *SubChunk) Block(x, y, z int) nbt.Tag {
+	if len(s.Layers) == 0 {
+		return nbt.Tag{}
+	}
+
+	return s.Layers[0].Block(x, y, z)
+}
+
+// BlockStorage is a paletted layer of blocks within a sub-chunk.
+type BlockStorage struct {
+	// Palette contains the block state of each distinct block in the
+	// layer.
+	Palette []nbt.Tag
+
+	// Indices are the palette index of each block, indexed by
+	// x<<8|z<<4|y.
+	Indices [4096]uint16
+}
+
+// Block returns the block state at the given coords, each of which is in the
+// range 0-15.
+func (b *BlockStorage) Block(x, y, z int) nbt.Tag {
+	i := b.Indices[(x&15)<<8|(z&15)<<4|y&15]
+	if int(i) >= len(b.Palette) {
+		return nbt.Tag{}
+	}
+
+	return b.Palette[i]
+}
+
+func decodeSubChunk(data []byte, y int8) (*SubChunk, error) {
+	if len(data) == 0 {
+		return nil, ErrCorrupt
+	}
+
+	s := &SubChunk{Y: y}
+	layers := 1
+	version := data[0]
+	data = data[1:]
+
+	switch version {
+	case 1:
+	case 8, 9:
+		if len(data) == 0 {
+			return nil, ErrCorrupt
+		}
+
+		layers = int(data[0])
+		data = data[1:]
+
+		if version == 9 {
+			if len(data) == 0 {
+				return nil, ErrCorrupt
+			}
+
+			s.Y = int8(data[0])
+			data = data[1:]
+		}
+	default:
+		return nil, UnsupportedSubChunkVersion{version}
+	}
+
+	r := bytes.NewReader(data)
+
+	s.Layers = make([]BlockStorage, layers)
+
+	for n := range s.Layers {
+		if err := decodeBlockStorage(r, &s.Layers[n]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func decodeBlockStorage(r *bytes.Reader, b *BlockStorage) error {
+	header, err := r.ReadByte()
+	if err != nil {
+		return ErrCorrupt
+	} else if header&1 != 0 {
+		return ErrRuntimeIDs
+	}
+
+	bits := int(header >> 1)
+
+	switch bits {
+	case 0, 1, 2, 3, 4, 5, 6, 8, 16:
+	default:
+		return ErrBadBitsPerBlock
+	}
+
+	if bits > 0 {
+		perWord := 32 / bits
+		words := make([]byte, (4096+perWord-1)/perWord*4)
+		mask := uint32(1)<<bits - 1
+
+		if _, err = io.ReadFull(r, words); err != nil {
+			return ErrCorrupt
+		}
+
+		for i := range b.Indices {
+			word := binary.LittleEndian.Uint32(words[i/perWord*4:])
+			b.Indices[i] = uint16(word >> (uint(i%perWord) * uint(bits)) & mask)
+		}
+	}
+
+	var count [4]byte
+
+	if _, err = io.ReadFull(r, count[:]); err != nil {
+		return ErrCorrupt
+	}
+
+	size := binary.LittleEndian.Uint32(count[:])
+	if uint64(size) > uint64(r.Len()) {
+		return ErrCorrupt
+	}
+
+	d := nbt.NewDecoderEndian(&byteio.LittleEndianReader{Reader: r})
+	b.Palette = make([]nbt.Tag, size)
+
+	for i := range b.Palette {
+		if b.Palette[i], err = d.Decode(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,362 @@
+package bedrock
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	tableFooterSize = 48
+	tableMagic      = 0xdb4775248b80fb57
+	blockTrailerLen = 5
+
+	compressionNone    = 0
+	compressionSnappy  = 1
+	compressionZlib    = 2
+	compressionZlibRaw = 4
+
+	keyTypeDeletion = 0
+	keyTypeValue    = 1
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// unmaskCRC reverses the masking LevelDB applies to stored checksums.
+func unmaskCRC(c uint32) uint32 {
+	c -= 0xa282ead8
+
+	return c>>17 | c<<15
+}
+
+// compareInternal orders internal keys by ascending user key and then by
+// descending sequence number, so that the newest version of a key comes
+// first.
+func compareInternal(a, b []byte) int {
+	if c := bytes.Compare(a[:len(a)-8], b[:len(b)-8]); c != 0 {
+		return c
+	}
+
+	ta := binary.LittleEndian.Uint64(a[len(a)-8:])
+	tb := binary.LittleEndian.Uint64(b[len(b)-8:])
+
+	if ta > tb {
+		return -1
+	} else if ta < tb {
+		return 1
+	}
+
+	return 0
+}
+
+// lookupKey returns an internal key that sorts before all versions of the
+// given user key.
+func lookupKey(key []byte) []byte {
+	ikey := make([]byte, len(key)+8)
+
+	copy(ikey, key)
+	binary.LittleEndian.PutUint64(ikey[len(key):], 1<<64-1)
+
+	return ikey
+}
+
+type keyValue struct {
+	key, value []byte
+}
+
+type blockHandle struct {
+	offset, size uint64
+}
+
+func decodeBlockHandle(b []byte) (blockHandle, int) {
+	offset, n := binary.Uvarint(b)
+	if n <= 0 {
+		return blockHandle{}, 0
+	}
+
+	size, m := binary.Uvarint(b[n:])
+	if m <= 0 {
+		return blockHandle{}, 0
+	}
+
+	return blockHandle{offset, size}, n + m
+}
+
+// decodeBlock decodes all of the prefix compressed entries in a block.
+func decodeBlock(b []byte) ([]keyValue, error) {
+	if len(b) < 4 {
+		return nil, ErrCorrupt
+	}
+
+	restarts := uint64(binary.LittleEndian.Uint32(b[len(b)-4:]))
+	if restarts*4+4 > uint64(len(b)) {
+		return nil, ErrCorrupt
+	}
+
+	data := b[:uint64(len(b))-restarts*4-4]
+
+	var (
+		entries []keyValue
+		key     []byte
+	)
+
+	for len(data) > 0 {
+		var header [3]uint64
+
+		for i := range header {
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, ErrCorrupt
+			}
+
+			header[i] = v
+			data = data[n:]
+		}
+
+		shared, unshared, valueLen := header[0], header[1], header[2]
+		if shared > uint64(len(key)) || unshared+valueLen > uint64(len(data)) {
+			return nil, ErrCorrupt
+		}
+
+		newKey := make([]byte, shared+unshared)
+
+		copy(newKey, key[:shared])
+		copy(newKey[shared:], data[:unshared])
+
+		key = newKey
+
+		entries = append(entries, keyValue{key: key, value: data[unshared : unshared+valueLen]})
+		data = data[unshared+valueLen:]
+	}
+
+	return entries, nil
+}
+
+// table is a single sorted table (.ldb) file.
+//
+// The most recently decoded block is kept so that successive lookups of
+// nearby keys, such as the records of a single chunk, do not each re-read
+// and decompress the same block.
+type table struct {
+	f     *os.File
+	index []keyValue
+
+	mu          sync.Mutex
+	cached      blockHandle
+	cachedBlock []keyValue
+}
+
+func openTable(name string) (*table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{f: f}
+
+	if err = t.readIndex(); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *table) readIndex() error {
+	stat, err := t.f.Stat()
+	if err != nil {
+		return err
+	} else if stat.Size() < tableFooterSize {
+		return ErrCorrupt
+	}
+
+	var footer [tableFooterSize]byte
+
+	if _, err = t.f.ReadAt(footer[:], stat.Size()-tableFooterSize); err != nil {
+		return err
+	} else if binary.LittleEndian.Uint64(footer[40:]) != tableMagic {
+		return ErrCorrupt
+	}
+
+	_, n := decodeBlockHandle(footer[:])
+	if n == 0 {
+		return ErrCorrupt
+	}
+
+	index, m := decodeBlockHandle(footer[n:])
+	if m == 0 {
+		return ErrCorrupt
+	}
+
+	t.index, err = t.readBlock(index)
+
+	return err
+}
+
+// block returns the decoded entries of the block with the given handle,
+// reusing the last decoded block when possible.
+func (t *table) block(h blockHandle) ([]keyValue, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cachedBlock != nil && t.cached == h {
+		return t.cachedBlock, nil
+	}
+
+	entries, err := t.readBlock(h)
+	if err != nil {
+		return nil, err
+	}
+
+	t.cached, t.cachedBlock = h, entries
+
+	return entries, nil
+}
+
+func (t *table) readBlock(h blockHandle) ([]keyValue, error) {
+	if h.size > 1<<31 {
+		return nil, ErrCorrupt
+	}
+
+	buf := make([]byte, h.size+blockTrailerLen)
+
+	if _, err := t.f.ReadAt(buf, int64(h.offset)); err != nil {
+		if err == io.EOF {
+			err = ErrCorrupt
+		}
+
+		return nil, err
+	}
+
+	data, compression := buf[:h.size], buf[h.size]
+
+	if unmaskCRC(binary.LittleEndian.Uint32(buf[h.size+1:])) != crc32.Checksum(buf[:h.size+1], crcTable) {
+		return nil, ErrBadChecksum
+	}
+
+	var err error
+
+	switch compression {
+	case compressionNone:
+	case compressionSnappy:
+		data, err = snappyDecode(data)
+	case compressionZlib:
+		var zr io.ReadCloser
+
+		if zr, err = zlib.NewReader(bytes.NewReader(data)); err == nil {
+			data, err = io.ReadAll(zr)
+		}
+	case compressionZlibRaw:
+		data, err = io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	default:
+		return nil, UnsupportedCompression{compression}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := decodeBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if len(e.key) < 8 {
+			return nil, ErrCorrupt
+		}
+	}
+
+	return entries, nil
+}
+
+func (t *table) close() error {
+	return t.f.Close()
+}
+
+// tableIterator iterates over all of the entries in a table, in order.
+type tableIterator struct {
+	t       *table
+	block   int
+	entries []keyValue
+	pos     int
+	err     error
+}
+
+func (t *table) iterator() *tableIterator {
+	return &tableIterator{t: t, block: -1}
+}
+
+func (i *tableIterator) loadBlock(n int) bool {
+	i.block = n
+	i.pos = 0
+	i.entries = nil
+
+	if n >= len(i.t.index) {
+		return false
+	}
+
+	h, m := decodeBlockHandle(i.t.index[n].value)
+	if m == 0 {
+		i.err = ErrCorrupt
+
+		return false
+	}
+
+	i.entries, i.err = i.t.block(h)
+
+	return i.err == nil
+}
+
+// seek positions the iterator at the first entry with an internal key that
+// is not less than the given key.
+func (i *tableIterator) seek(ikey []byte) bool {
+	n := sort.Search(len(i.t.index), func(n int) bool {
+		return compareInternal(i.t.index[n].key, ikey) >= 0
+	})
+
+	if !i.loadBlock(n) {
+		return false
+	}
+
+	i.pos = sort.Search(len(i.entries), func(n int) bool {
+		return compareInternal(i.entries[n].key, ikey) >= 0
+	})
+
+	return i.valid()
+}
+
+// next advances the iterator, returning false when there are no more
+// entries or an error has occurred.
+func (i *tableIterator) next() bool {
+	if i.block < 0 {
+		if !i.loadBlock(0) {
+			return false
+		}
+	} else {
+		i.pos++
+	}
+
+	return i.valid()
+}
+
+func (i *tableIterator) valid() bool {
+	for i.pos >= len(i.entries) {
+		if i.err != nil || !i.loadBlock(i.block+1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *tableIterator) entry() keyValue {
+	return i.entries[i.pos]
+}
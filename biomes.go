@@ -223,3 +223,109 @@ func (b Biome) String() string {
 
 	return "Unrecognised Biome ID - " + string(digits)
 }
+
+// biomeNames contains, for each biome ID, the name used before Minecraft 1.18
+// and the name written to chunks saved in the 1.18 format. Biomes removed in
+// 1.18 are written as the biome that replaced them.
+var biomeNames = [...]struct {
+	biome             Biome
+	legacy, container string
+}{
+	{Ocean, "ocean", "ocean"},
+	{Plains, "plains", "plains"},
+	{Desert, "desert", "desert"},
+	{ExtremeHills, "mountains", "windswept_hills"},
+	{Forest, "forest", "forest"},
+	{Taiga, "taiga", "taiga"},
+	{Swampland, "swamp", "swamp"},
+	{River, "river", "river"},
+	{Hell, "nether", "nether_wastes"},
+	{Sky, "the_end", "the_end"},
+	{FrozenOcean, "frozen_ocean", "frozen_ocean"},
+	{FrozenRiver, "frozen_river", "frozen_river"},
+	{IcePlains, "snowy_tundra", "snowy_plains"},
+	{IceMountains, "snowy_mountains", "snowy_plains"},
+	{MushroomIsland, "mushroom_fields", "mushroom_fields"},
+	{MushroomIslandShore, "mushroom_field_shore", "mushroom_fields"},
+	{Beach, "beach", "beach"},
+	{DesertHills, "desert_hills", "desert"},
+	{ForestHills, "wooded_hills", "forest"},
+	{TaigaHills, "taiga_hills", "taiga"},
+	{ExtremeHillsEdge, "mountain_edge", "windswept_hills"},
+	{Jungle, "jungle", "jungle"},
+	{JungleHills, "jungle_hills", "jungle"},
+	{JungleEdge, "jungle_edge", "sparse_jungle"},
+	{DeepOcean, "deep_ocean", "deep_ocean"},
+	{StoneBeach, "stone_shore", "stony_shore"},
+	{ColdBeach, "snowy_beach", "snowy_beach"},
+	{BirchForest, "birch_forest", "birch_forest"},
+	{BirchForestHills, "birch_forest_hills", "birch_forest"},
+	{RoofedForest, "dark_forest", "dark_forest"},
+	{ColdTaiga, "snowy_taiga", "snowy_taiga"},
+	{ColdTaigaHills, "snowy_taiga_hills", "snowy_taiga"},
+	{MegaTaiga, "giant_tree_taiga", "old_growth_pine_taiga"},
+	{MegaTaigaHills, "giant_tree_taiga_hills", "old_growth_pine_taiga"},
+	{ExtremeHillsPlus, "wooded_mountains", "windswept_forest"},
+	{Savanna, "savanna", "savanna"},
+	{SavannaPlateau, "savanna_plateau", "savanna_plateau"},
+	{Mesa, "badlands", "badlands"},
+	{MesaPlateauF, "wooded_badlands_plateau", "wooded_badlands"},
+	{MesaPlateau, "badlands_plateau", "badlands"},
+	{40, "small_end_islands", "small_end_islands"},
+	{41, "end_midlands", "end_midlands"},
+	{42, "end_highlands", "end_highlands"},
+	{43, "end_barrens", "end_barrens"},
+	{44, "warm_ocean", "warm_ocean"},
+	{45, "lukewarm_ocean", "lukewarm_ocean"},
+	{46, "cold_ocean", "cold_ocean"},
+	{47, "deep_warm_ocean", "warm_ocean"},
+	{48, "deep_lukewarm_ocean", "deep_lukewarm_ocean"},
+	{49, "deep_cold_ocean", "deep_cold_ocean"},
+	{50, "deep_frozen_ocean", "deep_frozen_ocean"},
+	{127, "the_void", "the_void"},
+	{SunflowerPlains, "sunflower_plains", "sunflower_plains"},
+	{DeserM, "desert_lakes", "desert"},
+	{ExtremeHillsM, "gravelly_mountains", "windswept_gravelly_hills"},
+	{FlowerForest, "flower_forest", "flower_forest"},
+	{TaigaM, "taiga_mountains", "taiga"},
+	{SwamplandM, "swamp_hills", "swamp"},
+	{IcePlainsSpikes, "ice_spikes", "ice_spikes"},
+	{JungleM, "modified_jungle", "jungle"},
+	{JungleEdgeM, "modified_jungle_edge", "sparse_jungle"},
+	{BirchForestM, "tall_birch_forest", "old_growth_birch_forest"},
+	{BirchForestHillsM, "tall_birch_hills", "old_growth_birch_forest"},
+	{RoofedForestM, "dark_forest_hills", "dark_forest"},
+	{ColdTaigaM, "snowy_taiga_mountains", "snowy_taiga"},
+	{MegaSpruceTaiga, "giant_spruce_taiga", "old_growth_spruce_taiga"},
+	{MegaSpruceTaigaHills, "giant_spruce_taiga_hills", "old_growth_spruce_taiga"},
+	{ExtremeHillsPlusM, "modified_gravelly_mountains", "windswept_gravelly_hills"},
+	{SavannaM, "shattered_savanna", "windswept_savanna"},
+	{SavannaPlateauM, "shattered_savanna_plateau", "savanna_plateau"},
+	{MesaBryce, "eroded_badlands", "eroded_badlands"},
+	{MesaPlateauFM, "modified_wooded_badlands_plateau", "wooded_badlands"},
+	{MesaPlateauM, "modified_badlands_plateau", "badlands"},
+	{168, "bamboo_jungle", "bamboo_jungle"},
+	{169, "bamboo_jungle_hills", "bamboo_jungle"},
+	{170, "soul_sand_valley", "soul_sand_valley"},
+	{171, "crimson_forest", "crimson_forest"},
+	{172, "warped_forest", "warped_forest"},
+	{173, "basalt_deltas", "basalt_deltas"},
+}
+
+var (
+	biomeByName = make(map[string]Biome)
+	biomeToName = make(map[Biome]string)
+)
+
+func init() {
+	for _, b := range biomeNames {
+		biomeByName["minecraft:"+b.legacy] = b.biome
+		biomeToName[b.biome] = "minecraft:" + b.container
+	}
+
+	for _, b := range biomeNames {
+		if _, ok := biomeByName["minecraft:"+b.container]; !ok {
+			biomeByName["minecraft:"+b.container] = b.biome
+		}
+	}
+}
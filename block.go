@@ -14,11 +14,47 @@ type Tick struct {
 
 // Block is a type that represents the full information for a block, id, data,
 // metadata and scheduled tick data.
+//
+// A Block read from a block state that has no mapping to an ID and Data is
+// unmapped: it has an ID and Data of zero and keeps its block state, which can
+// be retrieved with the Unmapped method. An unmapped Block can be written to
+// any paletted chunk, where it is stored by its block state.
 type Block struct {
 	ID       uint16
 	Data     uint8
 	metadata nbt.Compound
 	ticks    []Tick
+	state    *unmappedState
+}
+
+// unmappedState is the block state of an unmapped Block, as the palette entry
+// it was read from, so that the entry can be written back unchanged.
+type unmappedState struct {
+	key   string
+	entry nbt.Compound
+}
+
+// UnmappedBlock returns an unmapped Block for the given block state.
+func UnmappedBlock(state BlockState) Block {
+	return Block{state: &unmappedState{key: state.String(), entry: stateToCompound(state)}}
+}
+
+// Unmapped returns the block state of an unmapped Block, and true, or false if
+// the Block is not unmapped.
+func (b Block) Unmapped() (BlockState, bool) {
+	if b.state == nil {
+		return BlockState{}, false
+	}
+
+	return stateFromCompound(b.state.entry), true
+}
+
+func (b Block) stateKey() string {
+	if b.state == nil {
+		return ""
+	}
+
+	return b.state.key
 }
 
 // Equal is an implementation of the equaler.Equaler interface.
@@ -38,7 +74,7 @@ func (b Block) Equal(e any) bool {
 
 // EqualBlock checks for equality between the two blocks.
 func (b Block) EqualBlock(c Block) bool {
-	if b.ID == c.ID && b.Data == c.Data && len(b.metadata) == len(c.metadata) && len(b.ticks) == len(c.ticks) {
+	if b.ID == c.ID && b.Data == c.Data && b.stateKey() == c.stateKey() && len(b.metadata) == len(c.metadata) && len(b.ticks) == len(c.ticks) {
 		for _, bT := range b.ticks {
 			found := false
 
@@ -83,8 +119,12 @@ func (b Block) EqualBlock(c Block) bool {
 }
 
 // Opacity returns how much light is blocked by this block..
+//
+// Unmapped blocks are treated as opaque.
 func (b Block) Opacity() uint8 {
-	if b.ID == 8 || b.ID == 9 {
+	if b.state != nil {
+		return 15
+	} else if b.ID == 8 || b.ID == 9 {
 		return 3
 	}
 
@@ -177,6 +217,10 @@ func (b Block) String() string {
 	var toRet strings.Builder
 	toRet.WriteString("Block ID: " + strconv.FormatUint(uint64(b.ID), 10) + "\nData: " + strconv.FormatUint(uint64(b.Data), 10) + "\n")
 
+	if b.state != nil {
+		toRet.WriteString("Unmapped State: " + b.state.key + "\n")
+	}
+
 	if b.metadata != nil && len(b.metadata) != 0 {
 		toRet.WriteString("Metadata: " + b.metadata.String())
 	}
@@ -0,0 +1,194 @@
+package minecraft
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BlockState is a namespaced block name along with its properties, as used by
+// the block palettes of modern formats.
+type BlockState struct {
+	Name       string
+	Properties map[string]string
+}
+
+// ParseBlockState parses a block state string, such as
+// "minecraft:oak_stairs[facing=east,half=top]". A name without a namespace is
+// given the minecraft namespace.
+func ParseBlockState(s string) (BlockState, error) {
+	name, props := s, ""
+
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		if !strings.HasSuffix(s, "]") {
+			return BlockState{}, InvalidBlockState{s}
+		}
+
+		name, props = s[:i], s[i+1:len(s)-1]
+	}
+
+	if name == "" || strings.ContainsAny(name, "[]=,") {
+		return BlockState{}, InvalidBlockState{s}
+	} else if !strings.ContainsRune(name, ':') {
+		name = "minecraft:" + name
+	}
+
+	b := BlockState{Name: name}
+
+	if props != "" {
+		b.Properties = make(map[string]string)
+
+		for _, prop := range strings.Split(props, ",") {
+			kv := strings.SplitN(prop, "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return BlockState{}, InvalidBlockState{s}
+			}
+
+			b.Properties[kv[0]] = kv[1]
+		}
+	}
+
+	return b, nil
+}
+
+// PropertyNames returns the names of the properties of the block state,
+// sorted, so that they can be written in a consistent order.
+func (b BlockState) PropertyNames() []string {
+	keys := make([]string, 0, len(b.Properties))
+
+	for k := range b.Properties {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// String returns the block state in its string form, with the properties
+// sorted by name.
+func (b BlockState) String() string {
+	if len(b.Properties) == 0 {
+		return b.Name
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(b.Name)
+
+	for n, k := range b.PropertyNames() {
+		if n == 0 {
+			sb.WriteByte('[')
+		} else {
+			sb.WriteByte(',')
+		}
+
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(b.Properties[k])
+	}
+
+	sb.WriteByte(']')
+
+	return sb.String()
+}
+
+// BlockMapper converts between block states and the numeric ID/Data model
+// used by Block.
+type BlockMapper interface {
+	// BlockFromState returns the Block for the given block state, and
+	// whether a mapping exists.
+	BlockFromState(BlockState) (Block, bool)
+	// StateFromBlock returns the block state for the ID and Data of the
+	// given Block, and whether a mapping exists.
+	StateFromBlock(Block) (BlockState, bool)
+}
+
+// StateOf returns the block state of the given Block, which is its own block
+// state for an unmapped Block, or else the block state given by the
+// BlockMapper.
+func StateOf(m BlockMapper, b Block) (BlockState, bool) {
+	if state, ok := b.Unmapped(); ok {
+		return state, true
+	}
+
+	return m.StateFromBlock(b)
+}
+
+// BlockOf returns the Block for the given block state, as given by the
+// BlockMapper, or an unmapped Block if the BlockMapper has no mapping for it.
+func BlockOf(m BlockMapper, state BlockState) Block {
+	if b, ok := m.BlockFromState(state); ok {
+		return b
+	}
+
+	return UnmappedBlock(state)
+}
+
+// BlockStateTable is a BlockMapper backed by lookup tables.
+//
+// A BlockStateTable is safe for concurrent use.
+type BlockStateTable struct {
+	mu      sync.RWMutex
+	toBlock map[string]Block
+	toState map[uint32]BlockState
+}
+
+// NewBlockStateTable creates a new, empty, BlockStateTable.
+func NewBlockStateTable() *BlockStateTable {
+	return &BlockStateTable{
+		toBlock: make(map[string]Block),
+		toState: make(map[uint32]BlockState),
+	}
+}
+
+// Add adds a mapping between the given block state and block. The first
+// block state added for a block is the one returned by StateFromBlock, while
+// any number of block states can map to the same block. Adding a block state
+// that is already mapped replaces its mapping.
+func (t *BlockStateTable) Add(state BlockState, block Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.toBlock[state.String()] = Block{ID: block.ID, Data: block.Data}
+
+	if key := uint32(block.ID)<<8 | uint32(block.Data); t.toState[key].Name == "" {
+		t.toState[key] = state
+	}
+}
+
+// BlockFromState returns the block mapped to the given block state. If there
+// is no exact match, the mapping of the block name without properties is
+// used, if it exists.
+func (t *BlockStateTable) BlockFromState(state BlockState) (Block, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if b, ok := t.toBlock[state.String()]; ok {
+		return b, true
+	}
+
+	b, ok := t.toBlock[state.Name]
+
+	return b, ok
+}
+
+// StateFromBlock returns the block state mapped to the ID and Data of the
+// given block.
+func (t *BlockStateTable) StateFromBlock(block Block) (BlockState, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	state, ok := t.toState[uint32(block.ID)<<8|uint32(block.Data)]
+	if !ok {
+		return BlockState{}, false
+	}
+
+	props := make(map[string]string, len(state.Properties))
+
+	for k, v := range state.Properties {
+		props[k] = v
+	}
+
+	return BlockState{Name: state.Name, Properties: props}, true
+}
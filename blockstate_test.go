@@ -0,0 +1,105 @@
+package minecraft
+
+import "testing"
+
+func TestParseBlockState(t *testing.T) {
+	for n, test := range [...]struct {
+		input, output string
+		err           error
+	}{
+		{"stone", "minecraft:stone", nil},
+		{"minecraft:oak_stairs[half=top,facing=east]", "minecraft:oak_stairs[facing=east,half=top]", nil},
+		{"mod:machine[on=true]", "mod:machine[on=true]", nil},
+		{"", "", InvalidBlockState{""}},
+		{"stone[", "", InvalidBlockState{"stone["}},
+		{"stone[a]", "", InvalidBlockState{"stone[a]"}},
+		{"stone[a=]", "", InvalidBlockState{"stone[a=]"}},
+		{"[a=b]", "", InvalidBlockState{"[a=b]"}},
+	} {
+		state, err := ParseBlockState(test.input)
+		if err != test.err {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.err, err)
+		} else if err == nil && state.String() != test.output {
+			t.Errorf("test %d: expecting %q, got %q", n+1, test.output, state.String())
+		}
+	}
+}
+
+func TestBlockStateTable(t *testing.T) {
+	chest, _ := ParseBlockState("chest[waterlogged=false,facing=west,type=single]")
+
+	if b, ok := DefaultBlockStates.BlockFromState(chest); !ok || b.ID != 54 || b.Data != 4 {
+		t.Errorf("expecting chest 54:4, got %d:%d (%v)", b.ID, b.Data, ok)
+	}
+
+	water, _ := ParseBlockState("water[level=0]")
+
+	if b, ok := DefaultBlockStates.BlockFromState(water); !ok || b.ID != 9 {
+		t.Errorf("expecting still water, got %d (%v)", b.ID, ok)
+	}
+
+	if s, ok := DefaultBlockStates.StateFromBlock(Block{ID: 8}); !ok || s.String() != "minecraft:water[level=0]" {
+		t.Errorf("expecting water state, got %s (%v)", s, ok)
+	}
+
+	// Unknown properties fall back to the bare block name.
+	wool, _ := ParseBlockState("red_wool[custom=1]")
+
+	if b, ok := DefaultBlockStates.BlockFromState(wool); !ok || b.ID != 35 || b.Data != 14 {
+		t.Errorf("expecting red wool, got %d:%d (%v)", b.ID, b.Data, ok)
+	}
+
+	if _, ok := DefaultBlockStates.StateFromBlock(Block{ID: 4000}); ok {
+		t.Error("expecting no state for unknown block")
+	}
+
+	table := NewBlockStateTable()
+	custom := BlockState{Name: "mod:machine", Properties: map[string]string{"on": "true"}}
+
+	table.Add(custom, Block{ID: 500, Data: 1})
+
+	if s, ok := table.StateFromBlock(Block{ID: 500, Data: 1}); !ok || s.String() != custom.String() {
+		t.Errorf("expecting %s, got %s (%v)", custom, s, ok)
+	} else if s.Properties["on"] = "false"; custom.Properties["on"] != "true" {
+		t.Error("returned state shares properties with table")
+	}
+}
+
+func TestDefaultBlockStates(t *testing.T) {
+	for n, test := range [...]struct {
+		State string
+		ID    uint16
+		Data  uint8
+	}{
+		{"oak_stairs[facing=west,half=top,shape=inner_left,waterlogged=true]", 53, 5},
+		{"oak_fence[east=true,north=false,south=true,waterlogged=false,west=false]", 85, 0},
+		{"oak_leaves[distance=3,persistent=false,waterlogged=false]", 18, 0},
+		{"redstone_wire[east=side,north=none,power=5,south=up,west=none]", 55, 5},
+		{"rail[shape=north_east,waterlogged=false]", 66, 9},
+		{"oak_door[facing=north,half=upper,hinge=right,open=true,powered=false]", 64, 9},
+		{"cobblestone_wall[east=low,north=none,south=tall,up=true,waterlogged=false,west=none]", 139, 0},
+	} {
+		state, err := ParseBlockState(test.State)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if b, ok := DefaultBlockStates.BlockFromState(state); !ok || b.ID != test.ID || b.Data != test.Data {
+			t.Errorf("test %d: expecting %d:%d, got %d:%d (%v)", n+1, test.ID, test.Data, b.ID, b.Data, ok)
+		}
+	}
+
+	unmapped := BlockState{Name: "mod:machine", Properties: map[string]string{"on": "true"}}
+
+	b := BlockOf(DefaultBlockStates, unmapped)
+
+	if s, ok := b.Unmapped(); !ok || s.String() != unmapped.String() {
+		t.Errorf("expecting unmapped %s, got %s (%v)", unmapped, s, ok)
+	} else if s, ok = StateOf(DefaultBlockStates, b); !ok || s.String() != unmapped.String() {
+		t.Errorf("expecting state %s, got %s (%v)", unmapped, s, ok)
+	}
+
+	if s, ok := StateOf(DefaultBlockStates, Block{ID: 54, Data: 4}); !ok || s.Name != "minecraft:chest" || s.Properties["facing"] != "west" {
+		t.Errorf("expecting west facing chest, got %s (%v)", s, ok)
+	}
+}
@@ -0,0 +1,408 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// RepairAction describes what was done to a chunk during a repair.
+type RepairAction uint8
+
+// Repair actions.
+const (
+	NoAction RepairAction = iota
+	Dropped
+	Relocated
+)
+
+func (r RepairAction) String() string {
+	switch r {
+	case Dropped:
+		return "dropped"
+	case Relocated:
+		return "relocated"
+	}
+
+	return "none"
+}
+
+// ChunkProblem describes an issue discovered with a chunk entry in a region
+// file.
+type ChunkProblem struct {
+	X, Z   int32
+	Err    error
+	Action RepairAction
+}
+
+// RegionReport contains all of the problems found in a single region file.
+type RegionReport struct {
+	X, Z     int32
+	Problems []ChunkProblem
+}
+
+type checkedChunk struct {
+	slot              int
+	offset, count     uint32
+	timestamp         uint32
+	data              []byte
+	compression       byte
+	x, z              int32
+	err               error
+	overlap, decoded  bool
+	external, invalid bool
+	action            RepairAction
+	origX, origZ      int32
+}
+
+type regionCheck struct {
+	x, z   int32
+	chunks []*checkedChunk
+}
+
+// Check examines the region file at region coords x, z and returns a list of
+// all of the problems found.
+//
+// It detects chunks with overlapping sectors, sectors beyond the end of the
+// file, zero-length chunks, unknown compression types, chunks that cannot be
+// decoded and chunks whose xPos/zPos do not match the location in the region.
+func (p *FilePath) Check(x, z int32) ([]ChunkProblem, error) {
+	if !p.canRead() {
+		return nil, ErrNoLock
+	}
+
+	l := p.regionLock(StorageRegion, x, z)
+
+	l.RLock()
+	defer l.RUnlock()
+
+	rc, err := p.checkRegion(x, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.problems(), nil
+}
+
+// CheckDimension runs Check on all regions of the current dimension, returning
+// a report for each region that has problems.
+func (p *FilePath) CheckDimension() ([]RegionReport, error) {
+	return p.eachRegion(p.Check)
+}
+
+// Repair runs the checks performed by Check and then rewrites the region file,
+// dropping any chunks that cannot be read, relocating any overlapping chunks
+// that can be read and moving chunks with valid data in the wrong place in the
+// region to the correct location, if it is free. Chunks in the wrong place that
+// cannot be moved are dropped.
+//
+// The returned list of problems contains the action taken for each chunk.
+func (p *FilePath) Repair(x, z int32) ([]ChunkProblem, error) {
+	if err := p.canWrite(); err != nil {
+		return nil, err
+	}
+
+	l := p.regionLock(StorageRegion, x, z)
+
+	l.Lock()
+	defer l.Unlock()
+
+	rc, err := p.checkRegion(x, z)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rc.problems()) == 0 {
+		return nil, nil
+	}
+
+	var slots [1024]*checkedChunk
+
+	for _, c := range rc.chunks {
+		if c.err != nil {
+			c.action = Dropped
+		}
+
+		if c.decoded && !c.invalid {
+			slots[c.slot] = c
+
+			if c.overlap {
+				c.action = Relocated
+			}
+		}
+	}
+
+	var moved []*checkedChunk
+
+	for _, c := range rc.chunks {
+		if !c.decoded || !c.invalid {
+			continue
+		}
+
+		slot := int(c.z&31)<<5 | int(c.x&31)
+
+		if c.x>>5 != x || c.z>>5 != z || slots[slot] != nil {
+			c.action = Dropped
+
+			continue
+		}
+
+		slots[slot] = c
+		c.action = Relocated
+		moved = append(moved, c)
+	}
+
+	for _, c := range rc.chunks {
+		if c.external && c.action == Dropped {
+			cx, cz := rc.slotCoords(c.slot)
+
+			os.Remove(p.getExternalChunkPath(StorageRegion, cx, cz))
+		}
+	}
+
+	for _, c := range moved {
+		if c.external {
+			ox, oz := rc.slotCoords(c.slot)
+
+			if err := os.Rename(p.getExternalChunkPath(StorageRegion, ox, oz), p.getExternalChunkPath(StorageRegion, c.x, c.z)); err != nil {
+				return nil, err
+			}
+		}
+
+		c.slot = int(c.z&31)<<5 | int(c.x&31)
+	}
+
+	p.closeRegion(StorageRegion, x, z)
+
+	if err := p.writeRepairedRegion(x, z, &slots); err != nil {
+		return nil, err
+	}
+
+	return rc.problems(), nil
+}
+
+// RepairDimension runs Repair on all regions of the current dimension,
+// returning a report for each region that had problems.
+func (p *FilePath) RepairDimension() ([]RegionReport, error) {
+	return p.eachRegion(p.Repair)
+}
+
+func (p *FilePath) eachRegion(fn func(int32, int32) ([]ChunkProblem, error)) ([]RegionReport, error) {
+	var reports []RegionReport
+
+	for _, r := range p.GetRegions() {
+		problems, err := fn(r[0], r[1])
+		if err != nil {
+			return reports, err
+		}
+
+		if len(problems) > 0 {
+			reports = append(reports, RegionReport{X: r[0], Z: r[1], Problems: problems})
+		}
+	}
+
+	return reports, nil
+}
+
+func (p *FilePath) checkRegion(x, z int32) (*regionCheck, error) {
+	// Opening the region replays any outstanding journal.
+	r, err := p.getRegion(StorageRegion, x, z, false)
+	if err != nil {
+		return nil, err
+	}
+
+	p.releaseRegion(r)
+
+	data, err := os.ReadFile(p.getRegionPath(StorageRegion, x, z))
+	if err != nil {
+		return nil, err
+	} else if len(data) < 4096 {
+		return nil, ErrRegionHeader
+	}
+
+	rc := &regionCheck{x: x, z: z}
+
+	for i := 0; i < 1024; i++ {
+		loc := binary.BigEndian.Uint32(data[i<<2:])
+		if loc == 0 {
+			continue
+		}
+
+		c := &checkedChunk{
+			slot:   i,
+			offset: loc >> 8,
+			count:  loc & 255,
+		}
+
+		c.x, c.z = rc.slotCoords(i)
+		c.origX, c.origZ = c.x, c.z
+
+		if len(data) >= 8192 {
+			c.timestamp = binary.BigEndian.Uint32(data[4096+i<<2:])
+		}
+
+		rc.chunks = append(rc.chunks, c)
+
+		start := int64(c.offset) << 12
+
+		switch {
+		case c.count == 0:
+			c.err = ErrZeroLengthChunk
+		case c.offset < 2:
+			c.err = ErrOverlappingSectors
+		case start+5 > int64(len(data)):
+			c.err = ErrSectorsPastEOF
+		}
+
+		if c.err != nil {
+			continue
+		}
+
+		length := int64(binary.BigEndian.Uint32(data[start:]))
+
+		switch {
+		case length == 0:
+			c.err = ErrZeroLengthChunk
+		case length+4 > int64(c.count)<<12:
+			c.err = ErrInvalidChunkLength
+		case start+4+length > int64(len(data)):
+			c.err = ErrSectorsPastEOF
+		}
+
+		if c.err != nil {
+			continue
+		}
+
+		c.data = data[start : start+4+length]
+		c.compression = data[start+4]
+		c.external = c.compression&externalChunk != 0
+	}
+
+	rc.checkOverlaps()
+
+	for _, c := range rc.chunks {
+		if c.data != nil {
+			p.checkChunk(c)
+		}
+	}
+
+	return rc, nil
+}
+
+func (rc *regionCheck) checkOverlaps() {
+	var valid []*checkedChunk
+
+	for _, c := range rc.chunks {
+		if c.err == nil {
+			valid = append(valid, c)
+		}
+	}
+
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].offset < valid[j].offset
+	})
+
+	var end uint32
+
+	for n, c := range valid {
+		if n > 0 && c.offset < end {
+			c.overlap = true
+
+			for m := n - 1; m >= 0 && valid[m].offset+valid[m].count > c.offset; m-- {
+				valid[m].overlap = true
+			}
+		}
+
+		if e := c.offset + c.count; e > end {
+			end = e
+		}
+	}
+}
+
+func (p *FilePath) checkChunk(c *checkedChunk) {
+	compression := c.compression &^ externalChunk
+
+	var reader io.Reader = bytes.NewReader(c.data[5:])
+
+	if c.external {
+		f, err := os.Open(p.getExternalChunkPath(StorageRegion, c.x, c.z))
+		if err != nil {
+			c.err = err
+
+			return
+		}
+
+		defer f.Close()
+
+		reader = f
+	}
+
+	dReader, err := decompressChunk(compression, reader)
+	if err != nil {
+		c.err = err
+
+		return
+	}
+
+	defer dReader.Close()
+
+	tag, err := nbt.Decode(dReader)
+	if err != nil {
+		c.err = err
+
+		return
+	}
+
+	c.decoded = true
+
+	x, z, err := chunkCoords(tag)
+	if err != nil {
+		c.err = err
+		c.decoded = false
+	} else if x != c.x || z != c.z {
+		c.err = UnexpectedValue{"[Chunk Base]->Level->[xz]Pos", formatCoords(c.x, c.z), formatCoords(x, z)}
+		c.x, c.z = x, z
+		c.invalid = true
+	} else if c.overlap {
+		c.err = ErrOverlappingSectors
+	}
+}
+
+func (rc *regionCheck) slotCoords(slot int) (int32, int32) {
+	return rc.x<<5 | int32(slot&31), rc.z<<5 | int32(slot>>5)
+}
+
+func (rc *regionCheck) problems() []ChunkProblem {
+	var problems []ChunkProblem
+
+	for _, c := range rc.chunks {
+		if c.err != nil {
+			problems = append(problems, ChunkProblem{X: c.origX, Z: c.origZ, Err: c.err, Action: c.action})
+		}
+	}
+
+	return problems
+}
+
+func formatCoords(x, z int32) string {
+	return strconv.FormatInt(int64(x), 10) + "," + strconv.FormatInt(int64(z), 10)
+}
+
+func (p *FilePath) writeRepairedRegion(x, z int32, slots *[1024]*checkedChunk) error {
+	var entries [1024]regionEntry
+
+	for n, c := range slots {
+		if c != nil {
+			entries[n] = regionEntry{data: c.data, timestamp: c.timestamp}
+		}
+	}
+
+	_, err := writeRegionFile(p.getRegionPath(StorageRegion, x, z), &entries)
+
+	return err
+}
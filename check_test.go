@@ -0,0 +1,183 @@
+package minecraft
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestFilePathCheckRepair(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = f.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1), addPos(2, 0, 0), addPos(3, 0, 0), addPos(4, 0, 0), addPos(5, 0, 0), addPos(7, 0, 0)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if problems, err := f.Check(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if len(problems) != 0 {
+		t.Fatalf("expecting no problems, got %v", problems)
+	}
+
+	regionFile := path.Join(tempDir, "region", "r.0.0.mca")
+
+	region, err := os.ReadFile(regionFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	header := func(slot int) uint32 {
+		return binary.BigEndian.Uint32(region[slot<<2:])
+	}
+	setHeader := func(slot int, loc uint32) {
+		binary.BigEndian.PutUint32(region[slot<<2:], loc)
+	}
+
+	setHeader(0, header(0)+1)                                // overlaps the next chunk
+	setHeader(2, uint32(len(region)>>12+10)<<8|1)            // past EOF
+	region[header(3)>>8<<12+4] = 9                           // bad compression
+	setHeader(4, header(4)&^255)                             // zero sectors
+	binary.BigEndian.PutUint32(region[header(5)>>8<<12:], 0) // zero length
+	setHeader(8, header(7))                                  // wrong slot
+	setHeader(7, 0)
+
+	if err = os.WriteFile(regionFile, region, 0o666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []ChunkProblem{
+		{X: 0, Z: 0, Err: ErrOverlappingSectors, Action: Relocated},
+		{X: 1, Z: 0, Err: ErrOverlappingSectors, Action: Relocated},
+		{X: 2, Z: 0, Err: ErrSectorsPastEOF, Action: Dropped},
+		{X: 3, Z: 0, Err: UnknownCompression{9}, Action: Dropped},
+		{X: 4, Z: 0, Err: ErrZeroLengthChunk, Action: Dropped},
+		{X: 5, Z: 0, Err: ErrZeroLengthChunk, Action: Dropped},
+		{X: 8, Z: 0, Err: UnexpectedValue{"[Chunk Base]->Level->[xz]Pos", "8,0", "7,0"}, Action: Relocated},
+	}
+
+	checkProblems := func(name string, problems []ChunkProblem, repaired bool) {
+		if len(problems) != len(expected) {
+			t.Fatalf("%s: expecting %d problems, got %d: %v", name, len(expected), len(problems), problems)
+		}
+
+		for n, e := range expected {
+			if !repaired {
+				e.Action = NoAction
+			}
+
+			if problems[n] != e {
+				t.Errorf("%s: problem %d: expecting %v, got %v", name, n+1, e, problems[n])
+			}
+		}
+	}
+
+	reports, err := f.CheckDimension()
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(reports) != 1 || reports[0].X != 0 || reports[0].Z != 0 {
+		t.Fatalf("expecting a single report for region 0,0, got %v", reports)
+	}
+
+	checkProblems("check", reports[0].Problems, false)
+
+	problems, err := f.Repair(0, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checkProblems("repair", problems, true)
+
+	if problems, err = f.Check(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if len(problems) != 0 {
+		t.Fatalf("expecting no problems after repair, got %v", problems)
+	}
+
+	for _, c := range [...]struct {
+		x        int32
+		exists   bool
+		chunkNum uint8
+	}{
+		{0, true, 0},
+		{1, true, 1},
+		{2, false, 0},
+		{3, false, 0},
+		{4, false, 0},
+		{5, false, 0},
+		{7, true, 0},
+		{8, false, 0},
+	} {
+		if chunk, err := f.GetChunk(c.x, 0); err != nil {
+			t.Errorf("chunk %d: unexpected error: %s", c.x, err)
+		} else if exists := chunk.TagID() != 0; exists != c.exists {
+			t.Errorf("chunk %d: expecting exists to be %v", c.x, c.exists)
+		} else if exists && !chunk.Equal(addPos(c.x, 0, c.chunkNum)) {
+			t.Errorf("chunk %d: chunk data does not match", c.x)
+		}
+	}
+}
+
+func TestFilePathRepairOccupied(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir, WriteCompression(Uncompressed))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	level := addPos(3, 0, 0).Data().(nbt.Compound).Get("Level").Data().(nbt.Compound)
+	level.Set(nbt.NewTag("Large", make(nbt.ByteArray, 1<<20)))
+
+	if err = f.SetChunk(addPos(1, 0, 1), nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", level)})); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	external := path.Join(tempDir, "region", "c.3.0.mcc")
+
+	file, err := os.Create(external)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	level.Set(nbt.NewTag("xPos", nbt.Int(1))) // belongs in the occupied slot
+
+	err = nbt.NewEncoder(file).Encode(nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", level)}))
+
+	file.Close()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := ChunkProblem{X: 3, Z: 0, Err: UnexpectedValue{"[Chunk Base]->Level->[xz]Pos", "3,0", "1,0"}, Action: Dropped}
+
+	if problems, err := f.Repair(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if len(problems) != 1 || problems[0] != expected {
+		t.Fatalf("expecting problem %v, got %v", expected, problems)
+	} else if problems, err = f.Check(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if len(problems) != 0 {
+		t.Fatalf("expecting no problems after repair, got %v", problems)
+	} else if _, err = os.Stat(external); !os.IsNotExist(err) {
+		t.Errorf("expecting external chunk file to be removed")
+	}
+
+	if chunk, err := f.GetChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(addPos(1, 0, 1)) {
+		t.Errorf("expecting chunk in occupied slot to be unchanged")
+	} else if chunk, err = f.GetChunk(3, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if chunk.TagID() != 0 {
+		t.Errorf("expecting dropped chunk to be removed")
+	}
+}
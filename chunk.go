@@ -32,22 +32,71 @@ var (
 	}
 )
 
+// Height limits of chunks. Chunks stored before 1.18 always use the legacy
+// limits, while 1.18 chunks default to the extended limits of the overworld.
+const (
+	legacyMinY     = 0
+	legacyHeight   = 256
+	extendedMinY   = -64
+	extendedHeight = 384
+)
+
 type chunk struct {
-	sections     [16]*section
-	biomes       nbt.ByteArray
-	data         nbt.Compound
-	heightMap    nbt.IntArray
-	tileEntities map[uint16]nbt.Compound
-	tileTicks    map[uint16][]nbt.Compound
+	minY, height  int32
+	sections      []*section
+	extraSections []nbt.Compound
+	format        blockFormat
+	mapper        BlockMapper
+	root          nbt.Compound
+	biomes        nbt.ByteArray
+	biomeInts     nbt.IntArray
+	dataVersion   int32
+	data          nbt.Compound
+	heightMap     nbt.IntArray
+	tileEntities  map[uint32]nbt.Compound
+	tileTicks     map[uint32][]nbt.Compound
+}
+
+// sectionsName returns the name of the sections list, which was lowercased in
+// the 1.18 format.
+func (c *chunk) sectionsName() string {
+	if c.format == containerBlocks {
+		return "sections"
+	}
+
+	return "Sections"
+}
+
+// tileEntitiesName returns the name of the tile entities list, which was
+// renamed in the 1.18 format.
+func (c *chunk) tileEntitiesName() string {
+	if c.format == containerBlocks {
+		return "block_entities"
+	}
+
+	return "TileEntities"
 }
 
 func (c *chunk) GetNBT() nbt.Tag {
 	data := c.data.Copy().(nbt.Compound)
-	sections := make([]nbt.Data, 0, 16)
+	sections := make([]nbt.Data, 0, len(c.sections)+len(c.extraSections))
+	minSection := nbt.Byte(c.minY >> 4)
+
+	for _, s := range c.extraSections {
+		if s.Get("Y").Data().(nbt.Byte) < minSection {
+			sections = append(sections, s)
+		}
+	}
+
+	for _, s := range c.sections {
+		if s != nil {
+			sections = append(sections, s.compound())
+		}
+	}
 
-	for i := 0; i < 16; i++ {
-		if c.sections[i] != nil {
-			sections = append(sections, c.sections[i].section)
+	for _, s := range c.extraSections {
+		if s.Get("Y").Data().(nbt.Byte) >= minSection {
+			sections = append(sections, s)
 		}
 	}
 
@@ -55,7 +104,7 @@ func (c *chunk) GetNBT() nbt.Tag {
 	tileEntities := make(nbt.ListCompound, 0, len(c.tileEntities))
 
 	sectionList.Append(sections...)
-	data.Set(nbt.NewTag("Sections", sectionList))
+	data.Set(nbt.NewTag(c.sectionsName(), sectionList))
 
 	for _, cmp := range c.tileEntities {
 		if cmp != nil {
@@ -63,23 +112,64 @@ func (c *chunk) GetNBT() nbt.Tag {
 		}
 	}
 
-	data.Set(nbt.NewTag("TileEntities", &tileEntities))
+	data.Set(nbt.NewTag(c.tileEntitiesName(), &tileEntities))
 
-	tileTicks := make(nbt.ListCompound, 0, len(c.tileTicks))
+	if c.format == legacyBlocks {
+		tileTicks := make(nbt.ListCompound, 0, len(c.tileTicks))
 
-	for _, cmpa := range c.tileTicks {
-		for _, cmp := range cmpa {
-			tileTicks = append(tileTicks, cmp)
+		for _, cmpa := range c.tileTicks {
+			for _, cmp := range cmpa {
+				tileTicks = append(tileTicks, cmp)
+			}
+		}
+
+		if len(tileTicks) == 0 {
+			data.Remove("TileTicks")
+		} else {
+			data.Set(nbt.NewTag("TileTicks", &tileTicks))
 		}
 	}
 
-	if len(tileTicks) == 0 {
-		data.Remove("TileTicks")
-	} else {
-		data.Set(nbt.NewTag("TileTicks", &tileTicks))
+	if c.format == containerBlocks {
+		return nbt.NewTag("", data)
 	}
 
-	return nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", data)})
+	root := append(nbt.Compound{}, c.root...)
+
+	root.Set(nbt.NewTag("Level", data))
+
+	return nbt.NewTag("", root)
+}
+
+// emptyChunk returns the NBT for a new chunk, with no blocks, in the format
+// used by the given data version.
+func emptyChunk(x, z, dataVersion int32) nbt.Tag {
+	switch formatFromDataVersion(dataVersion) {
+	case legacyBlocks:
+		return nbt.Tag{}
+	case containerBlocks:
+		return nbt.NewTag("", nbt.Compound{
+			nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+			nbt.NewTag("xPos", nbt.Int(x)),
+			nbt.NewTag("zPos", nbt.Int(z)),
+			nbt.NewTag("Status", nbt.String("full")),
+			nbt.NewTag("InhabitedTime", nbt.Long(0)),
+			nbt.NewTag("LastUpdate", nbt.Long(0)),
+			nbt.NewTag("sections", nbt.NewEmptyList(nbt.TagCompound)),
+		})
+	}
+
+	return nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+		nbt.NewTag("Level", nbt.Compound{
+			nbt.NewTag("xPos", nbt.Int(x)),
+			nbt.NewTag("zPos", nbt.Int(z)),
+			nbt.NewTag("Status", nbt.String("full")),
+			nbt.NewTag("InhabitedTime", nbt.Long(0)),
+			nbt.NewTag("LastUpdate", nbt.Long(0)),
+			nbt.NewTag("Sections", nbt.NewEmptyList(nbt.TagCompound)),
+		}),
+	})
 }
 
 func newChunk(x, z int32, data nbt.Tag) (*chunk, error) {
@@ -104,43 +194,98 @@ func newChunk(x, z int32, data nbt.Tag) (*chunk, error) {
 		})
 	}
 
-	c := new(chunk)
+	c := &chunk{
+		minY:   legacyMinY,
+		height: legacyHeight,
+		mapper: DefaultBlockStates,
+	}
 
 	if data.TagID() != nbt.TagCompound {
 		return nil, WrongTypeError{"[Chunk Base]", nbt.TagCompound, data.TagID()}
 	}
 
-	tag := data.Data().(nbt.Compound).Get("Level")
+	root := data.Data().(nbt.Compound)
 
-	if tag.TagID() == 0 {
-		return nil, MissingTagError{"[Chunk Base]->Level"}
+	if dv, ok := root.Get("DataVersion").Data().(nbt.Int); ok {
+		c.dataVersion = int32(dv)
+	}
+
+	base := "[Chunk Base]->Level"
+
+	if tag := root.Get("Level"); tag.TagID() == 0 {
+		if c.dataVersion < dataVersionContainer || root.Get("xPos").TagID() == 0 {
+			return nil, MissingTagError{"[Chunk Base]->Level"}
+		}
+
+		c.data = root
+		c.format = containerBlocks
+		c.minY = extendedMinY
+		c.height = extendedHeight
+		base = "[Chunk Base]"
 	} else if tag.TagID() != nbt.TagCompound {
 		return nil, WrongTypeError{"[Chunk Base]->Level", nbt.TagCompound, tag.TagID()}
+	} else {
+		c.data = tag.Data().(nbt.Compound)
+
+		for _, t := range root {
+			if t.Name() != "Level" {
+				c.root = append(c.root, t)
+			}
+		}
+
+		if c.format = formatFromDataVersion(c.dataVersion); c.format == containerBlocks {
+			c.format = paddedBlocks
+		}
 	}
 
-	c.data = tag.Data().(nbt.Compound)
+	if c.format == legacyBlocks {
+		for _, req := range chunkRequired {
+			if tag := c.data.Get(req.name); tag.TagID() == 0 {
+				return nil, MissingTagError{req.name}
+			} else if tagID := tag.TagID(); tagID != req.TagID {
+				return nil, WrongTypeError{req.name, req.TagID, tagID}
+			}
+		}
+	} else {
+		for _, name := range [...]string{"xPos", "zPos"} {
+			if tag := c.data.Get(name); tag.TagID() == 0 {
+				return nil, MissingTagError{name}
+			} else if tagID := tag.TagID(); tagID != nbt.TagInt {
+				return nil, WrongTypeError{name, nbt.TagInt, tagID}
+			}
+		}
 
-	for _, req := range chunkRequired {
-		if tag := c.data.Get(req.name); tag.TagID() == 0 {
-			return nil, MissingTagError{req.name}
-		} else if tagID := tag.TagID(); tagID != req.TagID {
-			return nil, WrongTypeError{req.name, req.TagID, tagID}
+		if tag := c.data.Get(c.sectionsName()); tag.TagID() == 0 {
+			c.data.Set(nbt.NewTag(c.sectionsName(), nbt.NewEmptyList(nbt.TagCompound)))
+		} else if tagID := tag.TagID(); tagID != nbt.TagList {
+			return nil, WrongTypeError{c.sectionsName(), nbt.TagList, tagID}
 		}
 	}
 
 	if tX := int32(c.data.Get("xPos").Data().(nbt.Int)); tX != x {
-		return nil, UnexpectedValue{"[Chunk Base]->Level->xPos", strconv.FormatInt(int64(x), 10), strconv.FormatInt(int64(tX), 10)}
+		return nil, UnexpectedValue{base + "->xPos", strconv.FormatInt(int64(x), 10), strconv.FormatInt(int64(tX), 10)}
 	}
 
 	if tZ := int32(c.data.Get("zPos").Data().(nbt.Int)); tZ != z {
-		return nil, UnexpectedValue{"[Chunk Base]->Level->zPos", strconv.FormatInt(int64(z), 10), strconv.FormatInt(int64(tZ), 10)}
+		return nil, UnexpectedValue{base + "->zPos", strconv.FormatInt(int64(z), 10), strconv.FormatInt(int64(tZ), 10)}
 	}
 
 	for _, co := range chunkOther {
-		if tag := c.data.Get(co.name); tag.TagID() == 0 {
+		name, tagType := co.name, co.tagType
+
+		switch {
+		case name == "TileEntities":
+			name = c.tileEntitiesName()
+		case name == "TileTicks" && c.format != legacyBlocks:
 			continue
-		} else if tagID := tag.TagID(); tagID != co.tagType {
-			return nil, WrongTypeError{co.name, co.tagType, tagID}
+		case name == "Biomes" && c.format != legacyBlocks:
+			tagType = nbt.TagIntArray
+		}
+
+		if tag := c.data.Get(name); tag.TagID() == 0 {
+			continue
+		} else if tagID := tag.TagID(); tagID != tagType {
+			return nil, WrongTypeError{name, tagType, tagID}
 		} else if tagID == nbt.TagList {
 			list := tag.Data().(nbt.List)
 
@@ -151,31 +296,41 @@ func newChunk(x, z int32, data nbt.Tag) (*chunk, error) {
 					}
 				}
 
-				return nil, WrongTypeError{co.name, co.listType, list.TagType()}
+				return nil, WrongTypeError{name, co.listType, list.TagType()}
 			}
 		}
 	}
 
-	if biomes := c.data.Get("Biomes"); biomes.TagID() != 0 {
-		c.biomes = biomes.Data().(nbt.ByteArray)
-	} else {
-		c.biomes = make(nbt.ByteArray, 256)
+	switch c.format {
+	case legacyBlocks:
+		if biomes := c.data.Get("Biomes"); biomes.TagID() != 0 {
+			c.biomes = biomes.Data().(nbt.ByteArray)
+		} else {
+			c.biomes = make(nbt.ByteArray, 256)
 
-		for i := 0; i < 256; i++ {
-			c.biomes[i] = -1
+			for i := 0; i < 256; i++ {
+				c.biomes[i] = -1
+			}
+
+			c.data.Set(nbt.NewTag("Biomes", c.biomes))
 		}
 
-		c.data.Set(nbt.NewTag("Biomes", c.biomes))
+		c.heightMap = c.data.Get("HeightMap").Data().(nbt.IntArray)
+	case packedBlocks, paddedBlocks:
+		if biomes := c.data.Get("Biomes"); biomes.TagID() != 0 {
+			if c.biomeInts = biomes.Data().(nbt.IntArray); len(c.biomeInts) != 256 && len(c.biomeInts) != 1024 {
+				return nil, ErrOOB
+			}
+		}
 	}
 
-	c.heightMap = c.data.Get("HeightMap").Data().(nbt.IntArray)
-	c.tileEntities = make(map[uint16]nbt.Compound)
+	c.tileEntities = make(map[uint32]nbt.Compound)
 
-	if tileEntities := c.data.Get("TileEntities"); tileEntities.TagID() != 0 {
+	if tileEntities := c.data.Get(c.tileEntitiesName()); tileEntities.TagID() != 0 {
 		if lTileEntities, ok := tileEntities.Data().(*nbt.ListCompound); ok {
 			for _, tag := range *lTileEntities {
 				if tag == nil {
-					return nil, MissingTagError{"TileEntities->Child"}
+					return nil, MissingTagError{c.tileEntitiesName() + "->Child"}
 				}
 
 				x, y, z, err := getCoords(tag)
@@ -188,71 +343,218 @@ func newChunk(x, z int32, data nbt.Tag) (*chunk, error) {
 		}
 	}
 
-	c.data.Remove("TileEntities")
+	c.data.Remove(c.tileEntitiesName())
 
-	c.tileTicks = make(map[uint16][]nbt.Compound)
+	c.tileTicks = make(map[uint32][]nbt.Compound)
 
-	if tileTicks := c.data.Get("TileTicks"); tileTicks.TagID() != 0 {
-		if lTileTicks, ok := tileTicks.Data().(*nbt.ListCompound); ok {
-			for _, tag := range *lTileTicks {
-				if tag == nil {
-					return nil, MissingTagError{"TileTicks->Child"}
-				}
+	if c.format == legacyBlocks {
+		if tileTicks := c.data.Get("TileTicks"); tileTicks.TagID() != 0 {
+			if lTileTicks, ok := tileTicks.Data().(*nbt.ListCompound); ok {
+				for _, tag := range *lTileTicks {
+					if tag == nil {
+						return nil, MissingTagError{"TileTicks->Child"}
+					}
 
-				x, y, z, err := getCoords(tag)
-				if err != nil {
-					return nil, err
-				} else if id := tag.Get("i"); id.TagID() == 0 {
-					return nil, MissingTagError{"TileTicks->Child->i"}
-				} else if j := id.TagID(); j != nbt.TagInt {
-					return nil, WrongTypeError{"TileTicks->Child->i", nbt.TagInt, j}
-				} else if t := tag.Get("t"); t.TagID() == 0 {
-					return nil, MissingTagError{"TileTicks->Child->t"}
-				} else if j := t.TagID(); j != nbt.TagInt {
-					return nil, WrongTypeError{"TileTicks->Child->t", nbt.TagInt, j}
-				} else if p := tag.Get("p"); p.TagID() == 0 {
-					return nil, MissingTagError{"TileTicks->Child->p"}
-				} else if j := p.TagID(); j != nbt.TagInt {
-					return nil, WrongTypeError{"TileTicks->Child->p", nbt.TagInt, j}
-				}
+					x, y, z, err := getCoords(tag)
+					if err != nil {
+						return nil, err
+					} else if id := tag.Get("i"); id.TagID() == 0 {
+						return nil, MissingTagError{"TileTicks->Child->i"}
+					} else if j := id.TagID(); j != nbt.TagInt {
+						return nil, WrongTypeError{"TileTicks->Child->i", nbt.TagInt, j}
+					} else if t := tag.Get("t"); t.TagID() == 0 {
+						return nil, MissingTagError{"TileTicks->Child->t"}
+					} else if j := t.TagID(); j != nbt.TagInt {
+						return nil, WrongTypeError{"TileTicks->Child->t", nbt.TagInt, j}
+					} else if p := tag.Get("p"); p.TagID() == 0 {
+						return nil, MissingTagError{"TileTicks->Child->p"}
+					} else if j := p.TagID(); j != nbt.TagInt {
+						return nil, WrongTypeError{"TileTicks->Child->p", nbt.TagInt, j}
+					}
 
-				pos := xyz(x, y, z)
-				c.tileTicks[pos] = append(c.tileTicks[pos], tag)
+					pos := xyz(x, y, z)
+					c.tileTicks[pos] = append(c.tileTicks[pos], tag)
+				}
 			}
 		}
+
+		c.data.Remove("TileTicks")
+	}
+
+	sections := c.data.Get(c.sectionsName()).Data().(nbt.List)
+
+	if sections.Len() > 0 && sections.TagType() != nbt.TagCompound {
+		return nil, WrongTypeError{c.sectionsName() + "->Child", nbt.TagCompound, sections.TagType()}
 	}
 
-	c.data.Remove("TileTicks")
+	c.sections = make([]*section, c.height>>4)
+
+	for i := 0; i < sections.Len(); i++ {
+		section := sections.Get(i).(nbt.Compound)
 
-	for _, section := range *(c.data.Get("Sections").Data().(*nbt.ListCompound)) {
 		if yc := section.Get("Y"); yc.TagID() == 0 {
-			return nil, MissingTagError{"Sections->Child->Y"}
+			return nil, MissingTagError{c.sectionsName() + "->Child->Y"}
 		} else if yc.TagID() != nbt.TagByte {
-			return nil, WrongTypeError{"Sections->Child->Y", nbt.TagByte, yc.TagID()}
+			return nil, WrongTypeError{c.sectionsName() + "->Child->Y", nbt.TagByte, yc.TagID()}
+		} else if y := int32(yc.Data().(nbt.Byte)) << 4; !c.inBounds(y) {
+			c.extraSections = append(c.extraSections, section)
 		} else {
-			y := int32(yc.Data().(nbt.Byte))
-
 			var err error
 
-			if c.sections[y], err = loadSection(section); err != nil {
+			if c.format == legacyBlocks {
+				c.sections[(y-c.minY)>>4], err = loadSection(section)
+			} else {
+				c.sections[(y-c.minY)>>4], err = loadPalettedSection(section, c.format, c.mapper)
+			}
+
+			if err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	c.data.Remove("Sections")
+	c.data.Remove(c.sectionsName())
+
+	if c.format != legacyBlocks {
+		c.calculateHeightMap()
+	}
 
 	return c, nil
 }
 
+// calculateHeightMap rebuilds the height map from the blocks of the chunk.
+func (c *chunk) calculateHeightMap() {
+	c.heightMap = make(nbt.IntArray, 256)
+
+	for x := int32(0); x < 16; x++ {
+		for z := int32(0); z < 16; z++ {
+			c.heightMap[x<<4|z] = c.columnHeight(x, c.minY+c.height-1, z)
+		}
+	}
+}
+
+// setHeightLimits sets the range of y coords that can hold blocks. Only
+// chunks stored in the 1.18 format can have their limits changed.
+//
+// Sections outside of the new limits are preserved, but cannot be accessed
+// until the limits include them again.
+func (c *chunk) setHeightLimits(minY, height int32) error {
+	if c.format != containerBlocks || minY == c.minY && height == c.height {
+		return nil
+	}
+
+	var (
+		sections = make([]*section, height>>4)
+		extra    []nbt.Compound
+	)
+
+	for n, s := range c.sections {
+		if s == nil {
+			continue
+		} else if i := n + int((c.minY-minY)>>4); i >= 0 && i < len(sections) {
+			sections[i] = s
+		} else {
+			extra = append(extra, s.compound())
+		}
+	}
+
+	for _, s := range c.extraSections {
+		if i := int32(s.Get("Y").Data().(nbt.Byte)) - minY>>4; i >= 0 && int(i) < len(sections) {
+			section, err := loadPalettedSection(s, c.format, c.mapper)
+			if err != nil {
+				return err
+			}
+
+			sections[i] = section
+		} else {
+			extra = append(extra, s)
+		}
+	}
+
+	c.minY = minY
+	c.height = height
+	c.sections = sections
+	c.extraSections = extra
+
+	c.calculateHeightMap()
+
+	return nil
+}
+
+// inBounds returns true if the given y coord is within the height limits of
+// the chunk.
+func (c *chunk) inBounds(y int32) bool {
+	return y >= c.minY && y < c.minY+c.height
+}
+
+// getSection returns the section containing the given y coord, or nil if the
+// section does not exist or is outside of the height limits of the chunk.
+func (c *chunk) getSection(y int32) *section {
+	if c.inBounds(y) {
+		return c.sections[(y-c.minY)>>4]
+	}
+
+	return nil
+}
+
+// missingSection returns true if the given y coord is within the height
+// limits of the chunk, but the section that would contain it does not exist.
+func (c *chunk) missingSection(y int32) bool {
+	return c.inBounds(y) && c.getSection(y) == nil
+}
+
+// columnHeight returns one more than the y coord of the highest
+// non-transparent block in the column at or below the given y coord. If there
+// is no such block, the lowest y coord of the chunk is returned.
+func (c *chunk) columnHeight(x, y, z int32) int32 {
+	for i := y; i >= c.minY; i-- {
+		if s := c.getSection(i); s != nil {
+			if s.GetOpacity(x, i, z) > 1 {
+				return i + 1
+			}
+		}
+	}
+
+	return c.minY
+}
+
+// setMapper sets the BlockMapper used to convert between blocks and the block
+// states of paletted sections.
+func (c *chunk) setMapper(m BlockMapper) {
+	c.mapper = m
+
+	for _, s := range c.sections {
+		if s != nil {
+			s.setMapper(m)
+		}
+	}
+}
+
+// canStore returns an error if the given block cannot be stored in the
+// chunk: unmapped blocks cannot be stored in legacy chunks, and other blocks
+// need a block state to be stored in paletted chunks.
+func (c *chunk) canStore(b Block) error {
+	if c.format == legacyBlocks {
+		if b.state != nil {
+			return UnknownBlockState{State: b.state.key}
+		}
+	} else if b.state == nil {
+		if _, ok := c.mapper.StateFromBlock(b); !ok {
+			return UnknownBlock{ID: b.ID, Data: b.Data}
+		}
+	}
+
+	return nil
+}
+
 func (c *chunk) GetBlock(x, y, z int32) Block {
-	ys := y >> 4
+	s := c.getSection(y)
 
-	if c.sections[ys] == nil {
+	if s == nil {
 		return Block{}
 	}
 
-	b := c.sections[ys].GetBlock(x, y, z)
+	b := s.GetBlock(x, y, z)
 	pos := xyz(x, y, z)
 
 	if md, ok := c.tileEntities[pos]; ok && md != nil {
@@ -275,31 +577,30 @@ func (c *chunk) GetBlock(x, y, z int32) Block {
 }
 
 func (c *chunk) SetBlock(x, y, z int32, b Block) {
-	ys := y >> 4
+	if !c.inBounds(y) {
+		return
+	}
+
+	s := c.getSection(y)
 
-	if c.sections[ys] == nil {
+	if s == nil {
 		if b.EqualBlock(Block{}) {
 			return
 		}
 
-		c.sections[ys] = newSection(y)
+		s = c.newSection(y)
+		c.sections[(y-c.minY)>>4] = s
 	}
 
-	c.sections[ys].SetBlock(x, y, z, b)
+	s.SetBlock(x, y, z, b)
+
+	if c.format != legacyBlocks {
+		c.data.Remove("Heightmaps") // recalculated by the game when missing
+	}
 
 	if hmpos := x&15<<4 | z&15; b.Opacity() <= 1 { // All transparent blocks block 1 light when they are below the highest non-transparent block
 		if y == c.heightMap[hmpos]-1 {
-			c.heightMap[hmpos] = 0
-
-			for i := y; i >= 0; i-- {
-				if yt := i >> 4; c.sections[yt] != nil {
-					if c.sections[yt].GetOpacity(x, i, z) > 1 {
-						c.heightMap[hmpos] = i + 1
-
-						break
-					}
-				}
-			}
+			c.heightMap[hmpos] = c.columnHeight(x, y, z)
 		}
 	} else if y >= c.heightMap[hmpos] {
 		c.heightMap[hmpos] = y + 1
@@ -318,7 +619,9 @@ func (c *chunk) SetBlock(x, y, z int32, b Block) {
 
 		c.tileEntities[pos] = comp
 	}
-	if b.HasTicks() {
+	if c.format != legacyBlocks {
+		return
+	} else if b.HasTicks() {
 		ticks := b.GetTicks()
 
 		c.tileTicks[pos] = make([]nbt.Compound, len(ticks))
@@ -338,12 +641,92 @@ func (c *chunk) SetBlock(x, y, z int32, b Block) {
 	}
 }
 
+func (c *chunk) newSection(y int32) *section {
+	if c.format == legacyBlocks {
+		return newSection(y)
+	}
+
+	return newPalettedSection(y, c.format, c.mapper)
+}
+
+// biomeCell returns the index of the lowest 4x4x4 biome cell containing the
+// given column.
+func biomeCell(x, z int32) int {
+	return int(z&15>>2<<2 | x&15>>2)
+}
+
+// GetBiome returns the biome of the given column. For chunks storing biomes
+// in 4x4x4 cells, the biome of the lowest cell is returned.
 func (c *chunk) GetBiome(x, z int32) Biome {
-	return Biome(c.biomes[x&15<<4|z&15])
+	switch c.format {
+	case legacyBlocks:
+		return Biome(c.biomes[x&15<<4|z&15])
+	case containerBlocks:
+		for _, s := range c.sections {
+			if s != nil && s.biomes != nil {
+				return s.biomes.get(biomeCell(x, z))
+			}
+		}
+	default:
+		switch len(c.biomeInts) {
+		case 256:
+			return Biome(c.biomeInts[x&15<<4|z&15])
+		case 1024:
+			return Biome(c.biomeInts[biomeCell(x, z)])
+		}
+	}
+
+	return AutoBiome
 }
 
+// SetBiome sets the biome of the given column. For chunks storing biomes in
+// 4x4x4 cells, every cell containing the column is set.
 func (c *chunk) SetBiome(x, z int32, b Biome) {
-	c.biomes[x&15<<4|z&15] = int8(b)
+	switch c.format {
+	case legacyBlocks:
+		c.biomes[x&15<<4|z&15] = int8(b)
+	case containerBlocks:
+		for _, s := range c.sections {
+			if s != nil && s.biomes != nil {
+				for y := 0; y < 64; y += 16 {
+					s.biomes.set(y|biomeCell(x, z), b)
+				}
+			}
+		}
+	default:
+		if c.biomeInts == nil {
+			size := 256
+			if c.dataVersion >= dataVersionBiomes3D {
+				size = 1024
+			}
+
+			c.biomeInts = make(nbt.IntArray, size)
+
+			for n := range c.biomeInts {
+				c.biomeInts[n] = int32(b)
+			}
+
+			c.data.Set(nbt.NewTag("Biomes", c.biomeInts))
+		} else if len(c.biomeInts) == 256 {
+			c.biomeInts[x&15<<4|z&15] = int32(b)
+		} else {
+			for y := 0; y < 1024; y += 16 {
+				c.biomeInts[y|biomeCell(x, z)] = int32(b)
+			}
+		}
+	}
+}
+
+// canStoreBiome returns an error if the given biome cannot be stored in the
+// chunk.
+func (c *chunk) canStoreBiome(b Biome) error {
+	if c.format == containerBlocks {
+		if _, ok := biomeToName[b]; !ok {
+			return UnknownBiome{Biome: b}
+		}
+	}
+
+	return nil
 }
 
 func (c *chunk) GetOpacity(x, y, z int32) uint8 {
@@ -351,13 +734,13 @@ func (c *chunk) GetOpacity(x, y, z int32) uint8 {
 		return 1
 	}
 
-	ys := y >> 4
+	s := c.getSection(y)
 
-	if c.sections[ys] == nil {
+	if s == nil {
 		return 1
 	}
 
-	return c.sections[ys].GetOpacity(x, y, z)
+	return s.GetOpacity(x, y, z)
 }
 
 func (c *chunk) GetHeight(x, z int32) int32 {
@@ -365,38 +748,32 @@ func (c *chunk) GetHeight(x, z int32) int32 {
 }
 
 func (c *chunk) GetBlockLight(x, y, z int32) uint8 {
-	ys := y >> 4
-
-	if ys < 16 && c.sections[ys] == nil {
-		return 0
-	} else if y > 255 {
+	if s := c.getSection(y); s != nil {
+		return s.GetBlockLight(x, y, z)
+	} else if y >= c.minY+c.height {
 		return 15
-	} else if y < 0 {
-		return 0
 	}
 
-	return c.sections[ys].GetBlockLight(x, y, z)
+	return 0
 }
 
 func (c *chunk) SetBlockLight(x, y, z int32, l uint8) {
-	ys := y >> 4
-
-	if ys < 16 && c.sections[ys] != nil {
-		c.sections[ys].SetBlockLight(x, y, z, l)
+	if s := c.getSection(y); s != nil {
+		s.SetBlockLight(x, y, z, l)
 	}
 }
 
 func (c *chunk) GetSkyLight(x, y, z int32) uint8 {
-	if ys := y >> 4; ys >= 0 && ys < 16 && c.sections[ys] != nil {
-		return c.sections[ys].GetSkyLight(x, y, z)
-	} else if y >= c.heightMap[x&15<<4|z&15] || y > 255 {
+	if s := c.getSection(y); s != nil {
+		return s.GetSkyLight(x, y, z)
+	} else if y >= c.heightMap[x&15<<4|z&15] || y >= c.minY+c.height {
 		return 15
-	} else if y < 0 {
+	} else if y < c.minY {
 		return 0
-	} else if ys < 15 && c.sections[ys+1] != nil {
-		sl := c.sections[ys+1].GetSkyLight(x, 0, z)
+	} else if above := (y>>4 + 1) << 4; c.getSection(above) != nil {
+		sl := c.getSection(above).GetSkyLight(x, above, z)
 
-		if d := uint8((ys+1)<<4 - y); d < sl {
+		if d := uint8(above - y); d < sl {
 			sl -= d
 		} else {
 			sl = 0
@@ -409,16 +786,14 @@ func (c *chunk) GetSkyLight(x, y, z int32) uint8 {
 }
 
 func (c *chunk) SetSkyLight(x, y, z int32, l uint8) {
-	ys := y >> 4
-
-	if ys < 16 && c.sections[ys] != nil {
-		c.sections[ys].SetSkyLight(x, y, z, l)
+	if s := c.getSection(y); s != nil {
+		s.SetSkyLight(x, y, z, l)
 	}
 }
 
 func (c *chunk) createSection(y int32) bool {
-	if ys := y >> 4; ys >= 0 && ys < 16 && c.sections[ys] == nil {
-		c.sections[ys] = newSection(y)
+	if c.missingSection(y) {
+		c.sections[(y-c.minY)>>4] = c.newSection(y)
 
 		return true
 	}
@@ -426,8 +801,8 @@ func (c *chunk) createSection(y int32) bool {
 	return false
 }
 
-func xyz(x, y, z int32) uint16 {
-	return (uint16(y) << 8) | (uint16(z&15) << 4) | uint16(x&15)
+func xyz(x, y, z int32) uint32 {
+	return (uint32(y) << 8) | (uint32(z&15) << 4) | uint32(x&15)
 }
 
 func getCoord(name string, data nbt.Compound) (int32, error) {
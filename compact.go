@@ -0,0 +1,290 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"vimagination.zapto.org/memio"
+)
+
+// CompactReport describes the result of compacting a single region file.
+//
+// Before and After are the sizes, in bytes, of the region file along with any
+// external chunk files for the region.
+//
+// Problems lists the chunks that could not be read, and were dropped, along
+// with any that could not be recompressed, and were left unchanged.
+type CompactReport struct {
+	Storage       Storage
+	X, Z          int32
+	Before, After int64
+	Removed       bool
+	Problems      []ChunkProblem
+}
+
+// Reclaimed returns the number of bytes saved by compacting the region.
+func (c CompactReport) Reclaimed() int64 {
+	return c.Before - c.After
+}
+
+// Compact rewrites the region file at region coords x, z so that there is no
+// unused space between or after its chunks. If recompress is true, all chunks
+// are recompressed using the compression scheme set on the FilePath.
+//
+// Chunks that cannot be read, as they have a zero length, extend past the end
+// of the file or are missing their external chunk file, are dropped and listed
+// in the Problems of the report, as are chunks that cannot be recompressed,
+// which are kept as they are.
+//
+// A region file that contains no chunks is removed.
+func (p *FilePath) Compact(x, z int32, recompress bool) (CompactReport, error) {
+	return p.CompactStorage(StorageRegion, x, z, recompress)
+}
+
+// CompactDimension runs CompactStorage on every region file, of every storage
+// kind, in the current dimension.
+func (p *FilePath) CompactDimension(recompress bool) ([]CompactReport, error) {
+	var reports []CompactReport
+
+	for _, s := range [...]Storage{StorageRegion, StorageEntities, StoragePOI} {
+		for _, r := range p.getRegions(s) {
+			report, err := p.CompactStorage(s, r[0], r[1], recompress)
+			if err != nil {
+				return reports, err
+			}
+
+			reports = append(reports, report)
+		}
+	}
+
+	return reports, nil
+}
+
+type externalWrite struct {
+	name string
+	data []byte
+}
+
+// CompactStorage compacts the region file of the given storage kind at region
+// coords x, z, as with Compact.
+func (p *FilePath) CompactStorage(s Storage, x, z int32, recompress bool) (CompactReport, error) {
+	report := CompactReport{Storage: s, X: x, Z: z}
+
+	if err := p.canWrite(); err != nil {
+		return report, err
+	}
+
+	l := p.regionLock(s, x, z)
+
+	l.Lock()
+	defer l.Unlock()
+
+	// Opening the region replays any outstanding journal.
+	r, err := p.getRegion(s, x, z, false)
+	if err != nil {
+		return report, err
+	}
+
+	p.releaseRegion(r)
+	p.closeRegion(s, x, z)
+
+	name := p.getRegionPath(s, x, z)
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return report, err
+	} else if len(data) < 4096 {
+		return report, ErrRegionHeader
+	}
+
+	report.Before = int64(len(data))
+
+	var (
+		entries [1024]regionEntry
+		writes  []externalWrite
+		removes []string
+		size    int64
+		empty   = true
+	)
+
+	for i := 0; i < 1024; i++ {
+		loc := binary.BigEndian.Uint32(data[i<<2:])
+		if loc>>8 == 0 {
+			continue
+		}
+
+		cx, cz := x<<5|int32(i&31), z<<5|int32(i>>5)
+		start := int64(loc>>8) << 12
+
+		var length int64
+
+		if start+5 > int64(len(data)) {
+			err = ErrSectorsPastEOF
+		} else if length = int64(binary.BigEndian.Uint32(data[start:])); length == 0 {
+			err = ErrZeroLengthChunk
+		} else if start+4+length > int64(len(data)) {
+			err = ErrSectorsPastEOF
+		}
+
+		if err != nil {
+			report.Problems = append(report.Problems, ChunkProblem{X: cx, Z: cz, Err: err, Action: Dropped})
+			err = nil
+
+			continue
+		}
+
+		entry := data[start : start+4+length]
+		compression := entry[4] &^ externalChunk
+		external := p.getExternalChunkPath(s, cx, cz)
+
+		var payload []byte
+
+		if entry[4]&externalChunk != 0 {
+			if payload, err = os.ReadFile(external); os.IsNotExist(err) {
+				report.Problems = append(report.Problems, ChunkProblem{X: cx, Z: cz, Err: err, Action: Dropped})
+				err = nil
+
+				continue
+			} else if err != nil {
+				return report, err
+			}
+
+			report.Before += int64(len(payload))
+		} else {
+			payload = entry[5:]
+		}
+
+		if recompress && (compression != p.compression || compression == Custom) {
+			if recompressed, err := p.recompress(compression, payload); err != nil {
+				report.Problems = append(report.Problems, ChunkProblem{X: cx, Z: cz, Err: err})
+			} else {
+				payload, compression = recompressed, p.compression
+
+				if sectors(len(payload)) > 255 {
+					entry = []byte{0, 0, 0, 1, compression | externalChunk}
+					writes = append(writes, externalWrite{external, payload})
+				} else {
+					entry = make([]byte, 5, 5+len(payload))
+
+					binary.BigEndian.PutUint32(entry, uint32(len(payload))+1)
+
+					entry[4] = compression
+					entry = append(entry, payload...)
+
+					if data[start+4]&externalChunk != 0 {
+						removes = append(removes, external)
+					}
+				}
+			}
+		}
+
+		if entry[4]&externalChunk != 0 {
+			size += int64(len(payload))
+		}
+
+		entries[i].data = entry
+		empty = false
+
+		if len(data) >= 8192 {
+			entries[i].timestamp = binary.BigEndian.Uint32(data[4096+i<<2:])
+		}
+	}
+
+	if empty {
+		report.Removed = true
+
+		return report, os.Remove(name)
+	}
+
+	for _, w := range writes {
+		if err = writeExternalChunk(w.name, w.data); err != nil {
+			return report, err
+		}
+	}
+
+	regionSize, err := writeRegionFile(name, &entries)
+	if err != nil {
+		return report, err
+	}
+
+	report.After = regionSize + size
+
+	for _, name := range removes {
+		if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func (p *FilePath) recompress(compression byte, data []byte) ([]byte, error) {
+	dr, err := decompressChunk(compression, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer dr.Close()
+
+	var buf memio.Buffer
+
+	cw, err := compressChunk(p.compression, p.customCompression, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(cw, dr)
+
+	if cerr := cw.Close(); err == nil {
+		err = cerr
+	}
+
+	return buf, err
+}
+
+// regionEntry is the raw data, including the length and compression header,
+// and timestamp of a single chunk within a region file.
+type regionEntry struct {
+	data      []byte
+	timestamp uint32
+}
+
+// writeRegionFile writes a new, compact, region file containing the given
+// entries, returning the size of the new file.
+func writeRegionFile(name string, entries *[1024]regionEntry) (int64, error) {
+	var (
+		header [8192]byte
+		body   bytes.Buffer
+		sector uint32 = 2
+	)
+
+	for n, e := range entries {
+		if e.data == nil {
+			continue
+		}
+
+		count := sectors(len(e.data) - 5)
+
+		binary.BigEndian.PutUint32(header[n<<2:], sector<<8|count)
+		binary.BigEndian.PutUint32(header[4096+n<<2:], e.timestamp)
+
+		body.Write(e.data)
+		body.Write(make([]byte, int(count<<12)-len(e.data)))
+
+		sector += count
+	}
+
+	size := int64(len(header) + body.Len())
+
+	return size, writeFileAtomic(name, func(w io.Writer) error {
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+
+		_, err := body.WriteTo(w)
+
+		return err
+	})
+}
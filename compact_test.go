@@ -0,0 +1,186 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestFilePathCompact(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = f.SetChunk(addPos(0, 0, 3), addPos(1, 0, 2), addPos(2, 0, 1), addPos(3, 0, 0), addPos(40, 0, 0)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.RemoveChunk(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.RemoveChunk(2, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.RemoveChunk(40, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	regionFile := path.Join(tempDir, "region", "r.0.0.mca")
+
+	before, err := os.Stat(regionFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reports, err := f.CompactDimension(false)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(reports) != 2 {
+		t.Fatalf("expecting 2 reports, got %v", reports)
+	}
+
+	after, err := os.Stat(regionFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if r := reports[0]; r.Storage != StorageRegion || r.X != 0 || r.Z != 0 || r.Removed {
+		t.Errorf("unexpected report for region 0,0: %v", r)
+	} else if r.Before != before.Size() || r.After != after.Size() || r.Reclaimed() <= 0 {
+		t.Errorf("expecting to reclaim %d bytes, got report %v", before.Size()-after.Size(), r)
+	} else if after.Size()%4096 != 0 {
+		t.Errorf("region file size not divisible by 4096, got %d", after.Size())
+	}
+
+	if r := reports[1]; r.X != 1 || r.Z != 0 || !r.Removed || r.After != 0 {
+		t.Errorf("expecting region 1,0 to be removed, got %v", r)
+	} else if _, err = os.Stat(path.Join(tempDir, "region", "r.1.0.mca")); !os.IsNotExist(err) {
+		t.Error("expecting empty region file to be removed")
+	}
+
+	check := func(compression byte) {
+		t.Helper()
+
+		for _, c := range [...]struct {
+			x        int32
+			chunkNum uint8
+		}{
+			{1, 2},
+			{3, 0},
+		} {
+			if chunk, err := f.GetChunk(c.x, 0); err != nil {
+				t.Errorf("chunk %d: unexpected error: %s", c.x, err)
+			} else if !chunk.Equal(addPos(c.x, 0, c.chunkNum)) {
+				t.Errorf("chunk %d: chunk data does not match", c.x)
+			}
+		}
+
+		it := f.Chunks(context.Background())
+
+		for it.Next() {
+			if c := it.Info(); c.Compression != compression {
+				t.Errorf("chunk %d,%d: expecting compression %d, got %d", c.X, c.Z, compression, c.Compression)
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	check(Zlib)
+
+	if err = WriteCompression(LZ4)(f); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if r, err := f.Compact(0, 0, true); err != nil {
+		t.Fatal(err.Error())
+	} else if r.Removed || r.After <= 0 {
+		t.Errorf("unexpected report: %v", r)
+	}
+
+	check(LZ4)
+}
+
+func TestFilePathCompactBadChunks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = f.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1), addPos(2, 0, 2), addPos(40, 0, 3)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	f.Close()
+
+	regionFile := path.Join(tempDir, "region", "r.0.0.mca")
+
+	region, err := os.ReadFile(regionFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	binary.BigEndian.PutUint32(region[binary.BigEndian.Uint32(region[4:])>>8<<12:], 0) // zero length
+	binary.BigEndian.PutUint32(region[8:], uint32(len(region)>>12+10)<<8|1)            // past EOF
+
+	if err = os.WriteFile(regionFile, region, 0o666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if f, err = NewFilePath(tempDir); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reports, err := f.CompactDimension(false)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(reports) != 2 {
+		t.Fatalf("expecting 2 reports, got %v", reports)
+	}
+
+	expected := [...]ChunkProblem{
+		{X: 1, Z: 0, Err: ErrZeroLengthChunk, Action: Dropped},
+		{X: 2, Z: 0, Err: ErrSectorsPastEOF, Action: Dropped},
+	}
+
+	if problems := reports[0].Problems; len(problems) != len(expected) {
+		t.Fatalf("expecting %d problems, got %v", len(expected), problems)
+	} else {
+		for n, e := range expected {
+			if problems[n] != e {
+				t.Errorf("problem %d: expecting %v, got %v", n+1, e, problems[n])
+			}
+		}
+	}
+
+	if len(reports[1].Problems) != 0 {
+		t.Errorf("expecting no problems in region 1,0, got %v", reports[1].Problems)
+	}
+
+	for _, c := range [...]struct {
+		x        int32
+		chunkNum uint8
+	}{
+		{0, 0},
+		{40, 3},
+	} {
+		if chunk, err := f.GetChunk(c.x, 0); err != nil {
+			t.Errorf("chunk %d: unexpected error: %s", c.x, err)
+		} else if !chunk.Equal(addPos(c.x, 0, c.chunkNum)) {
+			t.Errorf("chunk %d: chunk data does not match", c.x)
+		}
+	}
+
+	for _, x := range [...]int32{1, 2} {
+		if chunk, err := f.GetChunk(x, 0); err != nil {
+			t.Errorf("chunk %d: unexpected error: %s", x, err)
+		} else if chunk.TagID() != 0 {
+			t.Errorf("chunk %d: expecting chunk to be dropped", x)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package minecraft
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	"vimagination.zapto.org/byteio"
+)
+
+// CompressionFuncs contains the functions required to read and write chunks
+// using a custom compression scheme.
+type CompressionFuncs struct {
+	Reader func(io.Reader) (io.ReadCloser, error)
+	Writer func(io.Writer) (io.WriteCloser, error)
+}
+
+var customCompression = make(map[string]CompressionFuncs)
+
+// RegisterCompression registers a custom compression scheme that can be used
+// to read and write chunks with the Custom compression code.
+//
+// The name is a namespaced identifier (e.g. "mymod:zstd") that is stored
+// before the compressed chunk data.
+//
+// This function is not safe to be called concurrently with chunk reads or
+// writes and should be called during program initialisation.
+func RegisterCompression(name string, funcs CompressionFuncs) {
+	customCompression[name] = funcs
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func decompressChunk(compression byte, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case GZip:
+		return gzip.NewReader(r)
+	case Zlib:
+		return zlib.NewReader(r)
+	case Uncompressed:
+		return io.NopCloser(r), nil
+	case LZ4:
+		return newLZ4Reader(r), nil
+	case Custom:
+		name, _, err := byteio.BigEndianReader{Reader: r}.ReadString16()
+		if err != nil {
+			return nil, err
+		}
+
+		c, ok := customCompression[name]
+		if !ok || c.Reader == nil {
+			return nil, UnknownCustomCompression{name}
+		}
+
+		return c.Reader(r)
+	}
+
+	return nil, UnknownCompression{compression}
+}
+
+func compressChunk(compression byte, custom string, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case GZip:
+		return gzip.NewWriter(w), nil
+	case Zlib:
+		return zlib.NewWriter(w), nil
+	case Uncompressed:
+		return nopCloser{w}, nil
+	case LZ4:
+		return newLZ4Writer(w), nil
+	case Custom:
+		c, ok := customCompression[custom]
+		if !ok || c.Writer == nil {
+			return nil, UnknownCustomCompression{custom}
+		}
+
+		if _, err := (byteio.BigEndianWriter{Writer: w}).WriteString16(custom); err != nil {
+			return nil, err
+		}
+
+		return c.Writer(w)
+	}
+
+	return nil, UnknownCompression{compression}
+}
@@ -0,0 +1,117 @@
+package minecraft
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestXXHash32(t *testing.T) {
+	for n, test := range [...]struct {
+		Input string
+		Seed  uint32
+		Hash  uint32
+	}{
+		{"", 0, 0x02cc5d05},
+		{"a", 0, 0x550d7456},
+		{"abc", 0, 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0, 0xe2293b2f},
+	} {
+		if hash := xxHash32([]byte(test.Input), test.Seed); hash != test.Hash {
+			t.Errorf("test %d: expecting hash 0x%08x, got 0x%08x", n+1, test.Hash, hash)
+		}
+	}
+}
+
+func TestLZ4DecompressBlock(t *testing.T) {
+	dst := make([]byte, 13)
+
+	if n, err := lz4DecompressBlock(dst, []byte{0x44, 'a', 'b', 'c', 'd', 4, 0, 0x10, '!'}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if str := string(dst[:n]); str != "abcdabcdabcd!" {
+		t.Errorf("expecting %q, got %q", "abcdabcdabcd!", str)
+	}
+
+	if _, err := lz4DecompressBlock(dst, []byte{0x48, 'a', 'b', 'c', 'd', 5, 0}); err != errLZ4Corrupt {
+		t.Errorf("expecting corrupt error, got %v", err)
+	}
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	random := make([]byte, 100000)
+
+	rand.New(rand.NewSource(0)).Read(random)
+
+	for n, input := range [...][]byte{
+		{},
+		[]byte("a"),
+		[]byte("Hello, World"),
+		bytes.Repeat([]byte("minecraft"), 50000),
+		random,
+		append(bytes.Repeat([]byte{0}, 70000), random...),
+	} {
+		var buf bytes.Buffer
+
+		w := newLZ4Writer(&buf)
+
+		if _, err := w.Write(input); err != nil {
+			t.Errorf("test %d: unexpected write error: %s", n+1, err)
+
+			continue
+		} else if err = w.Close(); err != nil {
+			t.Errorf("test %d: unexpected close error: %s", n+1, err)
+
+			continue
+		}
+
+		output, err := io.ReadAll(newLZ4Reader(&buf))
+		if err != nil {
+			t.Errorf("test %d: unexpected read error: %s", n+1, err)
+		} else if !bytes.Equal(input, output) {
+			t.Errorf("test %d: output does not match input", n+1)
+		} else if buf.Len() != 0 {
+			t.Errorf("test %d: %d bytes left unread", n+1, buf.Len())
+		}
+	}
+}
+
+func TestFilePathCompression(t *testing.T) {
+	RegisterCompression("test:deflate", CompressionFuncs{
+		Reader: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+		Writer: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.BestSpeed)
+		},
+	})
+
+	for _, test := range [...]struct {
+		Name   string
+		Option FilePathOption
+	}{
+		{"gzip", WriteCompression(GZip)},
+		{"zlib", WriteCompression(Zlib)},
+		{"uncompressed", WriteCompression(Uncompressed)},
+		{"lz4", WriteCompression(LZ4)},
+		{"custom", WriteCustomCompression("test:deflate")},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			f, err := NewFilePath(t.TempDir(), test.Option)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			testPathChunkSetGet(t, f)
+		})
+	}
+
+	if _, err := NewFilePath(t.TempDir(), WriteCompression(5)); err != (UnknownCompression{5}) {
+		t.Errorf("expecting UnknownCompression error, got %v", err)
+	}
+
+	if _, err := NewFilePath(t.TempDir(), WriteCustomCompression("test:unknown")); err != (UnknownCustomCompression{"test:unknown"}) {
+		t.Errorf("expecting UnknownCustomCompression error, got %v", err)
+	}
+}
@@ -0,0 +1,627 @@
+package minecraft
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultBlockStates is a BlockStateTable containing mappings between the
+// numeric blocks used up to minecraft 1.12 and the block states that replaced
+// them. More mappings can be added with the Add method.
+//
+// Properties that were not stored in the block data, such as the shape of
+// stairs or whether a block is waterlogged, are ignored when mapping a block
+// state to a Block. Blocks that are renamed in later versions are mapped from
+// both names, and mapped to the newer one.
+var DefaultBlockStates = NewBlockStateTable()
+
+// expandStates returns all of the block states described by the given string,
+// in which the value of each property may be a list of alternatives separated
+// by '|'; an empty alternative means that the property is absent. The first
+// state returned uses the first alternative of every property.
+func expandStates(s string) []BlockState {
+	state, err := ParseBlockState(s)
+	if err != nil {
+		panic(err)
+	}
+
+	states := []BlockState{{Name: state.Name}}
+
+	for _, k := range state.PropertyNames() {
+		values := strings.Split(state.Properties[k], "|")
+		expanded := make([]BlockState, 0, len(states)*len(values))
+
+		for _, st := range states {
+			for _, v := range values {
+				props := make(map[string]string, len(st.Properties)+1)
+
+				for pk, pv := range st.Properties {
+					props[pk] = pv
+				}
+
+				if v != "" {
+					props[k] = v
+				}
+
+				expanded = append(expanded, BlockState{Name: st.Name, Properties: props})
+			}
+		}
+
+		states = expanded
+	}
+
+	return states
+}
+
+func init() {
+	var (
+		colours    = [...]string{"white", "orange", "magenta", "light_blue", "yellow", "lime", "pink", "gray", "light_gray", "cyan", "purple", "blue", "brown", "green", "red", "black"}
+		woods      = [...]string{"oak", "spruce", "birch", "jungle", "acacia", "dark_oak"}
+		bools      = [...]string{"false", "true"}
+		facing     = [...]string{"down", "up", "north", "south", "west", "east"}
+		horizontal = [...]string{"south", "west", "north", "east"}
+		rails      = [...]string{"north_south", "east_west", "ascending_east", "ascending_west", "ascending_north", "ascending_south", "south_east", "south_west", "north_west", "north_east"}
+		numbers    [16]string
+
+		names = make(map[string]Block)
+		mixed = make(map[string]bool)
+	)
+
+	for n := range numbers {
+		numbers[n] = strconv.Itoa(n)
+	}
+
+	add := func(id uint16, data uint8, state string) {
+		b := Block{ID: id, Data: data}
+
+		for _, st := range expandStates(state) {
+			DefaultBlockStates.Add(st, b)
+
+			if nb, ok := names[st.Name]; !ok {
+				names[st.Name] = b
+			} else if nb.ID != b.ID || nb.Data != b.Data {
+				mixed[st.Name] = true
+			}
+		}
+	}
+
+	for _, m := range [...]struct {
+		id    uint16
+		data  uint8
+		state string
+	}{
+		{0, 0, "air"},
+		{0, 0, "cave_air"},
+		{0, 0, "void_air"},
+		{1, 0, "stone"},
+		{1, 1, "granite"},
+		{1, 2, "polished_granite"},
+		{1, 3, "diorite"},
+		{1, 4, "polished_diorite"},
+		{1, 5, "andesite"},
+		{1, 6, "polished_andesite"},
+		{2, 0, "grass_block[snowy=false|true]"},
+		{3, 0, "dirt"},
+		{3, 1, "coarse_dirt"},
+		{3, 2, "podzol[snowy=false|true]"},
+		{4, 0, "cobblestone"},
+		{7, 0, "bedrock"},
+		{12, 0, "sand"},
+		{12, 1, "red_sand"},
+		{13, 0, "gravel"},
+		{14, 0, "gold_ore"},
+		{15, 0, "iron_ore"},
+		{16, 0, "coal_ore"},
+		{19, 0, "sponge"},
+		{19, 1, "wet_sponge"},
+		{20, 0, "glass"},
+		{21, 0, "lapis_ore"},
+		{22, 0, "lapis_block"},
+		{24, 0, "sandstone"},
+		{24, 1, "chiseled_sandstone"},
+		{24, 2, "cut_sandstone"},
+		{25, 0, "note_block[instrument=harp,note=0,powered=false]"},
+		{30, 0, "cobweb"},
+		{31, 0, "dead_bush"},
+		{31, 1, "short_grass"},
+		{31, 1, "grass"},
+		{31, 2, "fern"},
+		{32, 0, "dead_bush"},
+		{36, 0, "moving_piston[facing=north,type=normal]"},
+		{37, 0, "dandelion"},
+		{38, 0, "poppy"},
+		{38, 1, "blue_orchid"},
+		{38, 2, "allium"},
+		{38, 3, "azure_bluet"},
+		{38, 4, "red_tulip"},
+		{38, 5, "orange_tulip"},
+		{38, 6, "white_tulip"},
+		{38, 7, "pink_tulip"},
+		{38, 8, "oxeye_daisy"},
+		{39, 0, "brown_mushroom"},
+		{40, 0, "red_mushroom"},
+		{41, 0, "gold_block"},
+		{42, 0, "iron_block"},
+		{43, 8, "smooth_stone"},
+		{43, 9, "smooth_sandstone"},
+		{43, 15, "smooth_quartz"},
+		{45, 0, "bricks"},
+		{46, 0, "tnt[unstable=false]"},
+		{46, 1, "tnt[unstable=true]"},
+		{47, 0, "bookshelf"},
+		{48, 0, "mossy_cobblestone"},
+		{49, 0, "obsidian"},
+		{50, 0, "torch"},
+		{50, 5, "torch"},
+		{52, 0, "spawner"},
+		{56, 0, "diamond_ore"},
+		{57, 0, "diamond_block"},
+		{58, 0, "crafting_table"},
+		{70, 0, "stone_pressure_plate[powered=false]"},
+		{70, 1, "stone_pressure_plate[powered=true]"},
+		{72, 0, "oak_pressure_plate[powered=false]"},
+		{72, 1, "oak_pressure_plate[powered=true]"},
+		{73, 0, "redstone_ore[lit=false]"},
+		{74, 0, "redstone_ore[lit=true]"},
+		{75, 5, "redstone_torch[lit=false]"},
+		{76, 5, "redstone_torch[lit=true]"},
+		{79, 0, "ice"},
+		{80, 0, "snow_block"},
+		{82, 0, "clay"},
+		{84, 0, "jukebox[has_record=false]"},
+		{84, 1, "jukebox[has_record=true]"},
+		{87, 0, "netherrack"},
+		{88, 0, "soul_sand"},
+		{89, 0, "glowstone"},
+		{90, 0, "nether_portal[axis=x]"},
+		{90, 1, "nether_portal[axis=x]"},
+		{90, 2, "nether_portal[axis=z]"},
+		{97, 0, "infested_stone"},
+		{97, 1, "infested_cobblestone"},
+		{97, 2, "infested_stone_bricks"},
+		{97, 3, "infested_mossy_stone_bricks"},
+		{97, 4, "infested_cracked_stone_bricks"},
+		{97, 5, "infested_chiseled_stone_bricks"},
+		{98, 0, "stone_bricks"},
+		{98, 1, "mossy_stone_bricks"},
+		{98, 2, "cracked_stone_bricks"},
+		{98, 3, "chiseled_stone_bricks"},
+		{101, 0, "iron_bars[east=false,north=false,south=false,waterlogged=false,west=false]"},
+		{102, 0, "glass_pane[east=false,north=false,south=false,waterlogged=false,west=false]"},
+		{103, 0, "melon"},
+		{104, 7, "attached_pumpkin_stem[facing=north|south|west|east]"},
+		{105, 7, "attached_melon_stem[facing=north|south|west|east]"},
+		{110, 0, "mycelium[snowy=false|true]"},
+		{111, 0, "lily_pad"},
+		{112, 0, "nether_bricks"},
+		{113, 0, "nether_brick_fence[east=false,north=false,south=false,waterlogged=false,west=false]"},
+		{116, 0, "enchanting_table"},
+		{118, 0, "cauldron"},
+		{118, 0, "cauldron[level=0]"},
+		{119, 0, "end_portal"},
+		{121, 0, "end_stone"},
+		{122, 0, "dragon_egg"},
+		{123, 0, "redstone_lamp[lit=false]"},
+		{124, 0, "redstone_lamp[lit=true]"},
+		{129, 0, "emerald_ore"},
+		{133, 0, "emerald_block"},
+		{138, 0, "beacon"},
+		{139, 0, "cobblestone_wall"},
+		{139, 1, "mossy_cobblestone_wall"},
+		{140, 0, "flower_pot"},
+		{152, 0, "redstone_block"},
+		{153, 0, "nether_quartz_ore"},
+		{155, 0, "quartz_block"},
+		{155, 1, "chiseled_quartz_block"},
+		{155, 2, "quartz_pillar[axis=y]"},
+		{155, 3, "quartz_pillar[axis=x]"},
+		{155, 4, "quartz_pillar[axis=z]"},
+		{165, 0, "slime_block"},
+		{166, 0, "barrier"},
+		{168, 0, "prismarine"},
+		{168, 1, "prismarine_bricks"},
+		{168, 2, "dark_prismarine"},
+		{169, 0, "sea_lantern"},
+		{172, 0, "terracotta"},
+		{173, 0, "coal_block"},
+		{174, 0, "packed_ice"},
+		{179, 0, "red_sandstone"},
+		{179, 1, "chiseled_red_sandstone"},
+		{179, 2, "cut_red_sandstone"},
+		{181, 8, "smooth_red_sandstone"},
+		{199, 0, "chorus_plant[down=false,east=false,north=false,south=false,up=false,west=false]"},
+		{201, 0, "purpur_block"},
+		{206, 0, "end_stone_bricks"},
+		{208, 0, "dirt_path"},
+		{208, 0, "grass_path"},
+		{209, 0, "end_gateway"},
+		{213, 0, "magma_block"},
+		{214, 0, "nether_wart_block"},
+		{215, 0, "red_nether_bricks"},
+		{217, 0, "structure_void"},
+		{255, 0, "structure_block[mode=save]"},
+		{255, 1, "structure_block[mode=load]"},
+		{255, 2, "structure_block[mode=corner]"},
+		{255, 3, "structure_block[mode=data]"},
+	} {
+		add(m.id, m.data, m.state)
+	}
+
+	for n, wood := range woods {
+		var (
+			id   uint16 = 17
+			leaf uint16 = 18
+			data        = uint8(n)
+		)
+
+		if n >= 4 {
+			id, leaf, data = 162, 161, uint8(n-4)
+		}
+
+		add(5, uint8(n), wood+"_planks")
+		add(6, uint8(n), wood+"_sapling[stage=0]")
+		add(6, uint8(n)|8, wood+"_sapling[stage=1]")
+		add(id, data, wood+"_log[axis=y]")
+		add(id, data|4, wood+"_log[axis=x]")
+		add(id, data|8, wood+"_log[axis=z]")
+		add(id, data|12, wood+"_wood[axis=y|x|z]")
+		add(leaf, data|8, wood+"_leaves[distance=7,persistent=false]")
+		add(leaf, data|12, wood+"_leaves[distance=7,persistent=true]")
+		add(leaf, data, wood+"_leaves[distance=7|1|2|3|4|5|6,persistent=false,waterlogged=|false|true]")
+		add(leaf, data|4, wood+"_leaves[distance=7|1|2|3|4|5|6,persistent=true,waterlogged=|false|true]")
+		add(125, uint8(n), wood+"_slab[type=double,waterlogged=false|true]")
+		add(126, uint8(n), wood+"_slab[type=bottom,waterlogged=false|true]")
+		add(126, uint8(n)|8, wood+"_slab[type=top,waterlogged=false|true]")
+	}
+
+	for d := uint8(0); d < 16; d++ {
+		level := numbers[d]
+
+		if d == 0 {
+			add(8, d, "water[level=0]")
+			add(9, d, "water[level=0]")
+			add(10, d, "lava[level=0]")
+			add(11, d, "lava[level=0]")
+		} else {
+			add(9, d, "water[level="+level+"]")
+			add(8, d, "water[level="+level+"]")
+			add(11, d, "lava[level="+level+"]")
+			add(10, d, "lava[level="+level+"]")
+		}
+
+		add(51, d, "fire[age="+level+",east=false|true,north=false|true,south=false|true,up=false|true,west=false|true]")
+		add(55, d, "redstone_wire[east=none|side|up,north=none|side|up,power="+level+",south=none|side|up,west=none|side|up]")
+		add(63, d, "oak_sign[rotation="+level+",waterlogged=false|true]")
+		add(63, d, "sign[rotation="+level+",waterlogged=false|true]")
+		add(81, d, "cactus[age="+level+"]")
+		add(83, d, "sugar_cane[age="+level+"]")
+		add(147, d, "light_weighted_pressure_plate[power="+level+"]")
+		add(148, d, "heavy_weighted_pressure_plate[power="+level+"]")
+		add(151, d, "daylight_detector[inverted=false,power="+level+"]")
+		add(176, d, "white_banner[rotation="+level+"]")
+		add(178, d, "daylight_detector[inverted=true,power="+level+"]")
+	}
+
+	for d := uint8(0); d < 8; d++ {
+		age := numbers[d]
+
+		add(59, d, "wheat[age="+age+"]")
+		add(60, d, "farmland[moisture="+age+"]")
+		add(78, d, "snow[layers="+numbers[d+1]+"]")
+		add(104, d, "pumpkin_stem[age="+age+"]")
+		add(105, d, "melon_stem[age="+age+"]")
+		add(141, d, "carrots[age="+age+"]")
+		add(142, d, "potatoes[age="+age+"]")
+		add(117, d, "brewing_stand[has_bottle_0="+bools[d&1]+",has_bottle_1="+bools[d>>1&1]+",has_bottle_2="+bools[d>>2]+"]")
+
+		if d < 7 {
+			add(92, d, "cake[bites="+age+"]")
+		}
+
+		if d < 6 {
+			add(200, d, "chorus_flower[age="+age+"]")
+		}
+
+		if d < 4 {
+			add(115, d, "nether_wart[age="+age+"]")
+			add(207, d, "beetroots[age="+age+"]")
+			add(212, d, "frosted_ice[age="+age+"]")
+		}
+
+		if d > 0 && d < 4 {
+			add(118, d, "water_cauldron[level="+age+"]")
+			add(118, d, "cauldron[level="+age+"]")
+		}
+	}
+
+	for n, colour := range colours {
+		add(35, uint8(n), colour+"_wool")
+		add(95, uint8(n), colour+"_stained_glass")
+		add(159, uint8(n), colour+"_terracotta")
+		add(160, uint8(n), colour+"_stained_glass_pane[east=false,north=false,south=false,waterlogged=false,west=false]")
+		add(171, uint8(n), colour+"_carpet")
+		add(251, uint8(n), colour+"_concrete")
+		add(252, uint8(n), colour+"_concrete_powder")
+
+		for d, f := range facing {
+			add(219+uint16(n), uint8(d), colour+"_shulker_box[facing="+f+"]")
+		}
+
+		for d, f := range horizontal {
+			add(235+uint16(n), uint8(d), colour+"_glazed_terracotta[facing="+f+"]")
+		}
+	}
+
+	for n, name := range [...]string{"smooth_stone", "sandstone", "petrified_oak", "cobblestone", "brick", "stone_brick", "nether_brick", "quartz"} {
+		add(43, uint8(n), name+"_slab[type=double,waterlogged=false|true]")
+		add(44, uint8(n), name+"_slab[type=bottom,waterlogged=false|true]")
+		add(44, uint8(n)|8, name+"_slab[type=top,waterlogged=false|true]")
+	}
+
+	for _, s := range [...]struct {
+		double, single uint16
+		name           string
+	}{
+		{181, 182, "red_sandstone"},
+		{204, 205, "purpur"},
+	} {
+		add(s.double, 0, s.name+"_slab[type=double,waterlogged=false|true]")
+		add(s.single, 0, s.name+"_slab[type=bottom,waterlogged=false|true]")
+		add(s.single, 8, s.name+"_slab[type=top,waterlogged=false|true]")
+	}
+
+	for _, s := range [...]struct {
+		id   uint16
+		name string
+	}{
+		{53, "oak"},
+		{67, "cobblestone"},
+		{108, "brick"},
+		{109, "stone_brick"},
+		{114, "nether_brick"},
+		{128, "sandstone"},
+		{134, "spruce"},
+		{135, "birch"},
+		{136, "jungle"},
+		{156, "quartz"},
+		{163, "acacia"},
+		{164, "dark_oak"},
+		{180, "red_sandstone"},
+		{203, "purpur"},
+	} {
+		for d := uint8(0); d < 8; d++ {
+			add(s.id, d, s.name+"_stairs[facing="+[...]string{"east", "west", "south", "north"}[d&3]+",half="+[...]string{"bottom", "top"}[d>>2]+",shape=straight|inner_left|inner_right|outer_left|outer_right,waterlogged=false|true]")
+		}
+	}
+
+	for _, s := range [...]struct {
+		fence, gate, door uint16
+		name              string
+	}{
+		{85, 107, 64, "oak"},
+		{188, 183, 193, "spruce"},
+		{189, 184, 194, "birch"},
+		{190, 185, 195, "jungle"},
+		{192, 187, 196, "acacia"},
+		{191, 186, 197, "dark_oak"},
+		{0, 0, 71, "iron"},
+	} {
+		if s.fence != 0 {
+			add(s.fence, 0, s.name+"_fence[east=false,north=false,south=false,waterlogged=false,west=false]")
+
+			for d := uint8(0); d < 8; d++ {
+				add(s.gate, d, s.name+"_fence_gate[facing="+horizontal[d&3]+",in_wall=false|true,open="+bools[d>>2]+",powered=false|true]")
+			}
+		}
+
+		for d := uint8(0); d < 8; d++ {
+			add(s.door, d, s.name+"_door[facing="+[...]string{"east", "south", "west", "north"}[d&3]+",half=lower,hinge=left|right,open="+bools[d>>2]+",powered=false|true]")
+		}
+
+		for d := uint8(8); d < 12; d++ {
+			add(s.door, d, s.name+"_door[facing=east|south|west|north,half=upper,hinge="+[...]string{"left", "right"}[d&1]+",open=false|true,powered="+bools[d>>1&1]+"]")
+		}
+	}
+
+	for d := uint8(0); d < 16; d++ {
+		if d&7 >= 6 {
+			continue
+		}
+
+		f, on := facing[d&7], bools[d>>3]
+
+		add(23, d, "dispenser[facing="+f+",triggered="+on+"]")
+		add(29, d, "sticky_piston[extended="+on+",facing="+f+"]")
+		add(33, d, "piston[extended="+on+",facing="+f+"]")
+		add(34, d, "piston_head[facing="+f+",short=false|true,type="+[...]string{"normal", "sticky"}[d>>3]+"]")
+		add(137, d, "command_block[conditional="+on+",facing="+f+"]")
+		add(158, d, "dropper[facing="+f+",triggered="+on+"]")
+		add(210, d, "repeating_command_block[conditional="+on+",facing="+f+"]")
+		add(211, d, "chain_command_block[conditional="+on+",facing="+f+"]")
+		add(218, d, "observer[facing="+f+",powered="+on+"]")
+
+		if d&7 != 1 {
+			add(154, d, "hopper[enabled="+bools[d>>3^1]+",facing="+f+"]")
+		}
+
+		if d < 6 {
+			add(198, d, "end_rod[facing="+f+"]")
+		}
+	}
+
+	for d := uint8(2); d < 6; d++ {
+		f := facing[d]
+
+		add(54, d, "chest[facing="+f+",type=single|left|right,waterlogged=false|true]")
+		add(61, d, "furnace[facing="+f+",lit=false]")
+		add(62, d, "furnace[facing="+f+",lit=true]")
+		add(65, d, "ladder[facing="+f+",waterlogged=false|true]")
+		add(68, d, "oak_wall_sign[facing="+f+",waterlogged=false|true]")
+		add(68, d, "wall_sign[facing="+f+",waterlogged=false|true]")
+		add(130, d, "ender_chest[facing="+f+",waterlogged=false|true]")
+		add(144, d, "skeleton_wall_skull[facing="+f+"]")
+		add(146, d, "trapped_chest[facing="+f+",type=single|left|right,waterlogged=false|true]")
+		add(177, d, "white_wall_banner[facing="+f+"]")
+	}
+
+	for d := uint8(0); d < 16; d++ {
+		var (
+			f        = horizontal[d&3]
+			trapdoor = [...]string{"north", "south", "west", "east"}[d&3]
+			bit2     = bools[d>>2&1]
+			bit3     = bools[d>>3]
+		)
+
+		add(26, d, "red_bed[facing="+f+",occupied="+bit2+",part="+[...]string{"foot", "head"}[d>>3]+"]")
+		add(93, d, "repeater[delay="+numbers[d>>2+1]+",facing="+f+",locked=false|true,powered=false]")
+		add(94, d, "repeater[delay="+numbers[d>>2+1]+",facing="+f+",locked=false|true,powered=true]")
+		add(96, d, "oak_trapdoor[facing="+trapdoor+",half="+[...]string{"bottom", "top"}[d>>3]+",open="+bit2+",powered=false|true,waterlogged=false|true]")
+		add(131, d, "tripwire_hook[attached="+bit2+",facing="+f+",powered="+bit3+"]")
+		add(167, d, "iron_trapdoor[facing="+trapdoor+",half="+[...]string{"bottom", "top"}[d>>3]+",open="+bit2+",powered=false|true,waterlogged=false|true]")
+
+		if d < 8 {
+			add(150, d, "comparator[facing="+f+",mode="+[...]string{"compare", "subtract"}[d>>2]+",powered=true]")
+			add(120, d, "end_portal_frame[eye="+bit2+",facing="+f+"]")
+		}
+
+		add(149, d, "comparator[facing="+f+",mode="+[...]string{"compare", "subtract"}[d>>2&1]+",powered="+bit3+"]")
+
+		if d < 12 {
+			add(127, d, "cocoa[age="+numbers[d>>2]+",facing="+f+"]")
+			add(145, d, [...]string{"anvil", "chipped_anvil", "damaged_anvil"}[d>>2]+"[facing="+f+"]")
+		}
+
+		if d < 4 {
+			add(86, d, "carved_pumpkin[facing="+f+"]")
+			add(91, d, "jack_o_lantern[facing="+f+"]")
+		}
+	}
+
+	for d := uint8(0); d < 16; d++ {
+		var (
+			w       = d & 7
+			powered = bools[d>>3]
+		)
+
+		add(69, d, "lever["+[...]string{
+			"face=ceiling,facing=west|east",
+			"face=wall,facing=east",
+			"face=wall,facing=west",
+			"face=wall,facing=south",
+			"face=wall,facing=north",
+			"face=floor,facing=north|south",
+			"face=floor,facing=west|east",
+			"face=ceiling,facing=north|south",
+		}[w]+",powered="+powered+"]")
+
+		var button string
+
+		switch w {
+		case 0:
+			button = "face=ceiling,facing=north|east|south|west"
+		case 5:
+			button = "face=floor,facing=north|east|south|west"
+		case 6, 7:
+			continue
+		default:
+			f := [...]string{"", "east", "west", "south", "north"}[w]
+			button = "face=wall,facing=" + f
+
+			if d < 8 {
+				add(50, d, "wall_torch[facing="+f+"]")
+				add(75, d, "redstone_wall_torch[facing="+f+",lit=false]")
+				add(76, d, "redstone_wall_torch[facing="+f+",lit=true]")
+			}
+		}
+
+		add(77, d, "stone_button["+button+",powered="+powered+"]")
+		add(143, d, "oak_button["+button+",powered="+powered+"]")
+	}
+
+	for d, shape := range rails {
+		add(66, uint8(d), "rail[shape="+shape+",waterlogged=|false|true]")
+
+		if d >= 6 {
+			continue
+		}
+
+		for p, powered := range bools {
+			data := uint8(d | p<<3)
+
+			add(27, data, "powered_rail[powered="+powered+",shape="+shape+",waterlogged=|false|true]")
+			add(28, data, "detector_rail[powered="+powered+",shape="+shape+",waterlogged=|false|true]")
+			add(157, data, "activator_rail[powered="+powered+",shape="+shape+",waterlogged=|false|true]")
+		}
+	}
+
+	for d := uint8(0); d < 16; d++ {
+		up := "false|true"
+
+		if d == 0 {
+			up = "true"
+		}
+
+		add(106, d, "vine[east="+bools[d>>3]+",north="+bools[d>>2&1]+",south="+bools[d&1]+",up="+up+",west="+bools[d>>1&1]+"]")
+
+		if d&2 == 0 {
+			add(132, d, "tripwire[attached="+bools[d>>2&1]+",disarmed="+bools[d>>3]+",east=false|true,north=false|true,powered="+bools[d&1]+",south=false|true,west=false|true]")
+		}
+	}
+
+	for _, m := range [...]struct {
+		data  uint8
+		sides string
+	}{
+		{0, "down=false,east=false,north=false,south=false,up=false,west=false"},
+		{1, "down=false,east=false,north=true,south=false,up=true,west=true"},
+		{2, "down=false,east=false,north=true,south=false,up=true,west=false"},
+		{3, "down=false,east=true,north=true,south=false,up=true,west=false"},
+		{4, "down=false,east=false,north=false,south=false,up=true,west=true"},
+		{5, "down=false,east=false,north=false,south=false,up=true,west=false"},
+		{6, "down=false,east=true,north=false,south=false,up=true,west=false"},
+		{7, "down=false,east=false,north=false,south=true,up=true,west=true"},
+		{8, "down=false,east=false,north=false,south=true,up=true,west=false"},
+		{9, "down=false,east=true,north=false,south=true,up=true,west=false"},
+		{14, "down=true,east=true,north=true,south=true,up=true,west=true"},
+	} {
+		add(99, m.data, "brown_mushroom_block["+m.sides+"]")
+		add(100, m.data, "red_mushroom_block["+m.sides+"]")
+	}
+
+	for _, id := range [...]uint16{99, 100} {
+		add(id, 10, "mushroom_stem[down=false,east=true,north=true,south=true,up=false,west=true]")
+		add(id, 15, "mushroom_stem[down=true,east=true,north=true,south=true,up=true,west=true]")
+	}
+
+	rotations := strings.Join(numbers[:], "|")
+
+	add(144, 0, "skeleton_skull[rotation="+rotations+"]")
+	add(144, 1, "skeleton_skull[rotation="+rotations+"]")
+
+	for n, name := range [...]string{"sunflower", "lilac", "tall_grass", "large_fern", "rose_bush", "peony"} {
+		add(175, uint8(n), name+"[half=lower]")
+		add(175, uint8(n)|8, name+"[half=upper]")
+	}
+
+	for _, p := range [...]struct {
+		id   uint16
+		name string
+	}{
+		{170, "hay_block"},
+		{202, "purpur_pillar"},
+		{216, "bone_block"},
+	} {
+		for n, axis := range [...]string{"y", "x", "z"} {
+			add(p.id, uint8(n<<2), p.name+"[axis="+axis+"]")
+		}
+	}
+
+	// Blocks whose every state maps to the same Block are also mapped by
+	// name, so that states with properties not listed above, such as the
+	// connections of fences, are still mapped.
+	for name, b := range names {
+		if !mixed[name] {
+			DefaultBlockStates.Add(BlockState{Name: name}, b)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package minecraft
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Dimension is a namespaced identifier for a minecraft dimension, such as
+// minecraft:the_nether or mypack:sky/islands.
+type Dimension struct {
+	Namespace, Name string
+}
+
+// Vanilla dimensions.
+var (
+	Overworld = Dimension{"minecraft", "overworld"}
+	TheNether = Dimension{"minecraft", "the_nether"}
+	TheEnd    = Dimension{"minecraft", "the_end"}
+)
+
+// ParseDimension parses a dimension identifier of the form namespace:name. If
+// no namespace is given, the minecraft namespace is assumed.
+func ParseDimension(id string) (Dimension, error) {
+	d := Dimension{Namespace: "minecraft", Name: id}
+
+	if pos := strings.IndexByte(id, ':'); pos >= 0 {
+		d.Namespace, d.Name = id[:pos], id[pos+1:]
+	}
+
+	if !d.valid() {
+		return Dimension{}, InvalidDimension{id}
+	}
+
+	return d, nil
+}
+
+func (d Dimension) String() string {
+	return d.Namespace + ":" + d.Name
+}
+
+func (d Dimension) valid() bool {
+	if d.Namespace == "" || d.Name == "" || strings.Trim(d.Namespace, "abcdefghijklmnopqrstuvwxyz0123456789_.-") != "" || strings.Trim(d.Name, "abcdefghijklmnopqrstuvwxyz0123456789_.-/") != "" {
+		return false
+	}
+
+	for _, part := range strings.Split(d.Name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+
+	return d.Namespace != "." && d.Namespace != ".."
+}
+
+// dir returns the path, relative to the level directory, that contains the
+// region directory for the dimension.
+func (d Dimension) dir() string {
+	switch d {
+	case Overworld:
+		return ""
+	case TheNether:
+		return "DIM-1"
+	case TheEnd:
+		return "DIM1"
+	}
+
+	return path.Join("dimensions", d.Namespace, d.Name)
+}
+
+// WithDimension returns a FilePath for the given dimension of the same level.
+//
+// The returned FilePath shares the lock of the FilePath it was created from,
+// so locking or unlocking either affects both.
+func (p *FilePath) WithDimension(d Dimension) (*FilePath, error) {
+	if !d.valid() {
+		return nil, InvalidDimension{d.String()}
+	}
+
+	root := p
+
+	if p.root != nil {
+		root = p.root
+	}
+
+	return &FilePath{
+		root:              root,
+		regions:           root.regions,
+		dirname:           root.dirname,
+		legacyLock:        root.legacyLock,
+		readOnly:          root.readOnly,
+		dimension:         d.dir(),
+		compression:       p.compression,
+		customCompression: p.customCompression,
+	}, nil
+}
+
+// Dimensions returns a list of all dimensions in the level that contain a
+// region directory. The vanilla dimensions are listed first, followed by any
+// custom dimensions in lexical order.
+func (p *FilePath) Dimensions() []Dimension {
+	return listDimensions(os.DirFS(p.dirname))
+}
+
+func listDimensions(fsys fs.FS) []Dimension {
+	var dims, custom []Dimension
+
+	for _, d := range [...]Dimension{Overworld, TheNether, TheEnd} {
+		if isDir(fsys, path.Join(d.dir(), "region")) {
+			dims = append(dims, d)
+		}
+	}
+
+	namespaces, _ := fs.ReadDir(fsys, "dimensions")
+
+	for _, ns := range namespaces {
+		if !ns.IsDir() {
+			continue
+		}
+
+		nsDir := path.Join("dimensions", ns.Name())
+
+		fs.WalkDir(fsys, nsDir, func(name string, de fs.DirEntry, err error) error {
+			if err != nil || !de.IsDir() || name == nsDir || !isDir(fsys, path.Join(name, "region")) {
+				return nil
+			}
+
+			if d := (Dimension{Namespace: ns.Name(), Name: strings.TrimPrefix(name, nsDir+"/")}); d.valid() && d != Overworld && d != TheNether && d != TheEnd {
+				custom = append(custom, d)
+			}
+
+			return nil
+		})
+	}
+
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].String() < custom[j].String()
+	})
+
+	return append(dims, custom...)
+}
+
+func isDir(fsys fs.FS, name string) bool {
+	fi, err := fs.Stat(fsys, name)
+
+	return err == nil && fi.IsDir()
+}
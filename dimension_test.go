@@ -0,0 +1,96 @@
+package minecraft
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseDimension(t *testing.T) {
+	for n, test := range [...]struct {
+		ID        string
+		Dimension Dimension
+		Err       error
+	}{
+		{"overworld", Overworld, nil},
+		{"minecraft:the_nether", TheNether, nil},
+		{"minecraft:the_end", TheEnd, nil},
+		{"mypack:sky/islands", Dimension{"mypack", "sky/islands"}, nil},
+		{"MyPack:sky", Dimension{}, InvalidDimension{"MyPack:sky"}},
+		{"mypack:", Dimension{}, InvalidDimension{"mypack:"}},
+		{"mypack:../region", Dimension{}, InvalidDimension{"mypack:../region"}},
+		{"mypack:a//b", Dimension{}, InvalidDimension{"mypack:a//b"}},
+	} {
+		if d, err := ParseDimension(test.ID); err != test.Err {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if d != test.Dimension {
+			t.Errorf("test %d: expecting dimension %v, got %v", n+1, test.Dimension, d)
+		}
+	}
+}
+
+func TestFilePathDimensions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if dims := f.Dimensions(); len(dims) != 0 {
+		t.Fatalf("expecting no dimensions, got %v", dims)
+	}
+
+	for n, test := range [...]struct {
+		Dimension Dimension
+		Dir       string
+	}{
+		{Overworld, "region"},
+		{TheNether, "DIM-1/region"},
+		{TheEnd, "DIM1/region"},
+		{Dimension{"mypack", "sky/islands"}, "dimensions/mypack/sky/islands/region"},
+		{Dimension{"mypack", "caves"}, "dimensions/mypack/caves/region"},
+	} {
+		d, err := f.WithDimension(test.Dimension)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if err = d.SetChunk(addPos(int32(n), 0, 0)); err != nil {
+			t.Fatal(err.Error())
+		} else if _, err = os.Stat(path.Join(tempDir, test.Dir, "r.0.0.mca")); err != nil {
+			t.Errorf("test %d: %s", n+1, err)
+		} else if chunk, err := d.GetChunk(int32(n), 0); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if !chunk.Equal(addPos(int32(n), 0, 0)) {
+			t.Errorf("test %d: chunk data does not match", n+1)
+		}
+	}
+
+	if err = os.MkdirAll(path.Join(tempDir, "dimensions", "mypack", "empty", "data"), 0o755); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []Dimension{Overworld, TheNether, TheEnd, {"mypack", "caves"}, {"mypack", "sky/islands"}}
+
+	if dims := f.Dimensions(); len(dims) != len(expected) {
+		t.Fatalf("expecting %d dimensions, got %v", len(expected), dims)
+	} else {
+		for n, d := range expected {
+			if dims[n] != d {
+				t.Errorf("dimension %d: expecting %v, got %v", n+1, d, dims[n])
+			}
+		}
+	}
+
+	end, err := f.WithDimension(TheEnd)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = end.Unlock(); err != nil {
+		t.Fatal(err.Error())
+	} else if f.HasLock() {
+		t.Error("expecting lock to be released")
+	} else if _, err = end.GetChunk(2, 0); err != ErrNoLock {
+		t.Errorf("expecting ErrNoLock, got %v", err)
+	}
+
+	if _, err = f.WithDimension(Dimension{"mypack", ""}); err != (InvalidDimension{"mypack:"}) {
+		t.Errorf("expecting InvalidDimension error, got %v", err)
+	}
+}
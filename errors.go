@@ -13,6 +13,48 @@ var (
 	// ErrNoLock is an error returns by path types to indicate that the lock on the
 	// minecraft level has been locked and needs reinstating to continue.
 	ErrNoLock = errors.New("lost lock on files")
+	// ErrLocked is an error returned when trying to lock a minecraft level
+	// that is locked by another program.
+	ErrLocked = errors.New("level is locked by another program")
+	// ErrReadOnly is an error returned when trying to modify a minecraft
+	// level that was opened as read-only.
+	ErrReadOnly = errors.New("level opened as read-only")
+	// ErrNotDirectory is an error returned when trying to open a minecraft
+	// level at a path that is not a directory.
+	ErrNotDirectory = errors.New("not a directory")
+	// ErrCannotListChunks is an error returned when trying to enumerate the
+	// chunks of a Path that does not implement ChunkLister, or
+	// ChunkInfoLister.
+	ErrCannotListChunks = errors.New("path cannot list chunks")
+	// ErrNotLegacy is an error returned when trying to save a chunk in a
+	// legacy format that cannot store all of its blocks, such as blocks
+	// above a height of 128, with IDs greater than 255, or stored as block
+	// states.
+	ErrNotLegacy = errors.New("chunk cannot be stored in legacy format")
+	// ErrRegionHeader is an error returned when a region file is too short to
+	// contain a valid header.
+	ErrRegionHeader = errors.New("invalid region header")
+	// ErrOverlappingSectors is an error reported when the sectors of a chunk
+	// overlap with those of another chunk, or with the region header.
+	ErrOverlappingSectors = errors.New("chunk sectors overlap")
+	// ErrSectorsPastEOF is an error reported when the sectors of a chunk extend
+	// beyond the end of the region file.
+	ErrSectorsPastEOF = errors.New("chunk sectors past end of file")
+	// ErrZeroLengthChunk is an error reported when a chunk has no sectors or
+	// no data.
+	ErrZeroLengthChunk = errors.New("zero length chunk")
+	// ErrInvalidChunkLength is an error reported when the length of a chunk
+	// is larger than the sectors allocated to it.
+	ErrInvalidChunkLength = errors.New("chunk length exceeds allocated sectors")
+	// ErrPackedLength is an error returned when a packed long array is too
+	// short to hold the expected number of values.
+	ErrPackedLength = errors.New("packed array too short")
+	// ErrHeightLimits is an error returned when trying to set height limits
+	// that are not aligned to sections, or are beyond those of Minecraft.
+	ErrHeightLimits = errors.New("invalid height limits")
+	// ErrOutsideHeight is an error returned when trying to set a block outside
+	// of the height limits of a level or chunk.
+	ErrOutsideHeight = errors.New("y coordinate outside of height limits")
 )
 
 // MissingTagError is an error type returned when an expected tag is not found.
@@ -55,6 +97,67 @@ func (u UnknownCompression) Error() string {
 	return "unknown compression code: " + strconv.FormatUint(uint64(u.Code), 10)
 }
 
+// UnknownCustomCompression is an error returned by path types when it
+// encounters a custom compression scheme that has not been registered.
+type UnknownCustomCompression struct {
+	Name string
+}
+
+func (u UnknownCustomCompression) Error() string {
+	return "unknown custom compression: " + strconv.Quote(u.Name)
+}
+
+// InvalidDimension is an error returned when a dimension identifier is not a
+// valid namespaced identifier.
+type InvalidDimension struct {
+	ID string
+}
+
+func (i InvalidDimension) Error() string {
+	return "invalid dimension identifier: " + strconv.Quote(i.ID)
+}
+
+// InvalidBlockState is an error returned when a block state string cannot be
+// parsed.
+type InvalidBlockState struct {
+	State string
+}
+
+func (i InvalidBlockState) Error() string {
+	return "invalid block state: " + strconv.Quote(i.State)
+}
+
+// UnknownBlockState is an error returned when a block state has no mapping to
+// a Block.
+type UnknownBlockState struct {
+	State string
+}
+
+func (u UnknownBlockState) Error() string {
+	return "no block mapping for block state: " + strconv.Quote(u.State)
+}
+
+// UnknownBlock is an error returned when a Block has no mapping to a block
+// state.
+type UnknownBlock struct {
+	ID   uint16
+	Data uint8
+}
+
+func (u UnknownBlock) Error() string {
+	return "no block state mapping for block " + strconv.FormatUint(uint64(u.ID), 10) + ":" + strconv.FormatUint(uint64(u.Data), 10)
+}
+
+// UnknownBiome is an error returned when a Biome has no name that can be
+// stored in a chunk saved in the 1.18 format.
+type UnknownBiome struct {
+	Biome Biome
+}
+
+func (u UnknownBiome) Error() string {
+	return "no name for biome " + strconv.FormatUint(uint64(u.Biome), 10)
+}
+
 // ConflictError is an error return by SetChunk when trying to save a single
 // chunk multiple times during the same save operation.
 type ConflictError struct {
@@ -0,0 +1,356 @@
+package minecraft
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// FSPath is a read-only Path implementation that reads a minecraft level from
+// an fs.FS, such as an embed.FS or the contents of a zip file.
+//
+// All methods that would modify the level return ErrReadOnly.
+type FSPath struct {
+	fsys      fs.FS
+	dimension string
+	closer    io.Closer
+}
+
+// NewFSPath creates a new read-only path from the given filesystem.
+//
+// If the root of the filesystem does not contain a level.dat file, the
+// shallowest directory that does is used as the root of the level, allowing
+// for archives that contain the level within a directory.
+func NewFSPath(fsys fs.FS) (*FSPath, error) {
+	root, err := findLevelRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if root != "." {
+		if fsys, err = fs.Sub(fsys, root); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FSPath{fsys: fsys}, nil
+}
+
+// OpenZipPath opens a zipped minecraft level as a read-only path.
+//
+// The returned FSPath should be closed when no longer needed.
+func OpenZipPath(name string) (*FSPath, error) {
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := NewFSPath(z)
+	if err != nil {
+		z.Close()
+
+		return nil, err
+	}
+
+	p.closer = z
+
+	return p, nil
+}
+
+// NewZipPath reads a zipped minecraft level, of the given size, as a
+// read-only path.
+func NewZipPath(r io.ReaderAt, size int64) (*FSPath, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFSPath(z)
+}
+
+func findLevelRoot(fsys fs.FS) (string, error) {
+	if _, err := fs.Stat(fsys, "level.dat"); err == nil {
+		return ".", nil
+	}
+
+	root := "."
+	depth := -1
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			if depth >= 0 && strings.Count(name, "/") >= depth {
+				return fs.SkipDir
+			}
+
+			return nil
+		} else if d.Name() != "level.dat" {
+			return nil
+		}
+
+		dir := path.Dir(name)
+
+		if dirDepth := strings.Count(dir, "/"); depth < 0 || dirDepth < depth {
+			root = dir
+			depth = dirDepth
+		}
+
+		return nil
+	})
+
+	return root, err
+}
+
+// Close closes the underlying archive, if the FSPath was opened with
+// OpenZipPath.
+func (p *FSPath) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+
+	return nil
+}
+
+// WithDimension returns an FSPath for the given dimension of the same level.
+func (p *FSPath) WithDimension(d Dimension) (*FSPath, error) {
+	if !d.valid() {
+		return nil, InvalidDimension{d.String()}
+	}
+
+	return &FSPath{fsys: p.fsys, dimension: d.dir()}, nil
+}
+
+// Dimensions returns a list of all dimensions in the level that contain a
+// region directory. The vanilla dimensions are listed first, followed by any
+// custom dimensions in lexical order.
+func (p *FSPath) Dimensions() []Dimension {
+	return listDimensions(p.fsys)
+}
+
+func (p *FSPath) getRegionDir(s Storage) string {
+	return path.Join(p.dimension, string(s))
+}
+
+func (p *FSPath) getRegionPath(s Storage, x, z int32) string {
+	return path.Join(p.getRegionDir(s), "r."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mca")
+}
+
+func (p *FSPath) getExternalChunkPath(s Storage, x, z int32) string {
+	return path.Join(p.getRegionDir(s), "c."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mcc")
+}
+
+// GetChunk returns the chunk at chunk coords x, z.
+func (p *FSPath) GetChunk(x, z int32) (nbt.Tag, error) {
+	return p.GetStorageChunk(StorageRegion, x, z)
+}
+
+// GetStorageChunk returns the chunk data at chunk coords x, z from the given
+// storage kind.
+func (p *FSPath) GetStorageChunk(s Storage, x, z int32) (nbt.Tag, error) {
+	return readFSChunk(p.fsys, p.getRegionPath(s, x>>5, z>>5), p.getExternalChunkPath(s, x, z), x, z)
+}
+
+// readFSChunk reads the chunk at chunk coords x, z from the named region
+// file.
+func readFSChunk(fsys fs.FS, region, external string, x, z int32) (nbt.Tag, error) {
+	f, err := fsys.Open(region)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+
+		return nbt.Tag{}, err
+	}
+
+	defer f.Close()
+
+	var locations [4096]byte
+
+	if _, err = io.ReadFull(f, locations[:]); err != nil {
+		return nbt.Tag{}, err
+	}
+
+	locationSize := binary.BigEndian.Uint32(locations[((z&31)<<5|(x&31))<<2:])
+	if locationSize>>8 == 0 {
+		return nbt.Tag{}, nil
+	}
+
+	offset, size := int64(locationSize>>8<<12), int64(locationSize&255<<12)
+
+	var reader io.Reader
+
+	// Files within compressed archives can often only be read sequentially.
+	if ra, ok := f.(io.ReaderAt); ok {
+		reader = io.NewSectionReader(ra, offset, size)
+	} else if _, err = io.CopyN(io.Discard, f, offset-int64(len(locations))); err != nil {
+		return nbt.Tag{}, err
+	} else {
+		reader = io.LimitReader(f, size)
+	}
+
+	return decodeChunk(reader, func() (io.ReadCloser, error) {
+		return fsys.Open(external)
+	})
+}
+
+// SetChunk always returns ErrReadOnly.
+func (p *FSPath) SetChunk(...nbt.Tag) error {
+	return ErrReadOnly
+}
+
+// SetStorageChunk always returns ErrReadOnly.
+func (p *FSPath) SetStorageChunk(Storage, int32, int32, nbt.Tag) error {
+	return ErrReadOnly
+}
+
+// RemoveChunk always returns ErrReadOnly.
+func (p *FSPath) RemoveChunk(int32, int32) error {
+	return ErrReadOnly
+}
+
+// RemoveStorageChunk always returns ErrReadOnly.
+func (p *FSPath) RemoveStorageChunk(Storage, int32, int32) error {
+	return ErrReadOnly
+}
+
+// ReadLevelDat returns the level data.
+//
+// If level.dat is missing or cannot be read, the backup in level.dat_old will
+// be used instead, if it exists.
+func (p *FSPath) ReadLevelDat() (nbt.Tag, error) {
+	return loadLevelDat(p.fsys)
+}
+
+// WriteLevelDat always returns ErrReadOnly.
+func (p *FSPath) WriteLevelDat(nbt.Tag) error {
+	return ErrReadOnly
+}
+
+// GetRegions returns a list of region x,z coords of all generated regions.
+func (p *FSPath) GetRegions() [][2]int32 {
+	return p.GetStorageRegions(StorageRegion)
+}
+
+// GetChunks returns a list of all chunks within a region with coords x,z.
+func (p *FSPath) GetChunks(x, z int32) ([][2]int32, error) {
+	return p.GetStorageChunks(StorageRegion, x, z)
+}
+
+// GetStorageRegions returns a list of region x,z coords of all regions of the
+// given storage kind.
+func (p *FSPath) GetStorageRegions(s Storage) [][2]int32 {
+	files, _ := fs.ReadDir(p.fsys, p.getRegionDir(s))
+
+	return regionFiles(files)
+}
+
+// GetStorageChunks returns a list of all chunks of the given storage kind
+// within a region with coords x,z.
+func (p *FSPath) GetStorageChunks(s Storage, x, z int32) ([][2]int32, error) {
+	return listFSChunks(p.fsys, p.getRegionPath(s, x, z), x, z)
+}
+
+// Chunks returns an iterator over all chunks in the current dimension that
+// match all of the given filters.
+func (p *FSPath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	return p.StorageChunks(ctx, StorageRegion, filters...)
+}
+
+// StorageChunks returns an iterator over all chunks of the given storage kind
+// in the current dimension that match all of the given filters.
+func (p *FSPath) StorageChunks(ctx context.Context, s Storage, filters ...ChunkFilter) *ChunkIterator {
+	return newChunkIterator(ctx, p.GetStorageRegions(s), func(x, z int32) ([]ChunkInfo, error) {
+		return fsRegionChunks(p.fsys, p.getRegionPath(s, x, z), x, z, func(cx, cz int32) string {
+			return p.getExternalChunkPath(s, cx, cz)
+		})
+	}, filters)
+}
+
+// fsRegionChunks reads the information for all chunks within the named region
+// file, which has region coords x,z.
+func fsRegionChunks(fsys fs.FS, region string, x, z int32, external func(x, z int32) string) ([]ChunkInfo, error) {
+	f, err := fsys.Open(region)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+
+		return nil, err
+	}
+
+	defer f.Close()
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		// Files within compressed archives can often only be read
+		// sequentially.
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+
+		ra = bytes.NewReader(data)
+	}
+
+	var (
+		header    [4096]byte
+		locations [1024]uint32
+	)
+
+	if _, err = ra.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+
+	for i := range locations {
+		locations[i] = binary.BigEndian.Uint32(header[i<<2:])
+	}
+
+	return readRegionChunks(ra, &locations, x, z, func(cx, cz int32) (int64, error) {
+		fi, err := fs.Stat(fsys, external(cx, cz))
+		if err != nil {
+			return 0, err
+		}
+
+		return fi.Size(), nil
+	})
+}
+
+// listFSChunks returns a list of all chunks within the named region file,
+// which has region coords x,z.
+func listFSChunks(fsys fs.FS, region string, x, z int32) ([][2]int32, error) {
+	f, err := fsys.Open(region)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var locations [4096]byte
+
+	if _, err = io.ReadFull(f, locations[:]); err != nil {
+		return nil, err
+	}
+
+	baseX := x << 5
+	baseZ := z << 5
+
+	var toRet [][2]int32
+
+	for i := 0; i < 1024; i++ {
+		if binary.BigEndian.Uint32(locations[i<<2:]) > 0 {
+			toRet = append(toRet, [2]int32{baseX + int32(i&31), baseZ + int32(i>>5)})
+		}
+	}
+
+	return toRet, nil
+}
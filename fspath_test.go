@@ -0,0 +1,188 @@
+package minecraft
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func makeTestLevel(t *testing.T) (string, nbt.Tag) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	levelDat := nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Data", nbt.Compound{
+			nbt.NewTag("LevelName", nbt.String("test")),
+		}),
+	})
+
+	nether, err := f.WithDimension(TheNether)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = f.WriteLevelDat(levelDat); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.SetChunk(addPos(0, 0, 0), addPos(1, 0, 3), addPos(-40, 7, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = nether.SetChunk(addPos(5, 5, 1)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return tempDir, levelDat
+}
+
+func testFSPath(t *testing.T, p *FSPath, levelDat nbt.Tag) {
+	t.Helper()
+
+	if l, err := p.ReadLevelDat(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !l.Equal(levelDat) {
+		t.Error("level.dat does not match")
+	}
+
+	for _, c := range [...]struct {
+		x, z     int32
+		chunkNum uint8
+	}{
+		{0, 0, 0},
+		{1, 0, 3},
+		{-40, 7, 2},
+	} {
+		if chunk, err := p.GetChunk(c.x, c.z); err != nil {
+			t.Errorf("chunk %d,%d: unexpected error: %s", c.x, c.z, err)
+		} else if !chunk.Equal(addPos(c.x, c.z, c.chunkNum)) {
+			t.Errorf("chunk %d,%d: chunk data does not match", c.x, c.z)
+		}
+	}
+
+	if chunk, err := p.GetChunk(2, 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if chunk.TagID() != 0 {
+		t.Error("expecting no chunk")
+	}
+
+	if regions := p.GetRegions(); len(regions) != 2 {
+		t.Errorf("expecting 2 regions, got %v", regions)
+	} else if chunks, err := p.GetChunks(0, 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if len(chunks) != 2 {
+		t.Errorf("expecting 2 chunks, got %v", chunks)
+	}
+
+	var infos []ChunkInfo
+
+	it := p.Chunks(context.Background())
+
+	for it.Next() {
+		infos = append(infos, it.Info())
+	}
+
+	if err := it.Err(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if len(infos) != 3 {
+		t.Errorf("expecting 3 chunks, got %v", infos)
+	} else if c := infos[0]; c.X != -40 || c.Z != 7 || c.Size <= 0 || c.Modified.IsZero() || c.Compression != Zlib {
+		t.Errorf("incorrect chunk information: %v", c)
+	}
+
+	if dims := p.Dimensions(); len(dims) != 2 || dims[0] != Overworld || dims[1] != TheNether {
+		t.Errorf("expecting overworld and nether dimensions, got %v", dims)
+	} else if nether, err := p.WithDimension(TheNether); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if chunk, err := nether.GetChunk(5, 5); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !chunk.Equal(addPos(5, 5, 1)) {
+		t.Error("nether chunk data does not match")
+	}
+
+	if err := p.SetChunk(addPos(2, 0, 0)); err != ErrReadOnly {
+		t.Errorf("SetChunk: expecting ErrReadOnly, got %v", err)
+	} else if err = p.RemoveChunk(0, 0); err != ErrReadOnly {
+		t.Errorf("RemoveChunk: expecting ErrReadOnly, got %v", err)
+	} else if err = p.WriteLevelDat(levelDat); err != ErrReadOnly {
+		t.Errorf("WriteLevelDat: expecting ErrReadOnly, got %v", err)
+	}
+}
+
+func TestFSPath(t *testing.T) {
+	dir, levelDat := makeTestLevel(t)
+
+	p, err := NewFSPath(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testFSPath(t, p, levelDat)
+}
+
+func TestZipPath(t *testing.T) {
+	dir, levelDat := makeTestLevel(t)
+
+	var buf bytes.Buffer
+
+	z := zip.NewWriter(&buf)
+
+	if err := fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		w, err := z.Create(path.Join("backup", "world", name))
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+
+		return err
+	}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = z.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, err := NewZipPath(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testFSPath(t, p, levelDat)
+
+	zipFile := filepath.Join(t.TempDir(), "backup.zip")
+
+	if err = os.WriteFile(zipFile, buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if p, err = OpenZipPath(zipFile); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testFSPath(t, p, levelDat)
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,243 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// ChunkInfo contains the information stored in a region file about a single
+// chunk.
+//
+// Size is the size of the compressed chunk data, in bytes, which for external
+// chunks is the size of the .mcc file.
+type ChunkInfo struct {
+	X, Z        int32
+	Modified    time.Time
+	Size        int64
+	Compression byte
+	External    bool
+}
+
+// ChunkFilter is used to select which chunks are returned by a ChunkIterator.
+type ChunkFilter func(ChunkInfo) bool
+
+// ModifiedSince returns a ChunkFilter that selects chunks that have been
+// modified at or after the given time.
+func ModifiedSince(t time.Time) ChunkFilter {
+	return func(c ChunkInfo) bool {
+		return !c.Modified.Before(t)
+	}
+}
+
+// InRegion returns a ChunkFilter that selects chunks within the region at
+// region coords x, z.
+func InRegion(x, z int32) ChunkFilter {
+	return func(c ChunkInfo) bool {
+		return c.X>>5 == x && c.Z>>5 == z
+	}
+}
+
+// ChunkInfoLister is implemented by Path types that can iterate over the
+// chunks they contain, along with information about each chunk.
+type ChunkInfoLister interface {
+	// Chunks returns an iterator over all chunks that match all of the
+	// given filters.
+	Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator
+}
+
+// ChunkIterator iterates over the chunks of a dimension.
+//
+// Each region is read in turn, so chunks modified after the iterator has
+// read their region will not be reflected in the results.
+type ChunkIterator struct {
+	ctx     context.Context
+	filters []ChunkFilter
+	regions [][2]int32
+	region  func(x, z int32) ([]ChunkInfo, error)
+	chunks  []ChunkInfo
+	info    ChunkInfo
+	err     error
+}
+
+func newChunkIterator(ctx context.Context, regions [][2]int32, region func(x, z int32) ([]ChunkInfo, error), filters []ChunkFilter) *ChunkIterator {
+	return &ChunkIterator{
+		ctx:     ctx,
+		filters: filters,
+		regions: regions,
+		region:  region,
+	}
+}
+
+// Chunks returns an iterator over all chunks in the current dimension that
+// match all of the given filters.
+func (p *FilePath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	return p.StorageChunks(ctx, StorageRegion, filters...)
+}
+
+// StorageChunks returns an iterator over all chunks of the given storage kind
+// in the current dimension that match all of the given filters.
+func (p *FilePath) StorageChunks(ctx context.Context, s Storage, filters ...ChunkFilter) *ChunkIterator {
+	if !p.canRead() {
+		return &ChunkIterator{err: ErrNoLock}
+	}
+
+	return newChunkIterator(ctx, p.getRegions(s), func(x, z int32) ([]ChunkInfo, error) {
+		return p.regionChunks(s, x, z)
+	}, filters)
+}
+
+// Next advances the iterator to the next chunk, returning false when there
+// are no more chunks or an error has occurred.
+func (c *ChunkIterator) Next() bool {
+	for c.err == nil {
+		if c.err = c.ctx.Err(); c.err != nil {
+			break
+		}
+
+		if len(c.chunks) > 0 {
+			c.info = c.chunks[0]
+			c.chunks = c.chunks[1:]
+
+			if c.matches(c.info) {
+				return true
+			}
+
+			continue
+		} else if len(c.regions) == 0 {
+			break
+		}
+
+		r := c.regions[0]
+		c.regions = c.regions[1:]
+		c.chunks, c.err = c.region(r[0], r[1])
+	}
+
+	c.info = ChunkInfo{}
+
+	return false
+}
+
+func (c *ChunkIterator) matches(info ChunkInfo) bool {
+	for _, f := range c.filters {
+		if !f(info) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Info returns the information for the current chunk.
+func (c *ChunkIterator) Info() ChunkInfo {
+	return c.info
+}
+
+// Err returns any error that stopped the iteration.
+func (c *ChunkIterator) Err() error {
+	return c.err
+}
+
+func (p *FilePath) regionChunks(s Storage, x, z int32) ([]ChunkInfo, error) {
+	l := p.regionLock(s, x, z)
+
+	l.RLock()
+	defer l.RUnlock()
+
+	r, err := p.getRegion(s, x, z, false)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer p.releaseRegion(r)
+
+	return readRegionChunks(r.f, &r.locations, x, z, func(cx, cz int32) (int64, error) {
+		fi, err := os.Stat(p.getExternalChunkPath(s, cx, cz))
+		if err != nil {
+			return 0, err
+		}
+
+		return fi.Size(), nil
+	})
+}
+
+// groupChunks groups the given chunks by region, returning the sorted region
+// coords along with the chunks of each region, sorted by z and then x.
+func groupChunks(chunks []ChunkInfo) ([][2]int32, map[[2]int32][]ChunkInfo) {
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].Z == chunks[j].Z {
+			return chunks[i].X < chunks[j].X
+		}
+
+		return chunks[i].Z < chunks[j].Z
+	})
+
+	grouped := make(map[[2]int32][]ChunkInfo)
+
+	for _, c := range chunks {
+		r := [2]int32{c.X >> 5, c.Z >> 5}
+		grouped[r] = append(grouped[r], c)
+	}
+
+	regions := make([][2]int32, 0, len(grouped))
+
+	for r := range grouped {
+		regions = append(regions, r)
+	}
+
+	sortCoords(regions)
+
+	return regions, grouped
+}
+
+// readRegionChunks reads the timestamps and chunk headers of the region file
+// at region coords x, z, using the external function to get the size of
+// external chunks.
+func readRegionChunks(r io.ReaderAt, locations *[1024]uint32, x, z int32, external func(x, z int32) (int64, error)) ([]ChunkInfo, error) {
+	var (
+		timestamps [4096]byte
+		header     [5]byte
+		chunks     []ChunkInfo
+	)
+
+	if _, err := r.ReadAt(timestamps[:], 4096); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for i, loc := range locations {
+		if loc>>8 == 0 {
+			continue
+		}
+
+		if _, err := r.ReadAt(header[:], int64(loc>>8<<12)); err != nil {
+			return nil, err
+		}
+
+		info := ChunkInfo{
+			X:           x<<5 | int32(i&31),
+			Z:           z<<5 | int32(i>>5),
+			Modified:    time.Unix(int64(binary.BigEndian.Uint32(timestamps[i<<2:])), 0),
+			Size:        int64(binary.BigEndian.Uint32(header[:])) - 1,
+			Compression: header[4] &^ externalChunk,
+			External:    header[4]&externalChunk != 0,
+		}
+
+		if info.External {
+			size, err := external(info.X, info.Z)
+			if err != nil {
+				return nil, err
+			}
+
+			info.Size = size
+		}
+
+		chunks = append(chunks, info)
+	}
+
+	return chunks, nil
+}
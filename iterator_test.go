@@ -0,0 +1,216 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestFilePathChunks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir, WriteCompression(GZip))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	start := time.Now().Add(-time.Minute)
+
+	if err = f.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1), addPos(-1, 40, 2)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	region, err := os.OpenFile(path.Join(tempDir, "region", "r.0.0.mca"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var timestamp [4]byte
+
+	binary.BigEndian.PutUint32(timestamp[:], 1000)
+
+	_, err = region.WriteAt(timestamp[:], 4096+4)
+
+	region.Close()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	collect := func(ctx context.Context, filters ...ChunkFilter) ([]ChunkInfo, error) {
+		var chunks []ChunkInfo
+
+		it := f.Chunks(ctx, filters...)
+
+		for it.Next() {
+			chunks = append(chunks, it.Info())
+		}
+
+		return chunks, it.Err()
+	}
+
+	chunks, err := collect(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(chunks) != 3 {
+		t.Fatalf("expecting 3 chunks, got %d", len(chunks))
+	}
+
+	for _, c := range chunks {
+		if c.Compression != GZip {
+			t.Errorf("chunk %d,%d: expecting compression %d, got %d", c.X, c.Z, GZip, c.Compression)
+		} else if c.Size <= 0 || c.External {
+			t.Errorf("chunk %d,%d: invalid size %d", c.X, c.Z, c.Size)
+		} else if c.X == 1 && c.Z == 0 {
+			if !c.Modified.Equal(time.Unix(1000, 0)) {
+				t.Errorf("chunk %d,%d: expecting modified time %s, got %s", c.X, c.Z, time.Unix(1000, 0), c.Modified)
+			}
+		} else if c.Modified.Before(start) {
+			t.Errorf("chunk %d,%d: unexpected modified time %s", c.X, c.Z, c.Modified)
+		}
+	}
+
+	if chunks, err = collect(context.Background(), ModifiedSince(start)); err != nil {
+		t.Fatal(err.Error())
+	} else if len(chunks) != 2 {
+		t.Errorf("expecting 2 recently modified chunks, got %d", len(chunks))
+	}
+
+	for _, test := range [...]struct {
+		since time.Time
+		count int
+	}{
+		{time.Unix(999, 0), 3},
+		{time.Unix(1000, 0), 3},
+		{time.Unix(1001, 0), 2},
+	} {
+		if chunks, err = collect(context.Background(), ModifiedSince(test.since)); err != nil {
+			t.Fatal(err.Error())
+		} else if len(chunks) != test.count {
+			t.Errorf("since %s: expecting %d chunks, got %d", test.since, test.count, len(chunks))
+		}
+	}
+
+	if chunks, err = collect(context.Background(), ModifiedSince(start), InRegion(-1, 1)); err != nil {
+		t.Fatal(err.Error())
+	} else if len(chunks) != 1 || chunks[0].X != -1 || chunks[0].Z != 40 {
+		t.Errorf("expecting only chunk -1,40, got %v", chunks)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	if chunks, err = collect(ctx); err != context.Canceled {
+		t.Errorf("expecting context.Canceled, got %v", err)
+	} else if len(chunks) != 0 {
+		t.Errorf("expecting no chunks, got %d", len(chunks))
+	}
+}
+
+func TestChunkInfoLister(t *testing.T) {
+	chunks := []nbt.Tag{addPos(0, 0, 0), addPos(40, 0, 1), addPos(-1, 0, 2), addPos(1, 0, 0)}
+	levelDat := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{nbt.NewTag("LevelName", nbt.String("chunks"))})})
+
+	mem := NewMemPath()
+
+	if err := mem.SetChunk(chunks...); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir := t.TempDir()
+
+	f, err := NewFilePath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.SetChunk(chunks...); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.WriteLevelDat(levelDat); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fsp, err := NewFSPath(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mcr, err := OpenMcRegionPath(makeMcRegionLevel(t))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	alpha, err := NewAlphaPath(t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, c := range [...][2]int32{{0, 0}, {-1, 2}} {
+		chunk, err := ConvertMcRegionChunk(mcRegionChunk(c[0], c[1]))
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if err = alpha.SetChunk(chunk); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	base := NewMemPath()
+
+	if err = base.SetChunk(chunks...); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	overlay := NewOverlayPath(base, NewMemPath())
+
+	if err = overlay.SetChunk(addPos(2, 0, 1)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = overlay.RemoveChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, test := range [...]struct {
+		name     string
+		path     ChunkInfoLister
+		chunks   [][2]int32
+		modified bool
+	}{
+		{"MemPath", mem, [][2]int32{{-1, 0}, {0, 0}, {1, 0}, {40, 0}}, false},
+		{"FSPath", fsp, [][2]int32{{-1, 0}, {0, 0}, {1, 0}, {40, 0}}, true},
+		{"McRegionPath", mcr, [][2]int32{{-1, 2}, {0, 0}}, true},
+		{"AlphaPath", alpha, [][2]int32{{-1, 2}, {0, 0}}, true},
+		{"OverlayPath", overlay, [][2]int32{{-1, 0}, {0, 0}, {2, 0}, {40, 0}}, false},
+		{"OverlayPath over FSPath", NewOverlayPath(fsp, NewMemPath()), [][2]int32{{-1, 0}, {0, 0}, {1, 0}, {40, 0}}, true},
+	} {
+		var got [][2]int32
+
+		it := test.path.Chunks(context.Background())
+
+		for it.Next() {
+			info := it.Info()
+			got = append(got, [2]int32{info.X, info.Z})
+
+			if info.Size <= 0 {
+				t.Errorf("%s: chunk %d,%d: invalid size %d", test.name, info.X, info.Z, info.Size)
+			} else if info.Modified.IsZero() == test.modified {
+				t.Errorf("%s: chunk %d,%d: unexpected modified time %s", test.name, info.X, info.Z, info.Modified)
+			}
+		}
+
+		if err = it.Err(); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		} else if fmt.Sprint(got) != fmt.Sprint(test.chunks) {
+			t.Errorf("%s: expecting chunks %v, got %v", test.name, test.chunks, got)
+		}
+	}
+
+	if err = NewOverlayPath(mem, struct{ Path }{NewMemPath()}).Chunks(context.Background()).Err(); err != ErrCannotListChunks {
+		t.Errorf("expecting ErrCannotListChunks, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package minecraft
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+)
+
+// The journal is used to make in-place modifications to region files crash
+// safe. All writes to a region are first collected and written, with a
+// checksum, to a journal file alongside the region. Only once that file has
+// been synced to disk are the writes applied to the region itself, after
+// which the journal is removed.
+//
+// If a crash occurs while the journal is being written, the checksum will not
+// match and the journal is discarded, leaving the region untouched. If a crash
+// occurs while the region is being written, the complete journal is replayed
+// the next time the region is opened.
+
+const (
+	journalMagic  = "MCJ\x01"
+	journalEnd    = ^uint64(0)
+	journalSuffix = ".journal"
+)
+
+var (
+	errJournalSeek    = errors.New("invalid journal seek")
+	errJournalCorrupt = errors.New("corrupt journal")
+)
+
+type journalRecord struct {
+	offset int64
+	data   []byte
+}
+
+type journal struct {
+	pos     int64
+	records []journalRecord
+}
+
+func (j *journal) Write(p []byte) (int, error) {
+	if l := len(j.records) - 1; l >= 0 && j.records[l].offset+int64(len(j.records[l].data)) == j.pos {
+		j.records[l].data = append(j.records[l].data, p...)
+	} else {
+		j.records = append(j.records, journalRecord{offset: j.pos, data: append([]byte(nil), p...)})
+	}
+
+	j.pos += int64(len(p))
+
+	return len(p), nil
+}
+
+func (j *journal) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += j.pos
+	default:
+		return j.pos, errJournalSeek
+	}
+
+	if offset < 0 {
+		return j.pos, errJournalSeek
+	}
+
+	j.pos = offset
+
+	return offset, nil
+}
+
+func (j *journal) encode() []byte {
+	size := len(journalMagic) + 12
+
+	for _, r := range j.records {
+		size += 12 + len(r.data)
+	}
+
+	buf := make([]byte, size)
+	pos := copy(buf, journalMagic)
+
+	for _, r := range j.records {
+		binary.BigEndian.PutUint64(buf[pos:], uint64(r.offset))
+		binary.BigEndian.PutUint32(buf[pos+8:], uint32(len(r.data)))
+
+		pos += 12 + copy(buf[pos+12:], r.data)
+	}
+
+	binary.BigEndian.PutUint64(buf[pos:], journalEnd)
+	binary.BigEndian.PutUint32(buf[pos+8:], crc32.ChecksumIEEE(buf[:pos+8]))
+
+	return buf
+}
+
+func decodeJournal(buf []byte) (*journal, error) {
+	if len(buf) < len(journalMagic)+12 || string(buf[:len(journalMagic)]) != journalMagic {
+		return nil, errJournalCorrupt
+	}
+
+	l := len(buf) - 4
+
+	if crc32.ChecksumIEEE(buf[:l]) != binary.BigEndian.Uint32(buf[l:]) {
+		return nil, errJournalCorrupt
+	}
+
+	j := new(journal)
+
+	for pos := len(journalMagic); ; {
+		if pos+8 > l {
+			return nil, errJournalCorrupt
+		}
+
+		offset := binary.BigEndian.Uint64(buf[pos:])
+		pos += 8
+
+		if offset == journalEnd {
+			if pos != l {
+				return nil, errJournalCorrupt
+			}
+
+			return j, nil
+		} else if pos+4 > l {
+			return nil, errJournalCorrupt
+		}
+
+		length := int(binary.BigEndian.Uint32(buf[pos:]))
+		pos += 4
+
+		if length > l-pos {
+			return nil, errJournalCorrupt
+		}
+
+		j.records = append(j.records, journalRecord{offset: int64(offset), data: buf[pos : pos+length]})
+		pos += length
+	}
+}
+
+func (j *journal) apply(f *os.File) error {
+	for _, r := range j.records {
+		if _, err := f.WriteAt(r.data, r.offset); err != nil {
+			return err
+		}
+	}
+
+	return f.Sync()
+}
+
+// commit writes the journal to disk, applies it to the given file and then
+// removes the journal.
+func (j *journal) commit(f *os.File) error {
+	if len(j.records) == 0 {
+		return nil
+	}
+
+	name := f.Name() + journalSuffix
+
+	if err := writeSync(name, j.encode()); err != nil {
+		os.Remove(name)
+
+		return err
+	}
+
+	syncDir(path.Dir(name))
+
+	if err := j.apply(f); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// replayJournal applies any complete journal that exists for the given file,
+// discarding any incomplete journal.
+func replayJournal(f *os.File) error {
+	name := f.Name() + journalSuffix
+
+	buf, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if j, err := decodeJournal(buf); err == nil {
+		if err = j.apply(f); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(name)
+}
+
+func hasJournal(name string) bool {
+	_, err := os.Stat(name + journalSuffix)
+
+	return err == nil
+}
+
+func writeSync(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// writeFileAtomic creates a temporary file next to the named file, fills it
+// using the given function and then, once synced to disk, renames it over the
+// named file.
+func writeFileAtomic(name string, fn func(io.Writer) error) error {
+	tmp := name + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(f); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+
+		return err
+	}
+
+	syncDir(path.Dir(name))
+
+	return nil
+}
+
+// syncDir attempts to sync a directory so that renames and file creations
+// within it are persisted. Not all platforms support this, so errors are
+// ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,65 @@
+package minecraft
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestJournalReplay(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = f.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	regionFile := path.Join(tempDir, "region", "r.0.0.mca")
+	journalFile := regionFile + journalSuffix
+
+	var j journal
+
+	j.Seek(4, io.SeekStart)
+	j.Write([]byte{0, 0, 0, 0})
+
+	data := j.encode()
+
+	if err = os.WriteFile(journalFile, data[:len(data)-1], 0o666); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if c, err := f.GetChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !c.Equal(addPos(1, 0, 1)) {
+		t.Errorf("incomplete journal should not have been applied")
+	} else if _, err = os.Stat(journalFile); !os.IsNotExist(err) {
+		t.Errorf("expecting incomplete journal to be removed")
+	}
+
+	if err = os.WriteFile(journalFile, data, 0o666); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if c, err := f.GetChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if c.TagID() != 0 {
+		t.Errorf("complete journal should have been applied")
+	} else if _, err = os.Stat(journalFile); !os.IsNotExist(err) {
+		t.Errorf("expecting complete journal to be removed")
+	}
+
+	if c, err := f.GetChunk(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !c.Equal(addPos(0, 0, 0)) {
+		t.Errorf("unrelated chunk was modified")
+	}
+}
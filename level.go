@@ -22,6 +22,9 @@ type Level struct {
 	chunks    map[uint64]*chunk
 	levelData nbt.Compound
 	changed   bool
+	mapper    BlockMapper
+	minY      int32
+	height    int32
 }
 
 // NewLevel creates/Loads a minecraft level from the given path.
@@ -110,17 +113,91 @@ func NewLevel(location Path) (*Level, error) {
 		}
 	}
 
+	dataVersion, _ := data.Get("DataVersion").Data().(nbt.Int)
+	minY, height := defaultHeightLimits(location, int32(dataVersion))
+
 	return &Level{
 		location,
 		make(map[uint64]*chunk),
 		levelDat.Data().(nbt.Compound).Get("Data").Data().(nbt.Compound),
 		changed,
+		DefaultBlockStates,
+		minY,
+		height,
 	}, nil
 }
 
+// defaultHeightLimits returns the height limits for a level with the given
+// data version, which, from 1.18, are extended for all dimensions other than
+// the nether and the end.
+func defaultHeightLimits(location Path, dataVersion int32) (int32, int32) {
+	if dataVersion >= dataVersionContainer {
+		if fp, ok := location.(*FilePath); !ok || fp.dimension != TheNether.dir() && fp.dimension != TheEnd.dir() {
+			return extendedMinY, extendedHeight
+		}
+	}
+
+	return legacyMinY, legacyHeight
+}
+
+// HeightLimits returns the lowest y coord that can hold a block, and the
+// number of blocks above it, inclusive, that can also hold blocks.
+func (l *Level) HeightLimits() (minY, height int32) {
+	return l.minY, l.height
+}
+
+// SetHeightLimits sets the range of y coords that can hold blocks. Both minY
+// and height must be multiples of 16, and the range must be within -2032 and
+// 2032.
+//
+// The default limits are from -64 to 320 for levels saved by Minecraft 1.18 and
+// later, except for the nether and the end, and from 0 to 256 otherwise.
+//
+// Chunks saved before 1.18 always have limits of 0 to 256. Sections outside of
+// the limits of a chunk are preserved, but cannot be accessed.
+func (l *Level) SetHeightLimits(minY, height int32) error {
+	if minY&15 != 0 || height&15 != 0 || height <= 0 || minY < -2032 || minY+height > 2032 {
+		return ErrHeightLimits
+	}
+
+	l.minY = minY
+	l.height = height
+
+	for _, c := range l.chunks {
+		if err := c.setHeightLimits(minY, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inBounds returns true if the given y coord is within the height limits of
+// the level.
+func (l *Level) inBounds(y int32) bool {
+	return y >= l.minY && y < l.minY+l.height
+}
+
+// SetBlockMapper sets the BlockMapper used to convert between blocks and the
+// block states of chunks saved by Minecraft 1.13 and later. The default is
+// DefaultBlockStates.
+//
+// Block states with no mapping are read as air, but are preserved unless
+// overwritten.
+func (l *Level) SetBlockMapper(m BlockMapper) {
+	l.mapper = m
+
+	for _, c := range l.chunks {
+		c.setMapper(m)
+	}
+}
+
 // GetBlock gets the block at coordinates x, y, z.
+//
+// If the block is stored as a block state with no mapping to an ID and Data,
+// an unmapped Block is returned; see Block.Unmapped.
 func (l *Level) GetBlock(x, y, z int32) (Block, error) {
-	if y < 0 {
+	if !l.inBounds(y) {
 		return Block{}, nil
 	}
 
@@ -135,15 +212,22 @@ func (l *Level) GetBlock(x, y, z int32) (Block, error) {
 }
 
 // SetBlock sets the block at coordinates x, y, z. Also processes any lighting updates if applicable.
+//
+// Returns ErrOutsideHeight if y is outside of the height limits of the level
+// or chunk.
 func (l *Level) SetBlock(x, y, z int32, block Block) error {
-	if y < 0 {
-		y = 0
+	if !l.inBounds(y) {
+		return ErrOutsideHeight
 	}
 
-	var (
-		c   *chunk
-		err error
-	)
+	c, err := l.getChunk(x, z, true)
+	if err != nil {
+		return err
+	} else if !c.inBounds(y) {
+		return ErrOutsideHeight
+	} else if err = c.canStore(block); err != nil {
+		return err
+	}
 
 	for mx := x - 1; mx <= x+1; mx++ {
 		for mz := z - 1; mz <= z+1; mz++ {
@@ -151,7 +235,7 @@ func (l *Level) SetBlock(x, y, z int32, block Block) error {
 				return err
 			}
 
-			for my := y + 16; my >= 0; my -= 16 {
+			for my := y + 16; my >= l.minY; my -= 16 {
 				if c.createSection(my) {
 					break
 				}
@@ -209,15 +293,15 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 	list[0] = &lightCoords{x, y, z, getLight(c, x, y, z)}
 	changed := boolmap.NewMap()
 
-	changed.SetBool((uint64(y)<<10)|(uint64(z&31)<<5)|uint64(x&31), true)
+	changed.SetBool(l.lightPos(x, y, z), true)
 
 	if darker { // reset lighting on all blocks affected by the changed one (only applies if darker)
 		setLight(c, x, y, z, 0)
 
 		for i := 0; i < len(list); i++ {
-			for _, s := range surroundingBlocks(list[i].x, list[i].y, list[i].z) {
+			for _, s := range l.surroundingBlocks(list[i].x, list[i].y, list[i].z) {
 				mx, my, mz := s[0], s[1], s[2]
-				pos := (uint64(my) << 10) | (uint64(mz&31) << 5) | uint64(mx&31)
+				pos := l.lightPos(mx, my, mz)
 
 				if changed.GetBool(pos) {
 					continue
@@ -227,7 +311,7 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 					return err
 				} else if c == nil {
 					continue
-				} else if ys := my >> 4; my < 16 && c.sections[ys] == nil {
+				} else if c.missingSection(my) {
 					changed.SetBool(pos, true)
 
 					continue
@@ -261,9 +345,9 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 
 		c.SetBlockLight(x, y, z, source)
 
-		for _, s := range surroundingBlocks(x, y, z) {
+		for _, s := range l.surroundingBlocks(x, y, z) {
 			mx, my, mz := s[0], s[1], s[2]
-			pos := (uint64(my) << 10) | (uint64(mz&31) << 5) | uint64(mx&31)
+			pos := l.lightPos(mx, my, mz)
 
 			if changed.GetBool(pos) {
 				continue
@@ -273,7 +357,7 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 				return err
 			} else if c == nil {
 				continue
-			} else if ys := my >> 4; my < 16 && c.sections[ys] == nil {
+			} else if c.missingSection(my) {
 				changed.SetBool(pos, true)
 
 				continue
@@ -292,7 +376,7 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 		newLight := uint8(0)
 		c, _ = l.getChunk(mx, mz, false)
 
-		changed.SetBool((uint64(my)<<10)|(uint64(mz&31)<<5)|uint64(mx&31), false)
+		changed.SetBool(l.lightPos(mx, my, mz), false)
 
 		if skyLight && my >= c.GetHeight(mx, mz) { // Determine correct light level...
 			newLight = 15
@@ -301,7 +385,7 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 		} else {
 			var d *chunk
 
-			for _, s := range surroundingBlocks(mx, my, mz) {
+			for _, s := range l.surroundingBlocks(mx, my, mz) {
 				nx, ny, nz := s[0], s[1], s[2]
 
 				if d, err = l.getChunk(nx, nz, false); err != nil {
@@ -325,9 +409,9 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 		setLight(c, mx, my, mz, newLight)
 
 		if newLight > list[0].lightLevel || (darker && newLight == list[0].lightLevel) {
-			for _, s := range surroundingBlocks(mx, my, mz) {
+			for _, s := range l.surroundingBlocks(mx, my, mz) {
 				mx, my, mz = s[0], s[1], s[2]
-				pos := (uint64(my) << 10) | (uint64(mz&31) << 5) | uint64(mx&31)
+				pos := l.lightPos(mx, my, mz)
 
 				if changed.GetBool(pos) {
 					continue
@@ -337,7 +421,7 @@ func (l *Level) genLighting(x, y, z int32, skyLight, darker bool, source uint8)
 					return err
 				} else if c == nil {
 					continue
-				} else if ys := my >> 4; ys < 16 && c.sections[ys] == nil {
+				} else if c.missingSection(my) {
 					changed.SetBool(pos, true)
 
 					continue
@@ -372,6 +456,8 @@ func (l *Level) SetBiome(x, z int32, biome Biome) error {
 	c, err := l.getChunk(x, z, true)
 	if err != nil {
 		return err
+	} else if err = c.canStoreBiome(biome); err != nil {
+		return err
 	}
 
 	c.SetBiome(x, z, biome)
@@ -380,10 +466,14 @@ func (l *Level) SetBiome(x, z int32, biome Biome) error {
 }
 
 // GetHeight returns the y coordinate for the highest non-transparent block at column x, z.
+//
+// If there is no such block, the lowest y coordinate of the chunk is returned.
 func (l *Level) GetHeight(x, z int32) (int32, error) {
 	c, err := l.getChunk(x, z, false)
-	if err != nil || c == nil {
+	if err != nil {
 		return 0, err
+	} else if c == nil {
+		return l.minY, nil
 	}
 
 	return c.GetHeight(x, z), nil
@@ -406,9 +496,21 @@ func (l *Level) getChunk(x, z int32, create bool) (*chunk, error) {
 				return nil, err
 			}
 
+			chunk.setMapper(l.mapper)
+
+			if err = chunk.setHeightLimits(l.minY, l.height); err != nil {
+				return nil, err
+			}
+
 			l.chunks[pos] = chunk
 		} else if create {
-			l.chunks[pos], _ = newChunk(x, z, nbt.Tag{})
+			dataVersion, _ := l.levelData.Get("DataVersion").Data().(nbt.Int)
+			chunk, _ := newChunk(x, z, emptyChunk(x, z, int32(dataVersion)))
+
+			chunk.setMapper(l.mapper)
+			chunk.setHeightLimits(l.minY, l.height)
+
+			l.chunks[pos] = chunk
 		}
 	}
 
@@ -439,12 +541,27 @@ func (l *Level) Save() error {
 }
 
 // Close closes all open chunks, but does not save them.
-func (l *Level) Close() {
+//
+// If the underlying Path holds a lock on the level, such as a FilePath, the
+// lock is released.
+func (l *Level) Close() error {
 	l.changed = false
 	l.chunks = make(map[uint64]*chunk)
+
+	if u, ok := l.path.(interface{ Unlock() error }); ok {
+		return u.Unlock()
+	}
+
+	return nil
+}
+
+// lightPos returns a unique position for the given coords, within the area
+// that can be affected by a single lighting update.
+func (l *Level) lightPos(x, y, z int32) uint64 {
+	return (uint64(y-l.minY) << 10) | (uint64(z&31) << 5) | uint64(x&31)
 }
 
-func surroundingBlocks(x, y, z int32) [][3]int32 {
+func (l *Level) surroundingBlocks(x, y, z int32) [][3]int32 {
 	sB := [6][3]int32{
 		{x, y - 1, z},
 		{x - 1, y, z},
@@ -453,14 +570,15 @@ func surroundingBlocks(x, y, z int32) [][3]int32 {
 		{x, y, z + 1},
 		{x, y + 1, z},
 	}
+	s := sB[:]
 
-	if y == 0 {
-		return sB[1:]
+	if y <= l.minY {
+		s = s[1:]
 	}
 
-	if y == 255 {
-		return sB[:5]
+	if y >= l.minY+l.height-1 {
+		s = s[:len(s)-1]
 	}
 
-	return sB[:]
+	return s
 }
@@ -1,6 +1,10 @@
 package minecraft
 
-import "testing"
+import (
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
 
 func TestNewLevel(t *testing.T) {
 	m := NewMemPath()
@@ -440,6 +444,113 @@ func TestBlockLight(t *testing.T) {
 	}
 }
 
+func TestHeightLimits(t *testing.T) {
+	m := NewMemPath()
+	l, err := NewLevel(m)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l.levelData.Set(nbt.NewTag("DataVersion", nbt.Int(2975)))
+
+	for _, limits := range [...][2]int32{
+		{-60, 384},
+		{-64, 380},
+		{0, 0},
+		{-2048, 256},
+		{1792, 256},
+	} {
+		if err = l.SetHeightLimits(limits[0], limits[1]); err != ErrHeightLimits {
+			t.Errorf("expecting ErrHeightLimits for limits %d, %d, got %v", limits[0], limits[1], err)
+		}
+	}
+
+	if err = l.SetHeightLimits(-64, 384); err != nil {
+		t.Fatal(err.Error())
+	} else if h, _ := l.GetHeight(0, 0); h != -64 {
+		t.Errorf("expecting empty height of -64, got %d", h)
+	}
+
+	for _, y := range [...]int32{-65, 320} {
+		if err = l.SetBlock(0, y, 0, Block{ID: 1}); err != ErrOutsideHeight {
+			t.Errorf("expecting ErrOutsideHeight setting block at %d, got %v", y, err)
+		}
+	}
+
+	for _, b := range [...]struct {
+		x, y, z int32
+		Block
+	}{
+		{0, -60, 0, Block{ID: 1}},
+		{0, 319, 0, Block{ID: 1}},
+		{5, -40, 5, Block{ID: 89}},
+	} {
+		if err = l.SetBlock(b.x, b.y, b.z, b.Block); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if h, _ := l.GetHeight(0, 0); h != 320 {
+		t.Errorf("expecting height of 320, got %d", h)
+	} else if sl, _ := l.getSkyLight(0, 318, 0); sl != 14 {
+		t.Errorf("expecting sky light of 14 below top block, got %d", sl)
+	} else if h, _ = l.GetHeight(5, 5); h != -39 {
+		t.Errorf("expecting height of -39, got %d", h)
+	}
+
+	for _, light := range [...][4]int32{
+		{5, -40, 5, 15},
+		{5, -39, 5, 14},
+		{5, -41, 5, 14},
+		{6, -40, 5, 14},
+		{5, -40, 8, 12},
+	} {
+		if bl, _ := l.getBlockLight(light[0], light[1], light[2]); int32(bl) != light[3] {
+			t.Errorf("block light level at [%d, %d, %d] does not match expected, got %d, expecting %d", light[0], light[1], light[2], bl, light[3])
+		}
+	}
+
+	if err = l.Save(); err != nil {
+		t.Fatal(err.Error())
+	} else if l, err = NewLevel(m); err != nil {
+		t.Fatal(err.Error())
+	} else if minY, height := l.HeightLimits(); minY != -64 || height != 384 {
+		t.Errorf("expecting default height limits of -64, 384, got %d, %d", minY, height)
+	} else if b, _ := l.GetBlock(0, -60, 0); b.ID != 1 {
+		t.Errorf("expecting stone at y -60, got %s", b)
+	} else if err = l.SetHeightLimits(0, 256); err != nil {
+		t.Fatal(err.Error())
+	} else if b, _ = l.GetBlock(0, -60, 0); b.ID != 0 {
+		t.Errorf("expecting air outside of height limits, got %s", b)
+	} else if err = l.Save(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := m.GetChunk(0, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sections := data.Data().(nbt.Compound).Get("sections").Data().(nbt.List)
+	ys := make(map[nbt.Byte]bool)
+
+	for i := 0; i < sections.Len(); i++ {
+		ys[sections.Get(i).(nbt.Compound).Get("Y").Data().(nbt.Byte)] = true
+	}
+
+	if !ys[-4] || !ys[19] {
+		t.Errorf("expecting sections outside of height limits to be preserved")
+	}
+
+	if err = l.SetHeightLimits(-64, 384); err != nil {
+		t.Fatal(err.Error())
+	} else if b, _ := l.GetBlock(0, -60, 0); b.ID != 1 {
+		t.Errorf("expecting stone at y -60, got %s", b)
+	} else if b, _ = l.GetBlock(0, 319, 0); b.ID != 1 {
+		t.Errorf("expecting stone at y 319, got %s", b)
+	}
+}
+
 func BenchmarkSkyLight(b *testing.B) {
 	l, _ := NewLevel(NewMemPath())
 	block := Block{ID: 1}
@@ -0,0 +1,374 @@
+// Package litematic implements reading and writing of the schematic files
+// used by the Litematica mod.
+package litematic // import "vimagination.zapto.org/minecraft/litematic"
+
+import (
+	"compress/gzip"
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+const (
+	// Version is the litematic format version written by Encode.
+	Version = 6
+	// SubVersion is the litematic format sub-version written by Encode.
+	SubVersion = 1
+)
+
+// Metadata contains the descriptive information stored in a litematic file.
+type Metadata struct {
+	Name, Author, Description string
+
+	// TimeCreated and TimeModified are in milliseconds since the Unix epoch.
+	TimeCreated, TimeModified int64
+
+	// PreviewImageData is a square image stored as ARGB pixels.
+	PreviewImageData []int32
+}
+
+// PreviewImage returns the preview image, or nil if there is no valid preview
+// image.
+func (m *Metadata) PreviewImage() image.Image {
+	size := int(math.Sqrt(float64(len(m.PreviewImageData))))
+	if size == 0 || size*size != len(m.PreviewImageData) {
+		return nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+
+	for n, p := range m.PreviewImageData {
+		img.SetNRGBA(n%size, n/size, color.NRGBA{R: uint8(p >> 16), G: uint8(p >> 8), B: uint8(p), A: uint8(p >> 24)})
+	}
+
+	return img
+}
+
+// SetPreviewImage sets the preview image to the largest square at the top
+// left of the given image.
+func (m *Metadata) SetPreviewImage(img image.Image) {
+	bounds := img.Bounds()
+
+	size := bounds.Dx()
+	if bounds.Dy() < size {
+		size = bounds.Dy()
+	}
+
+	m.PreviewImageData = make([]int32, size*size)
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			m.PreviewImageData[y*size+x] = int32(uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B))
+		}
+	}
+}
+
+// Litematic is a Litematica schematic, made of any number of named regions.
+type Litematic struct {
+	Version, SubVersion, DataVersion int32
+
+	Metadata Metadata
+	Regions  []*Region
+}
+
+// Region returns the region with the given name, or nil if there is no such
+// region.
+func (l *Litematic) Region(name string) *Region {
+	for _, r := range l.Regions {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Area returns the area enclosing all of the regions, relative to the origin
+// of the schematic.
+func (l *Litematic) Area() minecraft.Area {
+	if len(l.Regions) == 0 {
+		return minecraft.Area{}
+	}
+
+	minX, minY, minZ := int32(math.MaxInt32), int32(math.MaxInt32), int32(math.MaxInt32)
+	maxX, maxY, maxZ := int32(math.MinInt32), int32(math.MinInt32), int32(math.MinInt32)
+
+	for _, r := range l.Regions {
+		a := r.Area()
+
+		if a.X < minX {
+			minX = a.X
+		}
+
+		if a.Y < minY {
+			minY = a.Y
+		}
+
+		if a.Z < minZ {
+			minZ = a.Z
+		}
+
+		if a.X+a.Width > maxX {
+			maxX = a.X + a.Width
+		}
+
+		if a.Y+a.Height > maxY {
+			maxY = a.Y + a.Height
+		}
+
+		if a.Z+a.Length > maxZ {
+			maxZ = a.Z + a.Length
+		}
+	}
+
+	return minecraft.Area{X: minX, Y: minY, Z: minZ, Width: maxX - minX, Height: maxY - minY, Length: maxZ - minZ}
+}
+
+// Paste writes all of the regions to dst, with the origin of the schematic at
+// the given coordinates.
+func (l *Litematic) Paste(dst minecraft.BlockWriter, x, y, z int32, m minecraft.BlockMapper) error {
+	for _, r := range l.Regions {
+		a := r.Area()
+
+		if err := r.Paste(dst, x+a.X, y+a.Y, z+a.Z, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getTag(c nbt.Compound, name string, tagType nbt.TagID) (nbt.Data, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, minecraft.MissingTagError{TagName: name}
+	} else if tag.TagID() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name, Expecting: tagType, Got: tag.TagID()}
+	}
+
+	return tag.Data(), nil
+}
+
+func getCompoundList(c nbt.Compound, name string) ([]nbt.Compound, error) {
+	if c.Get(name).TagID() == 0 {
+		return nil, nil
+	}
+
+	d, err := getTag(c, name, nbt.TagList)
+	if err != nil {
+		return nil, err
+	}
+
+	list := d.(nbt.List)
+	if list.Len() == 0 {
+		return nil, nil
+	} else if list.TagType() != nbt.TagCompound {
+		return nil, minecraft.WrongTypeError{TagName: name + "->Child", Expecting: nbt.TagCompound, Got: list.TagType()}
+	}
+
+	compounds := make([]nbt.Compound, list.Len())
+
+	for n := range compounds {
+		compounds[n] = list.Get(n).(nbt.Compound)
+	}
+
+	return compounds, nil
+}
+
+func compoundList(compounds []nbt.Compound) nbt.List {
+	list := make(nbt.ListCompound, len(compounds))
+
+	copy(list, compounds)
+
+	return &list
+}
+
+func getVec(c nbt.Compound, name string) ([3]int32, error) {
+	var v [3]int32
+
+	d, err := getTag(c, name, nbt.TagCompound)
+	if err != nil {
+		return v, err
+	}
+
+	for n, coord := range [...]string{"x", "y", "z"} {
+		i, err := getTag(d.(nbt.Compound), coord, nbt.TagInt)
+		if err != nil {
+			return v, err
+		}
+
+		v[n] = int32(i.(nbt.Int))
+	}
+
+	return v, nil
+}
+
+func vec(v [3]int32) nbt.Compound {
+	return nbt.Compound{
+		nbt.NewTag("x", nbt.Int(v[0])),
+		nbt.NewTag("y", nbt.Int(v[1])),
+		nbt.NewTag("z", nbt.Int(v[2])),
+	}
+}
+
+// Decode reads a gzipped litematic file.
+func Decode(r io.Reader) (*Litematic, error) {
+	g, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := nbt.Decode(g)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := data.Data().(nbt.Compound)
+	if !ok {
+		return nil, minecraft.WrongTypeError{TagName: "[Litematic]", Expecting: nbt.TagCompound, Got: data.TagID()}
+	}
+
+	l := new(Litematic)
+
+	v, err := getTag(c, "Version", nbt.TagInt)
+	if err != nil {
+		return nil, err
+	}
+
+	l.Version = int32(v.(nbt.Int))
+
+	if sv, ok := c.Get("SubVersion").Data().(nbt.Int); ok {
+		l.SubVersion = int32(sv)
+	}
+
+	if dv, ok := c.Get("MinecraftDataVersion").Data().(nbt.Int); ok {
+		l.DataVersion = int32(dv)
+	}
+
+	if md, ok := c.Get("Metadata").Data().(nbt.Compound); ok {
+		l.Metadata = decodeMetadata(md)
+	}
+
+	regions, err := getTag(c, "Regions", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range regions.(nbt.Compound) {
+		rc, ok := t.Data().(nbt.Compound)
+		if !ok {
+			return nil, minecraft.WrongTypeError{TagName: "Regions->" + t.Name(), Expecting: nbt.TagCompound, Got: t.TagID()}
+		}
+
+		region, err := decodeRegion(t.Name(), rc)
+		if err != nil {
+			return nil, err
+		}
+
+		l.Regions = append(l.Regions, region)
+	}
+
+	return l, nil
+}
+
+func decodeMetadata(c nbt.Compound) Metadata {
+	var m Metadata
+
+	m.Name, _ = stringTag(c, "Name")
+	m.Author, _ = stringTag(c, "Author")
+	m.Description, _ = stringTag(c, "Description")
+
+	if t, ok := c.Get("TimeCreated").Data().(nbt.Long); ok {
+		m.TimeCreated = int64(t)
+	}
+
+	if t, ok := c.Get("TimeModified").Data().(nbt.Long); ok {
+		m.TimeModified = int64(t)
+	}
+
+	if p, ok := c.Get("PreviewImageData").Data().(nbt.IntArray); ok {
+		m.PreviewImageData = p
+	}
+
+	return m
+}
+
+func stringTag(c nbt.Compound, name string) (string, bool) {
+	s, ok := c.Get(name).Data().(nbt.String)
+
+	return string(s), ok
+}
+
+// Encode writes the litematic, gzipped, to the given writer.
+func (l *Litematic) Encode(w io.Writer) error {
+	version, subVersion := l.Version, l.SubVersion
+	if version == 0 {
+		version, subVersion = Version, SubVersion
+	}
+
+	dataVersion := l.DataVersion
+	if dataVersion == 0 {
+		dataVersion = minecraft.DefaultDataVersion
+	}
+
+	var totalBlocks, totalVolume int32
+
+	regions := make(nbt.Compound, 0, len(l.Regions))
+
+	for _, r := range l.Regions {
+		rc, blocks, err := r.encode()
+		if err != nil {
+			return err
+		}
+
+		totalBlocks += blocks
+		totalVolume += int32(r.volume())
+
+		regions = append(regions, nbt.NewTag(r.Name, rc))
+	}
+
+	area := l.Area()
+
+	metadata := nbt.Compound{
+		nbt.NewTag("Name", nbt.String(l.Metadata.Name)),
+		nbt.NewTag("Author", nbt.String(l.Metadata.Author)),
+		nbt.NewTag("Description", nbt.String(l.Metadata.Description)),
+		nbt.NewTag("RegionCount", nbt.Int(len(l.Regions))),
+		nbt.NewTag("TotalBlocks", nbt.Int(totalBlocks)),
+		nbt.NewTag("TotalVolume", nbt.Int(totalVolume)),
+		nbt.NewTag("TimeCreated", nbt.Long(l.Metadata.TimeCreated)),
+		nbt.NewTag("TimeModified", nbt.Long(l.Metadata.TimeModified)),
+		nbt.NewTag("EnclosingSize", vec([3]int32{area.Width, area.Height, area.Length})),
+	}
+
+	if l.Metadata.PreviewImageData != nil {
+		metadata = append(metadata, nbt.NewTag("PreviewImageData", nbt.IntArray(l.Metadata.PreviewImageData)))
+	}
+
+	c := nbt.Compound{
+		nbt.NewTag("MinecraftDataVersion", nbt.Int(dataVersion)),
+		nbt.NewTag("Version", nbt.Int(version)),
+	}
+
+	if subVersion != 0 {
+		c = append(c, nbt.NewTag("SubVersion", nbt.Int(subVersion)))
+	}
+
+	c = append(c,
+		nbt.NewTag("Metadata", metadata),
+		nbt.NewTag("Regions", regions),
+	)
+
+	g := gzip.NewWriter(w)
+
+	if err := nbt.Encode(g, nbt.NewTag("", c)); err != nil {
+		return err
+	}
+
+	return g.Close()
+}
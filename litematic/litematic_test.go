@@ -0,0 +1,232 @@
+package litematic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"image"
+	"image/color"
+	"testing"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestLitematic(t *testing.T) {
+	src, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chest := minecraft.Block{ID: 54, Data: 3}
+	chest.SetMetadata(nbt.Compound{
+		nbt.NewTag("id", nbt.String("minecraft:chest")),
+		nbt.NewTag("Items", nbt.NewEmptyList(nbt.TagCompound)),
+	})
+
+	blocks := []struct {
+		x, y, z int32
+		block   minecraft.Block
+	}{
+		{0, 10, 0, minecraft.Block{ID: 1}},
+		{2, 11, 1, minecraft.Block{ID: 35, Data: 14}},
+		{1, 10, 1, chest},
+		{20, 10, 20, minecraft.Block{ID: 5, Data: 2}},
+		{21, 10, 20, minecraft.Block{ID: 3}},
+	}
+
+	for _, b := range blocks {
+		if err := src.SetBlock(b.x, b.y, b.z, b.block); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	main, err := ExportRegion("main", src, minecraft.Area{X: 0, Y: 10, Z: 0, Width: 3, Height: 2, Length: 2}, minecraft.DefaultBlockStates)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	extra, err := ExportRegion("extra", src, minecraft.Area{X: 20, Y: 10, Z: 20, Width: 2, Height: 1, Length: 1}, minecraft.DefaultBlockStates)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	extra.Position = [3]int32{20, 0, 20}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.SetNRGBA(1, 1, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+
+	l := &Litematic{
+		Metadata: Metadata{Name: "Test", Author: "Builder", TimeCreated: 1600000000000},
+		Regions:  []*Region{main, extra},
+	}
+
+	l.Metadata.SetPreviewImage(img)
+
+	var buf bytes.Buffer
+
+	if err = l.Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	raw, err := nbt.Decode(g)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c := raw.Data().(nbt.Compound)
+	md := c.Get("Metadata").Data().(nbt.Compound)
+
+	if v := c.Get("Version").Data().(nbt.Int); v != Version {
+		t.Errorf("expecting version %d, got %d", Version, v)
+	} else if n := md.Get("TotalBlocks").Data().(nbt.Int); n != 5 {
+		t.Errorf("expecting 5 non-air blocks, got %d", n)
+	} else if n := md.Get("TotalVolume").Data().(nbt.Int); n != 14 {
+		t.Errorf("expecting volume of 14, got %d", n)
+	} else if size := md.Get("EnclosingSize").Data().(nbt.Compound); size.Get("x").Data().(nbt.Int) != 22 || size.Get("z").Data().(nbt.Int) != 21 {
+		t.Errorf("incorrect enclosing size: %s", size)
+	}
+
+	// main has 4 states, so is packed with 2 bits per block.
+	if states := c.Get("Regions").Data().(nbt.Compound).Get("main").Data().(nbt.Compound).Get("BlockStates").Data().(nbt.LongArray); len(states) != 1 {
+		t.Errorf("expecting 1 long of block states, got %d", len(states))
+	}
+
+	if l, err = Decode(&buf); err != nil {
+		t.Fatal(err.Error())
+	} else if l.Metadata.Name != "Test" || l.Metadata.Author != "Builder" || l.Metadata.TimeCreated != 1600000000000 {
+		t.Errorf("metadata not decoded correctly: %v", l.Metadata)
+	} else if len(l.Regions) != 2 || l.Region("extra") == nil {
+		t.Fatalf("expecting 2 regions, got %d", len(l.Regions))
+	}
+
+	if preview := l.Metadata.PreviewImage(); preview == nil || preview.Bounds().Dx() != 2 {
+		t.Errorf("expecting 2x2 preview image, got %v", preview)
+	} else if r, g, b, _ := preview.At(1, 1).RGBA(); r>>8 != 1 || g>>8 != 2 || b>>8 != 3 {
+		t.Errorf("incorrect preview pixel: %d, %d, %d", r>>8, g>>8, b>>8)
+	}
+
+	if state, err := l.Region("main").State(2, 1, 1); err != nil {
+		t.Fatal(err.Error())
+	} else if state.String() != "minecraft:red_wool" {
+		t.Errorf("expecting red wool, got %s", state)
+	}
+
+	dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = l.Paste(dst, 100, 50, 100, minecraft.DefaultBlockStates); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, b := range blocks {
+		got, err := dst.GetBlock(100+b.x, 40+b.y, 100+b.z)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if !got.EqualBlock(b.block) {
+			t.Errorf("expecting block %s at %d,%d,%d, got %s", b.block, b.x, b.y, b.z, got)
+		}
+	}
+}
+
+func TestLitematicArea(t *testing.T) {
+	region := func(position, size [3]int32) *Region {
+		return &Region{Position: position, Size: size}
+	}
+
+	for n, test := range [...]struct {
+		regions []*Region
+		area    minecraft.Area
+	}{
+		{},
+		{
+			[]*Region{region([3]int32{0, 0, 0}, [3]int32{3, 2, 2})},
+			minecraft.Area{Width: 3, Height: 2, Length: 2},
+		},
+		{
+			[]*Region{region([3]int32{5, 1, 5}, [3]int32{-2, -1, -3})},
+			minecraft.Area{X: 4, Y: 1, Z: 3, Width: 2, Height: 1, Length: 3},
+		},
+		{
+			[]*Region{
+				region([3]int32{0, 0, 0}, [3]int32{3, 2, 2}),
+				region([3]int32{20, 0, 20}, [3]int32{2, 1, 1}),
+			},
+			minecraft.Area{Width: 22, Height: 2, Length: 21},
+		},
+		{
+			[]*Region{
+				region([3]int32{0, 0, 0}, [3]int32{-3, 2, 2}),
+				region([3]int32{-5, 4, 1}, [3]int32{2, -2, -4}),
+			},
+			minecraft.Area{X: -5, Y: 0, Z: -2, Width: 6, Height: 5, Length: 4},
+		},
+	} {
+		if a := (&Litematic{Regions: test.regions}).Area(); a != test.area {
+			t.Errorf("test %d: expecting area %v, got %v", n+1, test.area, a)
+		}
+	}
+}
+
+func TestRegionNegativeSize(t *testing.T) {
+	r := NewRegion("neg", 2, 1, 3)
+	r.Position = [3]int32{5, 0, 5}
+	r.Size = [3]int32{-2, 1, -3}
+
+	if a := r.Area(); a != (minecraft.Area{X: 4, Y: 0, Z: 3, Width: 2, Height: 1, Length: 3}) {
+		t.Errorf("incorrect area: %v", a)
+	}
+
+	if err := r.SetState(1, 0, 2, minecraft.BlockState{Name: "minecraft:stone"}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = r.SetState(2, 0, 0, minecraft.BlockState{Name: "minecraft:stone"}); err != minecraft.ErrOOB {
+		t.Errorf("expecting ErrOOB, got %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := (&Litematic{Regions: []*Region{r}}).Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if state, err := l.Regions[0].State(1, 0, 2); err != nil {
+		t.Fatal(err.Error())
+	} else if state.Name != "minecraft:stone" {
+		t.Errorf("expecting stone, got %s", state)
+	}
+
+	dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = l.Paste(dst, 100, 50, 100, minecraft.DefaultBlockStates); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, test := range [...]struct {
+		x, y, z int32
+		block   minecraft.Block
+	}{
+		{105, 50, 105, minecraft.Block{ID: 1}},
+		{104, 50, 103, minecraft.Block{}},
+		{106, 50, 105, minecraft.Block{}},
+	} {
+		if b, err := dst.GetBlock(test.x, test.y, test.z); err != nil {
+			t.Fatal(err.Error())
+		} else if !b.EqualBlock(test.block) {
+			t.Errorf("expecting block %s at %d,%d,%d, got %s", test.block, test.x, test.y, test.z, b)
+		}
+	}
+}
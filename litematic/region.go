@@ -0,0 +1,348 @@
+package litematic
+
+import (
+	"strconv"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+var air = minecraft.BlockState{Name: "minecraft:air"}
+
+// Region is a single named region of a litematic.
+type Region struct {
+	Name string
+
+	// Position is the position of the region relative to the origin of the
+	// schematic, and Size is its size. Either may be negative, in which case
+	// the region extends in the negative direction from Position.
+	Position, Size [3]int32
+
+	// Palette contains the block states used by the region. The first entry
+	// is always air.
+	Palette []minecraft.BlockState
+
+	states []uint32
+
+	TileEntities, Entities, PendingBlockTicks, PendingFluidTicks []nbt.Compound
+}
+
+// NewRegion creates a new region, filled with air, with the given name and
+// size, at the origin of the schematic.
+func NewRegion(name string, width, height, length int32) *Region {
+	r := &Region{
+		Name:    name,
+		Size:    [3]int32{width, height, length},
+		Palette: []minecraft.BlockState{air},
+	}
+
+	r.states = make([]uint32, r.volume())
+
+	return r
+}
+
+// ExportRegion creates a new region from the blocks in the given area, using
+// the given mapper to convert the blocks to block states. Unmapped blocks are
+// exported with their own block states.
+func ExportRegion(name string, src minecraft.BlockReader, area minecraft.Area, m minecraft.BlockMapper) (*Region, error) {
+	r := NewRegion(name, area.Width, area.Height, area.Length)
+	indices := map[string]uint32{air.String(): 0}
+
+	for y := int32(0); y < area.Height; y++ {
+		for z := int32(0); z < area.Length; z++ {
+			for x := int32(0); x < area.Width; x++ {
+				b, err := src.GetBlock(area.X+x, area.Y+y, area.Z+z)
+				if err != nil {
+					return nil, err
+				}
+
+				state, ok := minecraft.StateOf(m, b)
+				if !ok {
+					return nil, minecraft.UnknownBlock{ID: b.ID, Data: b.Data}
+				}
+
+				key := state.String()
+
+				idx, ok := indices[key]
+				if !ok {
+					idx = uint32(len(r.Palette))
+					indices[key] = idx
+					r.Palette = append(r.Palette, state)
+				}
+
+				i, _ := r.index(x, y, z)
+				r.states[i] = idx
+
+				if b.HasMetadata() {
+					r.TileEntities = append(r.TileEntities, append(nbt.Compound{
+						nbt.NewTag("x", nbt.Int(x)),
+						nbt.NewTag("y", nbt.Int(y)),
+						nbt.NewTag("z", nbt.Int(z)),
+					}, b.GetMetadata()...))
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func abs(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+func (r *Region) volume() int {
+	return int(abs(r.Size[0])) * int(abs(r.Size[1])) * int(abs(r.Size[2]))
+}
+
+// Area returns the area covered by the region, relative to the origin of the
+// schematic.
+func (r *Region) Area() minecraft.Area {
+	var min [3]int32
+
+	for n, s := range r.Size {
+		min[n] = r.Position[n]
+
+		if s < 0 {
+			min[n] += s + 1
+		}
+	}
+
+	return minecraft.Area{
+		X:      min[0],
+		Y:      min[1],
+		Z:      min[2],
+		Width:  abs(r.Size[0]),
+		Height: abs(r.Size[1]),
+		Length: abs(r.Size[2]),
+	}
+}
+
+func (r *Region) index(x, y, z int32) (int, bool) {
+	w, h, l := abs(r.Size[0]), abs(r.Size[1]), abs(r.Size[2])
+
+	if x < 0 || y < 0 || z < 0 || x >= w || y >= h || z >= l {
+		return 0, false
+	}
+
+	return (int(y)*int(l)+int(z))*int(w) + int(x), true
+}
+
+// State returns the block state at the given coordinates, relative to the
+// lowest corner of the region.
+func (r *Region) State(x, y, z int32) (minecraft.BlockState, error) {
+	i, ok := r.index(x, y, z)
+	if !ok {
+		return minecraft.BlockState{}, minecraft.ErrOOB
+	}
+
+	return r.Palette[r.states[i]], nil
+}
+
+// SetState sets the block state at the given coordinates, relative to the
+// lowest corner of the region, adding it to the palette if needed.
+func (r *Region) SetState(x, y, z int32, state minecraft.BlockState) error {
+	i, ok := r.index(x, y, z)
+	if !ok {
+		return minecraft.ErrOOB
+	}
+
+	key := state.String()
+
+	for n, s := range r.Palette {
+		if s.String() == key {
+			r.states[i] = uint32(n)
+
+			return nil
+		}
+	}
+
+	r.states[i] = uint32(len(r.Palette))
+	r.Palette = append(r.Palette, state)
+
+	return nil
+}
+
+// Paste writes the blocks of the region to dst, with the lowest corner of the
+// region at the given coordinates, using the given mapper to convert the
+// block states to blocks; block states it has no mapping for are placed as
+// unmapped blocks. Tile entities are attached to their blocks;
+// entities and pending ticks are not placed.
+func (r *Region) Paste(dst minecraft.BlockWriter, x, y, z int32, m minecraft.BlockMapper) error {
+	blocks := make([]minecraft.Block, len(r.Palette))
+
+	for n, state := range r.Palette {
+		blocks[n] = minecraft.BlockOf(m, state)
+	}
+
+	tileEntities := make(map[int]nbt.Compound, len(r.TileEntities))
+
+	for _, te := range r.TileEntities {
+		var pos [3]int32
+
+		for n, coord := range [...]string{"x", "y", "z"} {
+			c, err := getTag(te, coord, nbt.TagInt)
+			if err != nil {
+				return err
+			}
+
+			pos[n] = int32(c.(nbt.Int))
+		}
+
+		if i, ok := r.index(pos[0], pos[1], pos[2]); ok {
+			tileEntities[i] = te
+		}
+	}
+
+	a := r.Area()
+
+	for by := int32(0); by < a.Height; by++ {
+		for bz := int32(0); bz < a.Length; bz++ {
+			for bx := int32(0); bx < a.Width; bx++ {
+				i, _ := r.index(bx, by, bz)
+				b := blocks[r.states[i]]
+
+				if te, ok := tileEntities[i]; ok {
+					b.SetMetadata(te)
+				}
+
+				if err := dst.SetBlock(x+bx, y+by, z+bz, b); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeRegion(name string, c nbt.Compound) (*Region, error) {
+	r := &Region{Name: name}
+
+	var err error
+
+	if r.Position, err = getVec(c, "Position"); err != nil {
+		return nil, err
+	} else if r.Size, err = getVec(c, "Size"); err != nil {
+		return nil, err
+	}
+
+	palette, err := getCompoundList(c, "BlockStatePalette")
+	if err != nil {
+		return nil, err
+	} else if len(palette) == 0 {
+		return nil, minecraft.MissingTagError{TagName: "BlockStatePalette"}
+	}
+
+	r.Palette = make([]minecraft.BlockState, len(palette))
+
+	for n, p := range palette {
+		name, ok := stringTag(p, "Name")
+		if !ok {
+			return nil, minecraft.MissingTagError{TagName: "BlockStatePalette->Name"}
+		}
+
+		r.Palette[n].Name = name
+
+		if props, ok := p.Get("Properties").Data().(nbt.Compound); ok {
+			r.Palette[n].Properties = make(map[string]string, len(props))
+
+			for _, prop := range props {
+				v, ok := prop.Data().(nbt.String)
+				if !ok {
+					return nil, minecraft.WrongTypeError{TagName: "Properties->" + prop.Name(), Expecting: nbt.TagString, Got: prop.TagID()}
+				}
+
+				r.Palette[n].Properties[prop.Name()] = string(v)
+			}
+		}
+	}
+
+	states, err := getTag(c, "BlockStates", nbt.TagLongArray)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.states, err = minecraft.UnpackBits(states.(nbt.LongArray), minecraft.PackedBits(len(r.Palette), 2), r.volume(), false); err != nil {
+		return nil, err
+	}
+
+	for _, s := range r.states {
+		if int(s) >= len(r.Palette) {
+			return nil, minecraft.UnexpectedValue{TagName: "BlockStates", Expecting: "index less than " + strconv.Itoa(len(r.Palette)), Got: strconv.FormatUint(uint64(s), 10)}
+		}
+	}
+
+	for _, list := range [...]struct {
+		name string
+		data *[]nbt.Compound
+	}{
+		{"TileEntities", &r.TileEntities},
+		{"Entities", &r.Entities},
+		{"PendingBlockTicks", &r.PendingBlockTicks},
+		{"PendingFluidTicks", &r.PendingFluidTicks},
+	} {
+		if *list.data, err = getCompoundList(c, list.name); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// encode returns the region as a compound, along with the number of non-air
+// blocks within it.
+func (r *Region) encode() (nbt.Compound, int32, error) {
+	palette := make(nbt.ListCompound, len(r.Palette))
+
+	for n, state := range r.Palette {
+		p := nbt.Compound{nbt.NewTag("Name", nbt.String(state.Name))}
+
+		if len(state.Properties) > 0 {
+			props := make(nbt.Compound, 0, len(state.Properties))
+
+			for _, k := range state.PropertyNames() {
+				props = append(props, nbt.NewTag(k, nbt.String(state.Properties[k])))
+			}
+
+			p = append(p, nbt.NewTag("Properties", props))
+		}
+
+		palette[n] = p
+	}
+
+	isAir := make([]bool, len(r.Palette))
+
+	for n, state := range r.Palette {
+		isAir[n] = state.Name == air.Name
+	}
+
+	if len(r.states) != r.volume() {
+		return nil, 0, minecraft.ErrOOB
+	}
+
+	var blocks int32
+
+	for _, s := range r.states {
+		if int(s) >= len(r.Palette) {
+			return nil, 0, minecraft.ErrOOB
+		} else if !isAir[s] {
+			blocks++
+		}
+	}
+
+	return nbt.Compound{
+		nbt.NewTag("Position", vec(r.Position)),
+		nbt.NewTag("Size", vec(r.Size)),
+		nbt.NewTag("BlockStatePalette", &palette),
+		nbt.NewTag("BlockStates", nbt.LongArray(minecraft.PackBits(r.states, minecraft.PackedBits(len(r.Palette), 2), false))),
+		nbt.NewTag("TileEntities", compoundList(r.TileEntities)),
+		nbt.NewTag("Entities", compoundList(r.Entities)),
+		nbt.NewTag("PendingBlockTicks", compoundList(r.PendingBlockTicks)),
+		nbt.NewTag("PendingFluidTicks", compoundList(r.PendingFluidTicks)),
+	}, blocks, nil
+}
@@ -0,0 +1,222 @@
+package minecraft
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vimagination.zapto.org/byteio"
+	"vimagination.zapto.org/memio"
+)
+
+// sessionLockDummy is written to session.lock by Minecraft 1.16+, which then
+// holds an OS level lock on the file for as long as the level is open.
+const sessionLockDummy = "☃"
+
+var (
+	lockedMu   sync.Mutex
+	lockedDirs = make(map[string]struct{})
+)
+
+// LegacyLock sets the FilePath to use the pre-1.16 session.lock semantics, in
+// which the current time, in milliseconds, is written to session.lock and the
+// lock is considered lost when the file contains a different value.
+//
+// Legacy locks can be taken from a running program at any time, and so cannot
+// detect a modern server that is using the level.
+func LegacyLock() FilePathOption {
+	return func(p *FilePath) error {
+		p.legacyLock = true
+
+		return nil
+	}
+}
+
+// HasLock returns whether or not another program has taken the lock.
+//
+// For modern locks, this returns whether this FilePath currently holds the
+// lock.
+func (p *FilePath) HasLock() bool {
+	if p.root != nil {
+		return p.root.HasLock()
+	}
+
+	p.sessionMu.RLock()
+	defer p.sessionMu.RUnlock()
+
+	return p.hasLock()
+}
+
+func (p *FilePath) hasLock() bool {
+	if !p.legacyLock {
+		return p.lockFile != nil
+	}
+
+	r, err := os.Open(path.Join(p.dirname, "session.lock"))
+	if err != nil {
+		return false
+	}
+
+	defer r.Close()
+
+	buf := make(memio.Buffer, 9)
+
+	n, err := io.ReadFull(r, buf)
+	if n != 8 || err != io.ErrUnexpectedEOF {
+		return false
+	}
+
+	bew := byteio.BigEndianReader{Reader: &buf}
+	b, _, _ := bew.ReadInt64()
+
+	return b == p.lock
+}
+
+// canRead determines whether the level can currently be read from.
+func (p *FilePath) canRead() bool {
+	return p.readOnly || p.HasLock()
+}
+
+// canWrite determines whether the level can currently be written to,
+// returning an appropriate error if it cannot.
+func (p *FilePath) canWrite() error {
+	if p.readOnly {
+		return ErrReadOnly
+	} else if !p.HasLock() {
+		return ErrNoLock
+	}
+
+	return nil
+}
+
+// Lock will retake the lock file if it has been lost.
+//
+// For modern locks, this will fail with ErrLocked if another program (or
+// another FilePath within this program) holds the lock.
+//
+// For legacy locks, this will always take the lock, which may cause corruption
+// if another program is using the level.
+func (p *FilePath) Lock() error {
+	if p.root != nil {
+		return p.root.Lock()
+	} else if p.readOnly {
+		return ErrReadOnly
+	}
+
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+
+	if p.hasLock() {
+		return nil
+	}
+
+	// Any cached region data may have been changed while the lock was not
+	// held.
+	p.Flush()
+
+	if p.legacyLock {
+		return p.legacyLockSession()
+	}
+
+	dir, err := filepath.Abs(p.dirname)
+	if err != nil {
+		return err
+	}
+
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+
+	if _, ok := lockedDirs[dir]; ok {
+		return ErrLocked
+	}
+
+	f, err := os.OpenFile(path.Join(p.dirname, "session.lock"), os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+
+	if err = lockFile(f); err == nil {
+		if err = f.Truncate(0); err == nil {
+			if _, err = f.WriteAt([]byte(sessionLockDummy), 0); err == nil {
+				err = f.Sync()
+			}
+		}
+
+		if err != nil {
+			unlockFile(f)
+		}
+	}
+
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	p.lockFile = f
+	lockedDirs[dir] = struct{}{}
+
+	return nil
+}
+
+func (p *FilePath) legacyLockSession() error {
+	p.lock = time.Now().UnixNano() / 1000000 // ms
+	session := path.Join(p.dirname, "session.lock")
+
+	f, err := os.Create(session)
+	if err != nil {
+		return err
+	}
+
+	bew := byteio.BigEndianWriter{Writer: f}
+	_, err = bew.WriteUint64(uint64(p.lock))
+
+	f.Close()
+
+	return err
+}
+
+// Unlock releases the lock on the level, after which no more reads or writes
+// can be made until the lock is retaken with Lock.
+//
+// Any region files held open by the FilePath are closed.
+func (p *FilePath) Unlock() error {
+	if p.root != nil {
+		return p.root.Unlock()
+	}
+
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+
+	err := p.Flush()
+
+	if p.legacyLock {
+		p.lock = 0
+
+		return err
+	} else if p.lockFile == nil {
+		return err
+	}
+
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+
+	if dir, err := filepath.Abs(p.dirname); err == nil {
+		delete(lockedDirs, dir)
+	}
+
+	if uerr := unlockFile(p.lockFile); err == nil {
+		err = uerr
+	}
+
+	if cerr := p.lockFile.Close(); err == nil {
+		err = cerr
+	}
+
+	p.lockFile = nil
+
+	return err
+}
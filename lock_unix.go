@@ -0,0 +1,31 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package minecraft
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// Java, and therefore Minecraft, uses POSIX record locks on unix systems,
+// which do not interact with flock(2) locks, so fcntl(2) must be used to
+// detect a running server.
+func lockFile(f *os.File) error {
+	err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &syscall.Flock_t{
+		Type:   syscall.F_WRLCK,
+		Whence: io.SeekStart,
+	})
+	if err == syscall.EAGAIN || err == syscall.EACCES {
+		return ErrLocked
+	}
+
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &syscall.Flock_t{
+		Type:   syscall.F_UNLCK,
+		Whence: io.SeekStart,
+	})
+}
@@ -0,0 +1,77 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package minecraft
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+func TestLockHelperProcess(t *testing.T) {
+	dir := os.Getenv("MINECRAFT_TEST_LOCK_DIR")
+	if dir == "" {
+		return
+	}
+
+	f, err := os.OpenFile(path.Join(dir, "session.lock"), os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		os.Exit(1)
+	} else if err = lockFile(f); err != nil {
+		os.Exit(2)
+	}
+
+	os.Stdout.WriteString("locked\n")
+	io.Copy(io.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+func TestLockOtherProcess(t *testing.T) {
+	tempDir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), "MINECRAFT_TEST_LOCK_DIR="+tempDir)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = cmd.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "locked\n" {
+		stdin.Close()
+		cmd.Wait()
+		t.Fatalf("helper process failed to lock: %q, %v", line, err)
+	}
+
+	if _, err = NewFilePath(tempDir); err != ErrLocked {
+		t.Errorf("expecting ErrLocked, got %v", err)
+	}
+
+	if f, err := NewFilePath(tempDir, LegacyLock()); err != nil {
+		t.Errorf("legacy lock: unexpected error: %s", err)
+	} else if !f.HasLock() {
+		t.Errorf("legacy lock: expecting lock to be held")
+	}
+
+	stdin.Close()
+
+	if err = cmd.Wait(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f.Unlock()
+}
@@ -0,0 +1,470 @@
+package minecraft
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Minecraft uses the block stream format of lz4-java (LZ4BlockOutputStream)
+// for compression type 4, and not the standard LZ4 frame format.
+
+const (
+	lz4Magic         = "LZ4Block"
+	lz4HeaderLength  = len(lz4Magic) + 13
+	lz4MethodRaw     = 0x10
+	lz4MethodLZ4     = 0x20
+	lz4BlockSize     = 1 << 16
+	lz4BlockLevel    = 6 // log2(lz4BlockSize) - 10
+	lz4Seed          = 0x9747b28c
+	lz4ChecksumMask  = 0xfffffff
+	lz4MinMatch      = 4
+	lz4LastLiterals  = 5
+	lz4MFLimit       = 12
+	lz4HashLog       = 12
+	lz4MaxOffset     = 65535
+	lz4MaxCompressed = lz4BlockSize + lz4BlockSize/255 + 16
+)
+
+var (
+	errLZ4Magic    = errors.New("invalid lz4 block magic")
+	errLZ4Header   = errors.New("invalid lz4 block header")
+	errLZ4Corrupt  = errors.New("corrupt lz4 block")
+	errLZ4Checksum = errors.New("lz4 block checksum mismatch")
+)
+
+type lz4Reader struct {
+	r      io.Reader
+	header [lz4HeaderLength]byte
+	comp   []byte
+	buf    []byte
+	pos    int
+	done   bool
+}
+
+func newLZ4Reader(r io.Reader) *lz4Reader {
+	return &lz4Reader{r: r}
+}
+
+func (l *lz4Reader) Read(p []byte) (int, error) {
+	for l.pos == len(l.buf) {
+		if l.done {
+			return 0, io.EOF
+		}
+
+		if err := l.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, l.buf[l.pos:])
+	l.pos += n
+
+	return n, nil
+}
+
+func (l *lz4Reader) readBlock() error {
+	if _, err := io.ReadFull(l.r, l.header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return err
+	} else if string(l.header[:len(lz4Magic)]) != lz4Magic {
+		return errLZ4Magic
+	}
+
+	token := l.header[len(lz4Magic)]
+	method := token & 0xf0
+	compressedLength := int(int32(binary.LittleEndian.Uint32(l.header[len(lz4Magic)+1:])))
+	originalLength := int(int32(binary.LittleEndian.Uint32(l.header[len(lz4Magic)+5:])))
+	checksum := binary.LittleEndian.Uint32(l.header[len(lz4Magic)+9:])
+	maxLength := 1 << (10 + token&0x0f)
+
+	if (method != lz4MethodRaw && method != lz4MethodLZ4) || originalLength < 0 || originalLength > maxLength || compressedLength < 0 || compressedLength > maxLength+maxLength/255+16 || (originalLength == 0) != (compressedLength == 0) || (method == lz4MethodRaw && originalLength != compressedLength) {
+		return errLZ4Header
+	}
+
+	l.pos = 0
+
+	if originalLength == 0 {
+		if checksum != 0 {
+			return errLZ4Header
+		}
+
+		l.buf = l.buf[:0]
+		l.done = true
+
+		return nil
+	}
+
+	if cap(l.buf) < originalLength {
+		l.buf = make([]byte, originalLength)
+	}
+
+	l.buf = l.buf[:originalLength]
+
+	if method == lz4MethodRaw {
+		if _, err := io.ReadFull(l.r, l.buf); err != nil {
+			return err
+		}
+	} else {
+		if cap(l.comp) < compressedLength {
+			l.comp = make([]byte, compressedLength)
+		}
+
+		l.comp = l.comp[:compressedLength]
+
+		if _, err := io.ReadFull(l.r, l.comp); err != nil {
+			return err
+		} else if n, err := lz4DecompressBlock(l.buf, l.comp); err != nil {
+			return err
+		} else if n != originalLength {
+			return errLZ4Corrupt
+		}
+	}
+
+	if hash := xxHash32(l.buf, lz4Seed); hash&lz4ChecksumMask != checksum&lz4ChecksumMask {
+		return errLZ4Checksum
+	}
+
+	return nil
+}
+
+func (l *lz4Reader) Close() error {
+	return nil
+}
+
+type lz4Writer struct {
+	w     io.Writer
+	buf   []byte
+	comp  []byte
+	table [1 << lz4HashLog]int32
+	err   error
+}
+
+func newLZ4Writer(w io.Writer) *lz4Writer {
+	return &lz4Writer{
+		w:    w,
+		buf:  make([]byte, 0, lz4BlockSize),
+		comp: make([]byte, lz4HeaderLength+lz4MaxCompressed),
+	}
+}
+
+func (l *lz4Writer) Write(p []byte) (int, error) {
+	var n int
+
+	for len(p) > 0 && l.err == nil {
+		m := copy(l.buf[len(l.buf):cap(l.buf)], p)
+		l.buf = l.buf[:len(l.buf)+m]
+		p = p[m:]
+		n += m
+
+		if len(l.buf) == cap(l.buf) {
+			l.flushBlock()
+		}
+	}
+
+	return n, l.err
+}
+
+func (l *lz4Writer) flushBlock() {
+	if len(l.buf) == 0 || l.err != nil {
+		return
+	}
+
+	header := l.comp[:lz4HeaderLength]
+	method := byte(lz4MethodLZ4)
+	n := lz4CompressBlock(l.comp[lz4HeaderLength:], l.buf, &l.table)
+	block := l.comp[:lz4HeaderLength+n]
+
+	if n == 0 || n >= len(l.buf) {
+		method = lz4MethodRaw
+		n = len(l.buf)
+		block = header
+	}
+
+	copy(header, lz4Magic)
+
+	header[len(lz4Magic)] = method | lz4BlockLevel
+
+	binary.LittleEndian.PutUint32(header[len(lz4Magic)+1:], uint32(n))
+	binary.LittleEndian.PutUint32(header[len(lz4Magic)+5:], uint32(len(l.buf)))
+	binary.LittleEndian.PutUint32(header[len(lz4Magic)+9:], xxHash32(l.buf, lz4Seed)&lz4ChecksumMask)
+
+	if _, l.err = l.w.Write(block); l.err == nil && method == lz4MethodRaw {
+		_, l.err = l.w.Write(l.buf)
+	}
+
+	l.buf = l.buf[:0]
+}
+
+func (l *lz4Writer) Close() error {
+	l.flushBlock()
+
+	if l.err != nil {
+		return l.err
+	}
+
+	var end [lz4HeaderLength]byte
+
+	copy(end[:], lz4Magic)
+
+	end[len(lz4Magic)] = lz4MethodRaw | lz4BlockLevel
+	_, l.err = l.w.Write(end[:])
+
+	return l.err
+}
+
+func lz4DecompressBlock(dst, src []byte) (int, error) {
+	var s, d int
+
+	for s < len(src) {
+		token := src[s]
+		s++
+
+		literals := int(token >> 4)
+
+		if literals == 15 {
+			for {
+				if s >= len(src) {
+					return 0, errLZ4Corrupt
+				}
+
+				b := src[s]
+				s++
+				literals += int(b)
+
+				if b != 255 {
+					break
+				}
+			}
+		}
+
+		if literals > len(src)-s || literals > len(dst)-d {
+			return 0, errLZ4Corrupt
+		}
+
+		d += copy(dst[d:], src[s:s+literals])
+		s += literals
+
+		if s == len(src) {
+			break
+		} else if s+2 > len(src) {
+			return 0, errLZ4Corrupt
+		}
+
+		offset := int(src[s]) | int(src[s+1])<<8
+		s += 2
+
+		if offset == 0 || offset > d {
+			return 0, errLZ4Corrupt
+		}
+
+		length := int(token & 15)
+
+		if length == 15 {
+			for {
+				if s >= len(src) {
+					return 0, errLZ4Corrupt
+				}
+
+				b := src[s]
+				s++
+				length += int(b)
+
+				if b != 255 {
+					break
+				}
+			}
+		}
+
+		length += lz4MinMatch
+
+		if length > len(dst)-d {
+			return 0, errLZ4Corrupt
+		}
+
+		for i := 0; i < length; i++ {
+			dst[d] = dst[d-offset]
+			d++
+		}
+	}
+
+	return d, nil
+}
+
+func lz4Hash(v uint32) uint32 {
+	return (v * 2654435761) >> (32 - lz4HashLog)
+}
+
+func lz4CompressBlock(dst, src []byte, table *[1 << lz4HashLog]int32) int {
+	for i := range table {
+		table[i] = -1
+	}
+
+	var (
+		d, anchor int
+		limit     = len(src) - lz4MFLimit
+	)
+
+	writeLength := func(l int) bool {
+		for ; l >= 255; l -= 255 {
+			if d >= len(dst) {
+				return false
+			}
+
+			dst[d] = 255
+			d++
+		}
+
+		if d >= len(dst) {
+			return false
+		}
+
+		dst[d] = byte(l)
+		d++
+
+		return true
+	}
+
+	writeSequence := func(literals []byte, offset, length int) bool {
+		if d >= len(dst) {
+			return false
+		}
+
+		token := d
+		d++
+
+		if len(literals) >= 15 {
+			dst[token] = 15 << 4
+
+			if !writeLength(len(literals) - 15) {
+				return false
+			}
+		} else {
+			dst[token] = byte(len(literals)) << 4
+		}
+
+		if len(literals) > len(dst)-d {
+			return false
+		}
+
+		d += copy(dst[d:], literals)
+
+		if length == 0 {
+			return true
+		} else if d+2 > len(dst) {
+			return false
+		}
+
+		dst[d] = byte(offset)
+		dst[d+1] = byte(offset >> 8)
+		d += 2
+
+		if length -= lz4MinMatch; length >= 15 {
+			dst[token] |= 15
+
+			return writeLength(length - 15)
+		}
+
+		dst[token] |= byte(length)
+
+		return true
+	}
+
+	for s := 0; s < limit; {
+		v := binary.LittleEndian.Uint32(src[s:])
+		h := lz4Hash(v)
+		ref := int(table[h])
+		table[h] = int32(s)
+
+		if ref < 0 || s-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != v {
+			s++
+
+			continue
+		}
+
+		length := lz4MinMatch
+
+		for s+length < len(src)-lz4LastLiterals && src[ref+length] == src[s+length] {
+			length++
+		}
+
+		for s > anchor && ref > 0 && src[s-1] == src[ref-1] {
+			s--
+			ref--
+			length++
+		}
+
+		if !writeSequence(src[anchor:s], s-ref, length) {
+			return 0
+		}
+
+		s += length
+		anchor = s
+	}
+
+	if !writeSequence(src[anchor:], 0, 0) {
+		return 0
+	}
+
+	return d
+}
+
+const (
+	xxPrime1 uint32 = 2654435761
+	xxPrime2 uint32 = 2246822519
+	xxPrime3 uint32 = 3266489917
+	xxPrime4 uint32 = 668265263
+	xxPrime5 uint32 = 374761393
+)
+
+func xxRound(acc, input uint32) uint32 {
+	return bits.RotateLeft32(acc+input*xxPrime2, 13) * xxPrime1
+}
+
+func xxHash32(data []byte, seed uint32) uint32 {
+	var h uint32
+
+	n := len(data)
+
+	if n >= 16 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+
+		for ; len(data) >= 16; data = data[16:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint32(data))
+			v2 = xxRound(v2, binary.LittleEndian.Uint32(data[4:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint32(data[8:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint32(data[12:]))
+		}
+
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint32(n)
+
+	for ; len(data) >= 4; data = data[4:] {
+		h += binary.LittleEndian.Uint32(data) * xxPrime3
+		h = bits.RotateLeft32(h, 17) * xxPrime4
+	}
+
+	for _, b := range data {
+		h += uint32(b) * xxPrime5
+		h = bits.RotateLeft32(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 15
+	h *= xxPrime2
+	h ^= h >> 13
+	h *= xxPrime3
+	h ^= h >> 16
+
+	return h
+}
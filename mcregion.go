@@ -0,0 +1,294 @@
+package minecraft
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+var mcRegionFilename = regexp.MustCompile(`^r.(-?[0-9]+).(-?[0-9]+).mcr$`)
+
+// McRegionPath is a read-only Path implementation for levels stored in the
+// McRegion format, as used before the Anvil format was introduced in version
+// 1.2.
+//
+// Chunks are converted to the Anvil format as they are read, allowing a
+// McRegion level to be used with a Level. The original chunk data can be
+// retrieved with GetMcRegionChunk.
+//
+// All methods that would modify the level return ErrReadOnly.
+type McRegionPath struct {
+	fsys      fs.FS
+	dimension string
+}
+
+// OpenMcRegionPath opens the McRegion level in the given directory.
+func OpenMcRegionPath(dirname string) (*McRegionPath, error) {
+	if fi, err := os.Stat(dirname); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: dirname, Err: ErrNotDirectory}
+	}
+
+	return NewMcRegionPath(os.DirFS(dirname)), nil
+}
+
+// NewMcRegionPath creates a McRegionPath that reads a level from the root of
+// the given filesystem.
+func NewMcRegionPath(fsys fs.FS) *McRegionPath {
+	return &McRegionPath{fsys: fsys}
+}
+
+// WithDimension returns a McRegionPath for the given dimension of the same
+// level.
+func (p *McRegionPath) WithDimension(d Dimension) (*McRegionPath, error) {
+	if !d.valid() {
+		return nil, InvalidDimension{d.String()}
+	}
+
+	return &McRegionPath{fsys: p.fsys, dimension: d.dir()}, nil
+}
+
+func (p *McRegionPath) getRegionPath(x, z int32) string {
+	return path.Join(p.dimension, string(StorageRegion), "r."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mcr")
+}
+
+func (p *McRegionPath) getExternalChunkPath(x, z int32) string {
+	return path.Join(p.dimension, string(StorageRegion), "c."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mcc")
+}
+
+// GetChunk returns the chunk at chunk coords x, z, converted to the Anvil
+// format.
+func (p *McRegionPath) GetChunk(x, z int32) (nbt.Tag, error) {
+	data, err := p.GetMcRegionChunk(x, z)
+	if err != nil || data.TagID() == 0 {
+		return data, err
+	}
+
+	return ConvertMcRegionChunk(data)
+}
+
+// GetMcRegionChunk returns the unconverted chunk at chunk coords x, z.
+func (p *McRegionPath) GetMcRegionChunk(x, z int32) (nbt.Tag, error) {
+	return readFSChunk(p.fsys, p.getRegionPath(x>>5, z>>5), p.getExternalChunkPath(x, z), x, z)
+}
+
+// SetChunk always returns ErrReadOnly.
+func (p *McRegionPath) SetChunk(...nbt.Tag) error {
+	return ErrReadOnly
+}
+
+// RemoveChunk always returns ErrReadOnly.
+func (p *McRegionPath) RemoveChunk(int32, int32) error {
+	return ErrReadOnly
+}
+
+// ReadLevelDat returns the level data.
+func (p *McRegionPath) ReadLevelDat() (nbt.Tag, error) {
+	return loadLevelDat(p.fsys)
+}
+
+// WriteLevelDat always returns ErrReadOnly.
+func (p *McRegionPath) WriteLevelDat(nbt.Tag) error {
+	return ErrReadOnly
+}
+
+// GetRegions returns a list of region x,z coords of all generated regions.
+func (p *McRegionPath) GetRegions() [][2]int32 {
+	files, _ := fs.ReadDir(p.fsys, path.Join(p.dimension, string(StorageRegion)))
+
+	return matchRegionFiles(files, mcRegionFilename)
+}
+
+// GetChunks returns a list of all chunks within a region with coords x,z.
+func (p *McRegionPath) GetChunks(x, z int32) ([][2]int32, error) {
+	return listFSChunks(p.fsys, p.getRegionPath(x, z), x, z)
+}
+
+// Chunks returns an iterator over all chunks in the current dimension that
+// match all of the given filters.
+func (p *McRegionPath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	return newChunkIterator(ctx, p.GetRegions(), func(x, z int32) ([]ChunkInfo, error) {
+		return fsRegionChunks(p.fsys, p.getRegionPath(x, z), x, z, p.getExternalChunkPath)
+	}, filters)
+}
+
+// ConvertTo converts every chunk in the McRegion level to the Anvil format,
+// writing them to the given Path, along with the level data, which is marked
+// as being an Anvil level.
+func (p *McRegionPath) ConvertTo(dst Path) error {
+	for _, r := range p.GetRegions() {
+		coords, err := p.GetChunks(r[0], r[1])
+		if err != nil {
+			return err
+		}
+
+		chunks := make([]nbt.Tag, 0, len(coords))
+
+		for _, c := range coords {
+			chunk, err := p.GetChunk(c[0], c[1])
+			if err != nil {
+				return err
+			} else if chunk.TagID() != 0 {
+				chunks = append(chunks, chunk)
+			}
+		}
+
+		if len(chunks) > 0 {
+			if err = dst.SetChunk(chunks...); err != nil {
+				return err
+			}
+		}
+	}
+
+	levelDat, err := p.ReadLevelDat()
+	if err != nil || levelDat.TagID() == 0 {
+		return err
+	}
+
+	if levelDat.TagID() == nbt.TagCompound {
+		if data, ok := levelDat.Data().(nbt.Compound).Get("Data").Data().(nbt.Compound); ok {
+			data.Set(nbt.NewTag("version", nbt.Int(19133)))
+		}
+	}
+
+	return dst.WriteLevelDat(levelDat)
+}
+
+const mcRegionHeight = 128
+
+// ConvertMcRegionChunk converts a chunk from the McRegion format, which stores
+// a single 128 block high column of blocks, in XZY order, to the sectioned
+// Anvil format.
+//
+// Sections that contain no blocks are omitted, and the HeightMap is rebuilt
+// from the converted blocks.
+func ConvertMcRegionChunk(data nbt.Tag) (nbt.Tag, error) {
+	x, z, err := chunkCoords(data)
+	if err != nil {
+		return nbt.Tag{}, err
+	}
+
+	old := data.Data().(nbt.Compound).Get("Level").Data().(nbt.Compound)
+
+	blocks, err := mcRegionArray(old, "Blocks", mcRegionHeight*256)
+	if err != nil {
+		return nbt.Tag{}, err
+	}
+
+	var nibbles [3]nbt.ByteArray
+
+	for n, name := range [...]string{"Data", "BlockLight", "SkyLight"} {
+		if nibbles[n], err = mcRegionArray(old, name, mcRegionHeight*128); err != nil {
+			return nbt.Tag{}, err
+		}
+	}
+
+	sections := nbt.NewEmptyList(nbt.TagCompound)
+
+	for sy := int32(0); sy < mcRegionHeight>>4; sy++ {
+		if !mcRegionSectionUsed(blocks, sy) {
+			continue
+		}
+
+		s := newSection(sy << 4)
+
+		for i := int32(0); i < 4096; i++ {
+			bx, by, bz := i&15, sy<<4|i>>8, i>>4&15
+			j := bx<<11 | bz<<7 | by
+
+			s.blocks[yzx(bx, by, bz)] = blocks[j]
+
+			setNibble(s.data, bx, by, bz, xzyNibble(nibbles[0], j))
+			setNibble(s.blockLight, bx, by, bz, xzyNibble(nibbles[1], j))
+			setNibble(s.skyLight, bx, by, bz, xzyNibble(nibbles[2], j))
+		}
+
+		sections.Append(s.section)
+	}
+
+	heightMap := make(nbt.IntArray, 256)
+
+	for bx := int32(0); bx < 16; bx++ {
+		for bz := int32(0); bz < 16; bz++ {
+			for by := int32(mcRegionHeight - 1); by >= 0; by-- {
+				j := bx<<11 | bz<<7 | by
+				b := Block{ID: uint16(byte(blocks[j])), Data: xzyNibble(nibbles[0], j)}
+
+				if b.Opacity() > 1 {
+					heightMap[bx<<4|bz] = by + 1
+
+					break
+				}
+			}
+		}
+	}
+
+	biomes := make(nbt.ByteArray, 256)
+
+	for i := range biomes {
+		biomes[i] = -1
+	}
+
+	level := nbt.Compound{
+		nbt.NewTag("xPos", nbt.Int(x)),
+		nbt.NewTag("zPos", nbt.Int(z)),
+		nbt.NewTag("Biomes", biomes),
+		nbt.NewTag("HeightMap", heightMap),
+		nbt.NewTag("InhabitedTime", nbt.Long(0)),
+		nbt.NewTag("LastUpdate", nbt.Long(0)),
+		nbt.NewTag("Sections", sections),
+		nbt.NewTag("TerrainPopulated", nbt.Byte(1)),
+	}
+
+	for _, name := range [...]string{"LastUpdate", "TerrainPopulated", "Entities", "TileEntities", "TileTicks"} {
+		if tag := old.Get(name); tag.TagID() != 0 {
+			level.Set(tag.Copy())
+		}
+	}
+
+	return nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", level)}), nil
+}
+
+func mcRegionArray(c nbt.Compound, name string, length int) (nbt.ByteArray, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, MissingTagError{"[Chunk Base]->Level->" + name}
+	} else if tag.TagID() != nbt.TagByteArray {
+		return nil, WrongTypeError{"[Chunk Base]->Level->" + name, nbt.TagByteArray, tag.TagID()}
+	}
+
+	arr := tag.Data().(nbt.ByteArray)
+	if len(arr) != length {
+		return nil, ErrOOB
+	}
+
+	return arr, nil
+}
+
+func mcRegionSectionUsed(blocks nbt.ByteArray, sy int32) bool {
+	for i := int32(0); i < 256; i++ {
+		for _, b := range blocks[i<<7|sy<<4 : i<<7|sy<<4+16] {
+			if b != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func xzyNibble(arr nbt.ByteArray, i int32) byte {
+	data := byte(arr[i>>1])
+
+	if i&1 == 0 {
+		return data & 15
+	}
+
+	return data >> 4
+}
@@ -0,0 +1,185 @@
+package minecraft
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func mcRegionChunk(x, z int32) nbt.Tag {
+	blocks := make(nbt.ByteArray, 32768)
+	data := make(nbt.ByteArray, 16384)
+	skyLight := make(nbt.ByteArray, 16384)
+
+	for bx := int32(0); bx < 16; bx++ {
+		for bz := int32(0); bz < 16; bz++ {
+			column := bx<<11 | bz<<7
+
+			blocks[column] = 7
+
+			for by := int32(1); by < 40+bx; by++ {
+				blocks[column|by] = 1
+			}
+
+			// A glass block, which is transparent, at the top of each
+			// column.
+			blocks[column|100] = 20
+		}
+	}
+
+	blocks[3<<11|4<<7|64] = 35
+	data[(3<<11|4<<7|64)>>1] = 5
+	skyLight[(3<<11|4<<7|65)>>1] = -16 // 15 in the high nibble
+
+	return nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Level", nbt.Compound{
+			nbt.NewTag("xPos", nbt.Int(x)),
+			nbt.NewTag("zPos", nbt.Int(z)),
+			nbt.NewTag("Blocks", blocks),
+			nbt.NewTag("Data", data),
+			nbt.NewTag("BlockLight", make(nbt.ByteArray, 16384)),
+			nbt.NewTag("SkyLight", skyLight),
+			nbt.NewTag("HeightMap", make(nbt.ByteArray, 256)),
+			nbt.NewTag("Entities", nbt.NewEmptyList(nbt.TagCompound)),
+			nbt.NewTag("TileEntities", nbt.NewEmptyList(nbt.TagCompound)),
+			nbt.NewTag("LastUpdate", nbt.Long(1234)),
+			nbt.NewTag("TerrainPopulated", nbt.Byte(1)),
+		}),
+	})
+}
+
+func makeMcRegionLevel(t *testing.T) string {
+	dir := t.TempDir()
+
+	f, err := NewFilePath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// McRegion files share the container format of Anvil files.
+	if err = f.SetChunk(mcRegionChunk(0, 0), mcRegionChunk(-1, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.WriteLevelDat(nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Data", nbt.Compound{
+			nbt.NewTag("LevelName", nbt.String("old")),
+			nbt.NewTag("SpawnX", nbt.Int(0)),
+			nbt.NewTag("SpawnY", nbt.Int(64)),
+			nbt.NewTag("SpawnZ", nbt.Int(0)),
+			nbt.NewTag("version", nbt.Int(19132)),
+		}),
+	})); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, r := range [...]string{"r.0.0", "r.-1.0"} {
+		name := filepath.Join(dir, "region", r)
+
+		if err = os.Rename(name+".mca", name+".mcr"); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	return dir
+}
+
+func testMcRegionLevel(t *testing.T, p Path) {
+	t.Helper()
+
+	l, err := NewLevel(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, test := range [...]struct {
+		x, y, z int32
+		block   Block
+	}{
+		{0, 0, 0, Block{ID: 7}},
+		{0, 39, 0, Block{ID: 1}},
+		{0, 40, 0, Block{}},
+		{15, 54, 15, Block{ID: 1}},
+		{15, 55, 15, Block{}},
+		{3, 64, 4, Block{ID: 35, Data: 5}},
+		{5, 100, 7, Block{ID: 20}},
+		{-16, 0, 32, Block{ID: 7}},
+		{-1, 54, 47, Block{ID: 1}},
+	} {
+		if b, err := l.GetBlock(test.x, test.y, test.z); err != nil {
+			t.Errorf("%d,%d,%d: unexpected error: %s", test.x, test.y, test.z, err)
+		} else if !b.EqualBlock(test.block) {
+			t.Errorf("%d,%d,%d: expecting block %s, got %s", test.x, test.y, test.z, test.block, b)
+		}
+	}
+
+	if h, err := l.GetHeight(2, 9); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if h != 42 {
+		t.Errorf("expecting height 42, got %d", h)
+	}
+
+	if h, err := l.GetHeight(3, 4); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if h != 65 {
+		t.Errorf("expecting height 65, got %d", h)
+	}
+}
+
+func TestMcRegionPath(t *testing.T) {
+	dir := makeMcRegionLevel(t)
+
+	p, err := OpenMcRegionPath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if regions := p.GetRegions(); len(regions) != 2 {
+		t.Errorf("expecting 2 regions, got %v", regions)
+	}
+
+	chunk, err := p.GetChunk(0, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sections := chunk.Data().(nbt.Compound).Get("Level").Data().(nbt.Compound).Get("Sections").Data().(nbt.List)
+	if sections.Len() != 6 {
+		t.Errorf("expecting 6 sections, got %d", sections.Len())
+	}
+
+	if c, err := newChunk(0, 0, chunk); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if sl := c.GetSkyLight(3, 65, 4); sl != 15 {
+		t.Errorf("expecting sky light 15, got %d", sl)
+	} else if sl = c.GetSkyLight(3, 66, 4); sl != 0 {
+		t.Errorf("expecting sky light 0, got %d", sl)
+	}
+
+	testMcRegionLevel(t, p)
+
+	if err = p.SetChunk(chunk); err != ErrReadOnly {
+		t.Errorf("expecting ErrReadOnly, got %v", err)
+	}
+
+	anvil, err := NewFilePath(t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer anvil.Close()
+
+	if err = p.ConvertTo(anvil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testMcRegionLevel(t, anvil)
+
+	if levelDat, err := anvil.ReadLevelDat(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if version := levelDat.Data().(nbt.Compound).Get("Data").Data().(nbt.Compound).Get("version").Data(); version != nbt.Int(19133) {
+		t.Errorf("expecting version 19133, got %v", version)
+	}
+}
@@ -0,0 +1,255 @@
+package minecraft
+
+import (
+	"context"
+	"sort"
+
+	"vimagination.zapto.org/memio"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// ChunkLister is implemented by Path types that can list the chunks they
+// contain.
+type ChunkLister interface {
+	// GetRegions returns a list of region x,z coords of all generated
+	// regions.
+	GetRegions() [][2]int32
+	// GetChunks returns a list of all chunks within a region with coords
+	// x,z.
+	GetChunks(int32, int32) ([][2]int32, error)
+}
+
+// GetRegions returns a list of region x,z coords of all regions that contain
+// chunks.
+func (m *MemPath) GetRegions() [][2]int32 {
+	return sortedRegions(m.chunks)
+}
+
+// GetChunks returns a list of all chunks within a region with coords x,z.
+func (m *MemPath) GetChunks(x, z int32) ([][2]int32, error) {
+	return m.GetStorageChunks(StorageRegion, x, z)
+}
+
+// GetStorageRegions returns a list of region x,z coords of all regions of the
+// given storage kind that contain chunks.
+func (m *MemPath) GetStorageRegions(s Storage) [][2]int32 {
+	return sortedRegions(m.storageChunks(s))
+}
+
+// GetStorageChunks returns a list of all chunks of the given storage kind
+// within a region with coords x,z.
+func (m *MemPath) GetStorageChunks(s Storage, x, z int32) ([][2]int32, error) {
+	var toRet [][2]int32
+
+	for pos := range m.storageChunks(s) {
+		if cx, cz := int32(pos), int32(pos>>32); cx>>5 == x && cz>>5 == z {
+			toRet = append(toRet, [2]int32{cx, cz})
+		}
+	}
+
+	sortCoords(toRet)
+
+	return toRet, nil
+}
+
+func (m *MemPath) storageChunks(s Storage) map[uint64]memio.Buffer {
+	if s == StorageRegion {
+		return m.chunks
+	}
+
+	return m.storage[s]
+}
+
+// Chunks returns an iterator over all chunks that match all of the given
+// filters.
+//
+// A MemPath does not record when chunks were modified, so the Modified time of
+// each chunk is zero. The Size is that of the compressed chunk data.
+func (m *MemPath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	return m.StorageChunks(ctx, StorageRegion, filters...)
+}
+
+// StorageChunks returns an iterator over all chunks of the given storage kind
+// that match all of the given filters.
+func (m *MemPath) StorageChunks(ctx context.Context, s Storage, filters ...ChunkFilter) *ChunkIterator {
+	chunks := m.storageChunks(s)
+	infos := make([]ChunkInfo, 0, len(chunks))
+
+	for pos, buf := range chunks {
+		infos = append(infos, ChunkInfo{
+			X:           int32(pos),
+			Z:           int32(pos >> 32),
+			Size:        int64(len(buf)),
+			Compression: Zlib,
+		})
+	}
+
+	regions, grouped := groupChunks(infos)
+
+	return newChunkIterator(ctx, regions, func(x, z int32) ([]ChunkInfo, error) {
+		return grouped[[2]int32{x, z}], nil
+	}, filters)
+}
+
+// HasLevelDat returns whether level data has been written to the MemPath.
+func (m *MemPath) HasLevelDat() bool {
+	return len(m.level) > 0
+}
+
+func sortedRegions(chunks map[uint64]memio.Buffer) [][2]int32 {
+	regions := make(map[[2]int32]struct{})
+
+	for pos := range chunks {
+		regions[[2]int32{int32(pos) >> 5, int32(pos>>32) >> 5}] = struct{}{}
+	}
+
+	toRet := make([][2]int32, 0, len(regions))
+
+	for r := range regions {
+		toRet = append(toRet, r)
+	}
+
+	sortCoords(toRet)
+
+	return toRet
+}
+
+func sortCoords(coords [][2]int32) {
+	sort.Slice(coords, func(i, j int) bool {
+		if coords[i][1] == coords[j][1] {
+			return coords[i][0] < coords[j][0]
+		}
+
+		return coords[i][1] < coords[j][1]
+	})
+}
+
+// WriteToDir writes the contents of the MemPath as a minecraft level in the
+// given directory, which will be created if it does not exist.
+//
+// The options are used to create the FilePath that the level is written
+// through.
+//
+// This is not named WriteTo, as that name is reserved for the io.WriterTo
+// method, WriteTo(io.Writer) (int64, error), which go vet enforces.
+func (m *MemPath) WriteToDir(dir string, options ...FilePathOption) error {
+	p, err := NewFilePath(dir, options...)
+	if err != nil {
+		return err
+	}
+
+	err = m.writeTo(p)
+
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (m *MemPath) writeTo(p *FilePath) error {
+	for _, r := range m.GetRegions() {
+		coords, _ := m.GetChunks(r[0], r[1])
+		chunks := make([]nbt.Tag, 0, len(coords))
+
+		for _, c := range coords {
+			chunk, err := m.GetChunk(c[0], c[1])
+			if err != nil {
+				return err
+			}
+
+			chunks = append(chunks, chunk)
+		}
+
+		if err := p.SetChunk(chunks...); err != nil {
+			return err
+		}
+	}
+
+	for s, chunks := range m.storage {
+		for pos, buf := range chunks {
+			chunk, err := m.read(buf)
+			if err != nil {
+				return err
+			} else if err = p.SetStorageChunk(s, int32(pos), int32(pos>>32), chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.HasLevelDat() {
+		levelDat, err := m.ReadLevelDat()
+		if err != nil {
+			return err
+		}
+
+		return p.WriteLevelDat(levelDat)
+	}
+
+	return nil
+}
+
+// LoadMemPath creates a new MemPath containing a copy of the level data and
+// all of the chunks from the given Path, which must implement ChunkLister.
+//
+// If the Path implements StorageLister, the chunks of the other storage kinds,
+// such as entities, are also copied.
+func LoadMemPath(p Path) (*MemPath, error) {
+	l, ok := p.(ChunkLister)
+	if !ok {
+		return nil, ErrCannotListChunks
+	}
+
+	m := NewMemPath()
+
+	for _, r := range l.GetRegions() {
+		coords, err := l.GetChunks(r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range coords {
+			chunk, err := p.GetChunk(c[0], c[1])
+			if err != nil {
+				return nil, err
+			} else if chunk.TagID() == 0 {
+				continue
+			} else if err = m.SetChunk(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if sl, ok := p.(StorageLister); ok {
+		for _, s := range storageKinds {
+			for _, r := range sl.GetStorageRegions(s) {
+				coords, err := sl.GetStorageChunks(s, r[0], r[1])
+				if err != nil {
+					return nil, err
+				}
+
+				for _, c := range coords {
+					chunk, err := sl.GetStorageChunk(s, c[0], c[1])
+					if err != nil {
+						return nil, err
+					} else if chunk.TagID() == 0 {
+						continue
+					} else if err = m.SetStorageChunk(s, c[0], c[1], chunk); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	levelDat, err := p.ReadLevelDat()
+	if err != nil {
+		return nil, err
+	} else if levelDat.TagID() != 0 {
+		if err = m.WriteLevelDat(levelDat); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,107 @@
+package minecraft
+
+import (
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestMemPathPersist(t *testing.T) {
+	m := NewMemPath()
+
+	if m.HasLevelDat() {
+		t.Error("expecting no level.dat")
+	}
+
+	levelDat := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{nbt.NewTag("LevelName", nbt.String("mem"))})})
+	entities := nbt.NewTag("", nbt.Compound{nbt.NewTag("Position", nbt.IntArray{1, 0})})
+
+	if err := m.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1), addPos(-1, 33, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = m.SetStorageChunk(StorageEntities, 1, 0, entities); err != nil {
+		t.Fatal(err.Error())
+	} else if err = m.WriteLevelDat(levelDat); err != nil {
+		t.Fatal(err.Error())
+	} else if !m.HasLevelDat() {
+		t.Error("expecting level.dat")
+	}
+
+	if regions := m.GetRegions(); len(regions) != 2 || regions[0] != [2]int32{0, 0} || regions[1] != [2]int32{-1, 1} {
+		t.Errorf("expecting regions [0 0] and [-1 1], got %v", regions)
+	}
+
+	if chunks, err := m.GetChunks(0, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if len(chunks) != 2 || chunks[0] != [2]int32{0, 0} || chunks[1] != [2]int32{1, 0} {
+		t.Errorf("expecting chunks [0 0] and [1 0], got %v", chunks)
+	}
+
+	dir := t.TempDir()
+
+	if err := m.WriteToDir(dir); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	f, err := NewFilePath(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if chunk, err := f.GetStorageChunk(StorageEntities, 1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(entities) {
+		t.Error("entities chunk does not match")
+	}
+
+	loaded, err := LoadMemPath(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, c := range [...]struct {
+		x, z     int32
+		chunkNum uint8
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{-1, 33, 2},
+	} {
+		if chunk, err := loaded.GetChunk(c.x, c.z); err != nil {
+			t.Errorf("chunk %d,%d: unexpected error: %s", c.x, c.z, err)
+		} else if !chunk.Equal(addPos(c.x, c.z, c.chunkNum)) {
+			t.Errorf("chunk %d,%d: chunk data does not match", c.x, c.z)
+		}
+	}
+
+	if chunk, err := loaded.GetStorageChunk(StorageEntities, 1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(entities) {
+		t.Error("loaded entities chunk does not match")
+	}
+
+	saved := t.TempDir()
+
+	if err = loaded.WriteToDir(saved); err != nil {
+		t.Fatal(err.Error())
+	} else if g, err := NewFilePath(saved); err != nil {
+		t.Fatal(err.Error())
+	} else if chunk, err := g.GetStorageChunk(StorageEntities, 1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(entities) {
+		t.Error("entities chunk does not match after round trip")
+	} else if err = g.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !loaded.HasLevelDat() {
+		t.Error("expecting loaded level.dat")
+	} else if l, err := loaded.ReadLevelDat(); err != nil {
+		t.Fatal(err.Error())
+	} else if !l.Equal(levelDat) {
+		t.Error("level.dat does not match")
+	}
+
+	if _, err = LoadMemPath(NewOverlayPath(m, NewMemPath())); err != ErrCannotListChunks {
+		t.Errorf("expecting ErrCannotListChunks, got %v", err)
+	}
+}
@@ -80,6 +80,8 @@ func (d Decoder) decodeData(tagID TagID) (Data, error) {
 		data, err = d.decodeCompound()
 	case TagIntArray:
 		data, err = d.decodeIntArray()
+	case TagLongArray:
+		data, err = d.decodeLongArray()
 	case TagBool:
 		data, err = d.decodeBool()
 	case TagUint8:
@@ -238,6 +240,24 @@ func (d Decoder) decodeIntArray() (IntArray, error) {
 	return ints, nil
 }
 
+// DecodeLongArray will read a LongArray Data.
+func (d Decoder) decodeLongArray() (LongArray, error) {
+	l, _, err := d.r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	longs := make(LongArray, l)
+
+	for i := uint32(0); i < l; i++ {
+		if longs[i], _, err = d.r.ReadInt64(); err != nil {
+			return nil, err
+		}
+	}
+
+	return longs, nil
+}
+
 func (d Decoder) decodeBool() (Bool, error) {
 	b, _, err := d.r.ReadUint8()
 
@@ -69,6 +69,8 @@ func (e Encoder) encodeData(d Data) error {
 		err = e.encodeCompound(d)
 	case IntArray:
 		err = e.encodeIntArray(d)
+	case LongArray:
+		err = e.encodeLongArray(d)
 	case Bool:
 		err = e.encodeBool(d)
 	case Uint8:
@@ -201,6 +203,21 @@ func (e Encoder) encodeIntArray(ints IntArray) error {
 	return nil
 }
 
+// EncodeLongArray will write a LongArray Data
+func (e Encoder) encodeLongArray(longs LongArray) error {
+	_, err := e.w.WriteUint32(uint32(len(longs)))
+	if err != nil {
+		return err
+	}
+	for _, l := range longs {
+		_, err = e.w.WriteInt64(l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e Encoder) encodeBool(b Bool) error {
 	var err error
 	if b {
@@ -45,6 +45,16 @@ func TestSmall(t *testing.T) { //test.nbt
 		}), t)
 }
 
+func TestLongArray(t *testing.T) {
+	testNBT(`CgAFYXJyYXkMAAVsb25ncwAAAAMAAAAAAAAAAf//////////f/////////8JAARsaXN0DAAAAAEAAAAB//////////sA`,
+		NewTag("array", Compound{
+			NewTag("longs", LongArray{1, -1, 9223372036854775807}),
+			NewTag("list", NewList([]Data{
+				LongArray{-5},
+			})),
+		}), t)
+}
+
 func byteArrayTestData() []int8 {
 	data := make([]int8, 1000)
 	for i := 0; i < 1000; i++ {
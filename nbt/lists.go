@@ -1277,6 +1277,165 @@ func (l ListData) ListIntArray() ListIntArray {
 	return s
 }
 
+// ListLongArray satisfies the List interface for a list of LongArrays.
+type ListLongArray []LongArray
+
+// Equal satisfies the equaler.Equaler interface, allowing for types to be
+// checked for equality
+func (l ListLongArray) Equal(e interface{}) bool {
+	m, ok := e.(ListLongArray)
+	if !ok {
+		var n *ListLongArray
+
+		if n, ok = e.(*ListLongArray); ok {
+			m = *n
+		}
+	}
+
+	if ok {
+		if len(l) == len(m) {
+			for n, t := range m {
+				if !t.Equal(l[n]) {
+					return false
+				}
+			}
+
+			return true
+		}
+	} else if d, ok := e.(List); ok && d.TagType() == TagLongArray && d.Len() == len(l) {
+		for i := 0; i < d.Len(); i++ {
+			if !d.Get(i).Equal(l[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Copy simply returns a deep-copy of the data.
+func (l ListLongArray) Copy() Data {
+	m := make(ListLongArray, len(l))
+	for n, e := range l {
+		m[n] = e.Copy().(LongArray)
+	}
+
+	return &m
+}
+
+func (l ListLongArray) String() string {
+	s := strconv.Itoa(len(l)) + " entries of type LongArray {"
+
+	for _, d := range l {
+		s += "\n        LongArray: " + indent(d.String())
+	}
+
+	return s + "\n}"
+}
+
+// Type returns the TagID of the data.
+func (ListLongArray) Type() TagID {
+	return TagList
+}
+
+// TagType returns the TagID of the type of tag this list contains.
+func (ListLongArray) TagType() TagID {
+	return TagLongArray
+}
+
+// Set sets the data at the given position. It does not append.
+func (l ListLongArray) Set(i int, d Data) error {
+	if m, ok := d.(LongArray); ok {
+		if i <= 0 || i >= len(l) {
+			return ErrBadRange
+		}
+
+		l[i] = m
+	} else {
+		return &WrongTag{TagLongArray, d.Type()}
+	}
+
+	return nil
+}
+
+// Get returns the data at the given position.
+func (l ListLongArray) Get(i int) Data {
+	return l[i]
+}
+
+// Append adds data to the list
+func (l *ListLongArray) Append(d ...Data) error {
+	toAppend := make(ListLongArray, len(d))
+
+	for n, e := range d {
+		if f, ok := e.(LongArray); ok {
+			toAppend[n] = f
+		} else {
+			return &WrongTag{TagLongArray, e.Type()}
+		}
+	}
+
+	*l = append(*l, toAppend...)
+
+	return nil
+}
+
+// Insert will add the given data at the specified position, moving other
+// up.
+func (l *ListLongArray) Insert(i int, d ...Data) error {
+	if i >= len(*l) {
+		return l.Append(d...)
+	}
+
+	toInsert := make(ListLongArray, len(d), len(d)+len(*l)-i)
+
+	for n, e := range d {
+		if f, ok := e.(LongArray); ok {
+			toInsert[n] = f
+		} else {
+			return &WrongTag{TagLongArray, e.Type()}
+		}
+	}
+
+	*l = append((*l)[:i], append(toInsert, (*l)[i:]...)...)
+
+	return nil
+}
+
+// Remove deletes the specified position and shifts remaining data down.
+func (l *ListLongArray) Remove(i int) {
+	if i >= len(*l) {
+		return
+	}
+
+	copy((*l)[i:], (*l)[i+1:])
+
+	*l = (*l)[:len(*l)-1]
+
+}
+
+// Len returns the length of the list.
+func (l ListLongArray) Len() int {
+	return len(l)
+}
+
+// ListLongArray returns the list as a specifically typed List.
+func (l ListData) ListLongArray() ListLongArray {
+	if l.tagType != TagLongArray {
+		return nil
+	}
+
+	s := make(ListLongArray, len(l.data))
+
+	for n, v := range l.data {
+		s[n] = v.(LongArray)
+	}
+
+	return s
+}
+
 // ListBool satisfies the List interface for a list of Bools.
 type ListBool []Bool
 
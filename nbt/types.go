@@ -18,13 +18,14 @@ const (
 	TagList       TagID = 9
 	TagCompound   TagID = 10
 	TagIntArray   TagID = 11
-	TagBool       TagID = 12
-	TagUint8      TagID = 13
-	TagUint16     TagID = 14
-	TagUint32     TagID = 15
-	TagUint64     TagID = 16
-	TagComplex64  TagID = 17
-	TagComplex128 TagID = 18
+	TagLongArray  TagID = 12
+	TagBool       TagID = 13
+	TagUint8      TagID = 14
+	TagUint16     TagID = 15
+	TagUint32     TagID = 16
+	TagUint64     TagID = 17
+	TagComplex64  TagID = 18
+	TagComplex128 TagID = 19
 )
 
 var tagIDNames = [...]string{
@@ -40,6 +41,7 @@ var tagIDNames = [...]string{
 	"List",
 	"Compound",
 	"Int Array",
+	"Long Array",
 }
 
 // TagID represents the type of nbt tag.
@@ -457,6 +459,9 @@ func newListWithLength(tagType TagID, length uint32) List {
 	case TagIntArray:
 		m := make(ListIntArray, 0, length)
 		l = &m
+	case TagLongArray:
+		m := make(ListLongArray, 0, length)
+		l = &m
 	case TagBool:
 		m := make(ListBool, 0, length)
 		l = &m
@@ -757,6 +762,55 @@ func (IntArray) Type() TagID {
 	return TagIntArray
 }
 
+// LongArray is an implementation of the Data interface.
+type LongArray []int64
+
+// Copy simply returns a copy of the data.
+func (l LongArray) Copy() Data {
+	c := make(LongArray, len(l))
+
+	copy(c, l)
+
+	return c
+}
+
+// Equal satisfies the equaler.Equaler interface, allowing for types to be
+// checked for equality.
+func (l LongArray) Equal(e interface{}) bool {
+	if m, ok := e.(LongArray); ok {
+		if len(l) == len(m) {
+			for j, o := range l {
+				if o != m[j] {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l LongArray) String() string {
+	var data []byte
+
+	for n, d := range l {
+		if n > 0 {
+			data = append(data, ',', ' ')
+		}
+
+		data = append(data, strconv.FormatInt(d, 10)...)
+	}
+
+	return "[" + strconv.FormatInt(int64(len(l)), 10) + " longs] [" + string(data) + "]"
+}
+
+// Type returns the TagID of the data.
+func (LongArray) Type() TagID {
+	return TagLongArray
+}
+
 // Bool is an implementation of the Data interface.
 type Bool bool
 
@@ -0,0 +1,313 @@
+package minecraft
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// ChunkChange describes a chunk that has been modified in an OverlayPath.
+type ChunkChange struct {
+	X, Z    int32
+	Removed bool
+}
+
+// OverlayPath is a copy-on-write Path that reads from a base Path and writes
+// all changes to an upper Path, such as a MemPath, leaving the base unchanged
+// until the changes are committed.
+//
+// An OverlayPath is safe for concurrent use if both the base and upper Paths
+// are.
+type OverlayPath struct {
+	base, upper Path
+
+	mu       sync.RWMutex
+	changes  map[uint64]bool
+	levelDat bool
+}
+
+// NewOverlayPath creates a new OverlayPath that reads from base and writes to
+// upper.
+//
+// The upper Path should start empty, as only those chunks written through the
+// OverlayPath will be read from it.
+func NewOverlayPath(base, upper Path) *OverlayPath {
+	return &OverlayPath{
+		base:    base,
+		upper:   upper,
+		changes: make(map[uint64]bool),
+	}
+}
+
+// GetChunk returns the chunk at chunk coords x, z, from the upper Path if it
+// has been changed and from the base Path otherwise.
+func (o *OverlayPath) GetChunk(x, z int32) (nbt.Tag, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	removed, changed := o.changes[uint64(z)<<32|uint64(uint32(x))]
+	if !changed {
+		return o.base.GetChunk(x, z)
+	} else if removed {
+		return nbt.Tag{}, nil
+	}
+
+	return o.upper.GetChunk(x, z)
+}
+
+// SetChunk saves multiple chunks to the upper Path.
+func (o *OverlayPath) SetChunk(data ...nbt.Tag) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, d := range data {
+		x, z, err := chunkCoords(d)
+		if err != nil {
+			return err
+		}
+
+		if err = o.upper.SetChunk(d); err != nil {
+			return err
+		}
+
+		o.changes[uint64(z)<<32|uint64(uint32(x))] = false
+	}
+
+	return nil
+}
+
+// RemoveChunk marks the chunk at chunk coords x, z as removed.
+func (o *OverlayPath) RemoveChunk(x, z int32) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pos := uint64(z)<<32 | uint64(uint32(x))
+
+	if removed, changed := o.changes[pos]; changed && !removed {
+		if err := o.upper.RemoveChunk(x, z); err != nil {
+			return err
+		}
+	}
+
+	o.changes[pos] = true
+
+	return nil
+}
+
+// ReadLevelDat returns the level data, from the upper Path if it has been
+// changed and from the base Path otherwise.
+func (o *OverlayPath) ReadLevelDat() (nbt.Tag, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.levelDat {
+		return o.upper.ReadLevelDat()
+	}
+
+	return o.base.ReadLevelDat()
+}
+
+// WriteLevelDat writes the level data to the upper Path.
+func (o *OverlayPath) WriteLevelDat(data nbt.Tag) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.upper.WriteLevelDat(data); err != nil {
+		return err
+	}
+
+	o.levelDat = true
+
+	return nil
+}
+
+// Chunks returns an iterator over all chunks that match all of the given
+// filters, with the information for changed chunks coming from the upper Path
+// and for all others from the base Path.
+//
+// Both the base and upper Paths must implement ChunkInfoLister, otherwise the
+// iterator will stop with ErrCannotListChunks. Chunks changed after the
+// iterator is created will not be reflected in the results.
+func (o *OverlayPath) Chunks(ctx context.Context, filters ...ChunkFilter) *ChunkIterator {
+	bl, ok := o.base.(ChunkInfoLister)
+	if !ok {
+		return &ChunkIterator{err: ErrCannotListChunks}
+	}
+
+	ul, ok := o.upper.(ChunkInfoLister)
+	if !ok {
+		return &ChunkIterator{err: ErrCannotListChunks}
+	}
+
+	base := bl.Chunks(ctx)
+	if base.err != nil {
+		return base
+	}
+
+	upper := ul.Chunks(ctx)
+	if upper.err != nil {
+		return upper
+	}
+
+	o.mu.RLock()
+
+	changes := make(map[uint64]bool, len(o.changes))
+
+	for pos, removed := range o.changes {
+		changes[pos] = removed
+	}
+
+	o.mu.RUnlock()
+
+	regionSet := make(map[[2]int32]struct{})
+
+	for _, r := range append(base.regions, upper.regions...) {
+		regionSet[r] = struct{}{}
+	}
+
+	regions := make([][2]int32, 0, len(regionSet))
+
+	for r := range regionSet {
+		regions = append(regions, r)
+	}
+
+	sortCoords(regions)
+
+	return newChunkIterator(ctx, regions, func(x, z int32) ([]ChunkInfo, error) {
+		baseChunks, err := base.region(x, z)
+		if err != nil {
+			return nil, err
+		}
+
+		upperChunks, err := upper.region(x, z)
+		if err != nil {
+			return nil, err
+		}
+
+		var chunks []ChunkInfo
+
+		for _, c := range baseChunks {
+			if _, changed := changes[uint64(c.Z)<<32|uint64(uint32(c.X))]; !changed {
+				chunks = append(chunks, c)
+			}
+		}
+
+		for _, c := range upperChunks {
+			if removed, changed := changes[uint64(c.Z)<<32|uint64(uint32(c.X))]; changed && !removed {
+				chunks = append(chunks, c)
+			}
+		}
+
+		_, grouped := groupChunks(chunks)
+
+		return grouped[[2]int32{x, z}], nil
+	}, filters)
+}
+
+// Diff returns a list of all chunks that have been changed, sorted by z and
+// then x.
+func (o *OverlayPath) Diff() []ChunkChange {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.diff()
+}
+
+func (o *OverlayPath) diff() []ChunkChange {
+	changes := make([]ChunkChange, 0, len(o.changes))
+
+	for pos, removed := range o.changes {
+		changes = append(changes, ChunkChange{X: int32(pos), Z: int32(pos >> 32), Removed: removed})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Z == changes[j].Z {
+			return changes[i].X < changes[j].X
+		}
+
+		return changes[i].Z < changes[j].Z
+	})
+
+	return changes
+}
+
+// LevelDatChanged returns whether the level data has been changed.
+func (o *OverlayPath) LevelDatChanged() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.levelDat
+}
+
+// Commit writes all changes to the base Path and then discards them from the
+// upper Path.
+//
+// If an error occurs, the changes remain in the upper Path, and Commit can be
+// retried.
+func (o *OverlayPath) Commit() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var chunks []nbt.Tag
+
+	for _, c := range o.diff() {
+		if c.Removed {
+			if err := o.base.RemoveChunk(c.X, c.Z); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		chunk, err := o.upper.GetChunk(c.X, c.Z)
+		if err != nil {
+			return err
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) > 0 {
+		if err := o.base.SetChunk(chunks...); err != nil {
+			return err
+		}
+	}
+
+	if o.levelDat {
+		levelDat, err := o.upper.ReadLevelDat()
+		if err != nil {
+			return err
+		} else if err = o.base.WriteLevelDat(levelDat); err != nil {
+			return err
+		}
+	}
+
+	return o.discard()
+}
+
+// Discard removes all changes from the upper Path, leaving the base Path
+// unchanged.
+func (o *OverlayPath) Discard() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.discard()
+}
+
+func (o *OverlayPath) discard() error {
+	for pos, removed := range o.changes {
+		if !removed {
+			if err := o.upper.RemoveChunk(int32(pos), int32(pos>>32)); err != nil {
+				return err
+			}
+		}
+
+		delete(o.changes, pos)
+	}
+
+	o.levelDat = false
+
+	return nil
+}
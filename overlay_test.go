@@ -0,0 +1,114 @@
+package minecraft
+
+import (
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestOverlayPath(t *testing.T) {
+	base, err := NewFilePath(t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	baseLevelDat := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{nbt.NewTag("LevelName", nbt.String("base"))})})
+	newLevelDat := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{nbt.NewTag("LevelName", nbt.String("new"))})})
+
+	if err = base.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1), addPos(2, 0, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = base.WriteLevelDat(baseLevelDat); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	o := NewOverlayPath(base, NewMemPath())
+
+	edit := func() {
+		if err := o.SetChunk(addPos(0, 0, 3), addPos(-5, 3, 1)); err != nil {
+			t.Fatal(err.Error())
+		} else if err = o.RemoveChunk(1, 0); err != nil {
+			t.Fatal(err.Error())
+		} else if err = o.WriteLevelDat(newLevelDat); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	check := func(name string, p Path, edited bool) {
+		t.Helper()
+
+		for _, c := range [...]struct {
+			x, z            int32
+			before, after   uint8
+			existed, exists bool
+		}{
+			{0, 0, 0, 3, true, true},
+			{1, 0, 1, 0, true, false},
+			{2, 0, 2, 2, true, true},
+			{-5, 3, 0, 1, false, true},
+		} {
+			chunkNum, exists := c.before, c.existed
+
+			if edited {
+				chunkNum, exists = c.after, c.exists
+			}
+
+			if chunk, err := p.GetChunk(c.x, c.z); err != nil {
+				t.Errorf("%s: chunk %d,%d: unexpected error: %s", name, c.x, c.z, err)
+			} else if (chunk.TagID() != 0) != exists {
+				t.Errorf("%s: chunk %d,%d: expecting exists to be %v", name, c.x, c.z, exists)
+			} else if exists && !chunk.Equal(addPos(c.x, c.z, chunkNum)) {
+				t.Errorf("%s: chunk %d,%d: chunk data does not match", name, c.x, c.z)
+			}
+		}
+
+		levelDat := baseLevelDat
+
+		if edited {
+			levelDat = newLevelDat
+		}
+
+		if l, err := p.ReadLevelDat(); err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+		} else if !l.Equal(levelDat) {
+			t.Errorf("%s: level.dat does not match", name)
+		}
+	}
+
+	edit()
+	check("overlay", o, true)
+	check("base", base, false)
+
+	expected := []ChunkChange{{0, 0, false}, {1, 0, true}, {-5, 3, false}}
+
+	if diff := o.Diff(); len(diff) != len(expected) {
+		t.Errorf("expecting %d changes, got %v", len(expected), diff)
+	} else {
+		for n, c := range expected {
+			if diff[n] != c {
+				t.Errorf("change %d: expecting %v, got %v", n+1, c, diff[n])
+			}
+		}
+	}
+
+	if !o.LevelDatChanged() {
+		t.Error("expecting level.dat to be changed")
+	}
+
+	if err = o.Discard(); err != nil {
+		t.Fatal(err.Error())
+	} else if diff := o.Diff(); len(diff) != 0 {
+		t.Errorf("expecting no changes after discard, got %v", diff)
+	}
+
+	check("discarded", o, false)
+	edit()
+
+	if err = o.Commit(); err != nil {
+		t.Fatal(err.Error())
+	} else if diff := o.Diff(); len(diff) != 0 {
+		t.Errorf("expecting no changes after commit, got %v", diff)
+	}
+
+	check("committed overlay", o, true)
+	check("committed base", base, true)
+}
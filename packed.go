@@ -0,0 +1,107 @@
+package minecraft
+
+import "math/bits"
+
+// PackedBits returns the number of bits used to store each index into a
+// palette of the given size, with the given minimum.
+func PackedBits(paletteSize int, min uint8) uint8 {
+	b := uint8(bits.Len(uint(paletteSize - 1)))
+	if b < min {
+		return min
+	}
+
+	return b
+}
+
+// PackedLength returns the number of longs needed to store count values of
+// the given bit width.
+//
+// When padded is true, values do not span multiple longs, with any remaining
+// bits left unused, as used by Minecraft 1.16 and later. Otherwise values are
+// stored contiguously, as used by Minecraft 1.13 to 1.15 and by Litematica.
+func PackedLength(count int, bits uint8, padded bool) int {
+	if bits == 0 {
+		return 0
+	} else if padded {
+		perLong := 64 / int(bits)
+
+		return (count + perLong - 1) / perLong
+	}
+
+	return (count*int(bits) + 63) / 64
+}
+
+// UnpackBits reads count values of the given bit width from a packed long
+// array.
+func UnpackBits(data []int64, bits uint8, count int, padded bool) ([]uint32, error) {
+	if len(data) < PackedLength(count, bits, padded) {
+		return nil, ErrPackedLength
+	}
+
+	values := make([]uint32, count)
+
+	if bits == 0 {
+		return values, nil
+	}
+
+	mask := uint64(1)<<bits - 1
+
+	if padded {
+		perLong := 64 / int(bits)
+
+		for n := range values {
+			values[n] = uint32(uint64(data[n/perLong]) >> (uint(n%perLong) * uint(bits)) & mask)
+		}
+
+		return values, nil
+	}
+
+	for n := range values {
+		pos := n * int(bits)
+		l, off := pos>>6, uint(pos&63)
+		v := uint64(data[l]) >> off
+
+		if off+uint(bits) > 64 {
+			v |= uint64(data[l+1]) << (64 - off)
+		}
+
+		values[n] = uint32(v & mask)
+	}
+
+	return values, nil
+}
+
+// PackBits stores the given values, of the given bit width, into a packed long
+// array.
+func PackBits(values []uint32, bits uint8, padded bool) []int64 {
+	data := make([]int64, PackedLength(len(values), bits, padded))
+
+	if bits == 0 {
+		return data
+	}
+
+	mask := uint64(1)<<bits - 1
+
+	if padded {
+		perLong := 64 / int(bits)
+
+		for n, v := range values {
+			data[n/perLong] |= int64((uint64(v) & mask) << (uint(n%perLong) * uint(bits)))
+		}
+
+		return data
+	}
+
+	for n, v := range values {
+		pos := n * int(bits)
+		l, off := pos>>6, uint(pos&63)
+		u := uint64(v) & mask
+		data[l] |= int64(u << off)
+
+		if off+uint(bits) > 64 {
+			data[l+1] |= int64(u >> (64 - off))
+		}
+	}
+
+	return data
+}
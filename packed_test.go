@@ -0,0 +1,62 @@
+package minecraft
+
+import "testing"
+
+func TestPackBits(t *testing.T) {
+	for n, test := range [...]struct {
+		bits   uint8
+		padded bool
+		length int
+	}{
+		{4, false, 7},
+		{4, true, 7},
+		{5, false, 8},
+		{5, true, 9},
+		{13, false, 21},
+		{13, true, 25},
+	} {
+		values := make([]uint32, 100)
+
+		for i := range values {
+			values[i] = uint32(i*7919) & (1<<test.bits - 1)
+		}
+
+		data := PackBits(values, test.bits, test.padded)
+		if len(data) != test.length {
+			t.Errorf("test %d: expecting %d longs, got %d", n+1, test.length, len(data))
+
+			continue
+		}
+
+		got, err := UnpackBits(data, test.bits, len(values), test.padded)
+		if err != nil {
+			t.Fatalf("test %d: %s", n+1, err)
+		}
+
+		for i, v := range values {
+			if got[i] != v {
+				t.Errorf("test %d: expecting value %d to be %d, got %d", n+1, i, v, got[i])
+
+				break
+			}
+		}
+
+		if _, err := UnpackBits(data[:len(data)-1], test.bits, len(values), test.padded); err != ErrPackedLength {
+			t.Errorf("test %d: expecting ErrPackedLength, got %v", n+1, err)
+		}
+	}
+
+	// A value spanning two longs in the unpacked format.
+	data := []int64{-0x6000000000000000, 0x1}
+	if got, err := UnpackBits(data, 5, 13, false); err != nil {
+		t.Fatal(err.Error())
+	} else if got[12] != 26 {
+		t.Errorf("expecting spanning value 26, got %d", got[12])
+	}
+
+	if b := PackedBits(1, 4); b != 4 {
+		t.Errorf("expecting minimum of 4 bits, got %d", b)
+	} else if b = PackedBits(17, 4); b != 5 {
+		t.Errorf("expecting 5 bits, got %d", b)
+	}
+}
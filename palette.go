@@ -0,0 +1,502 @@
+package minecraft
+
+import "vimagination.zapto.org/minecraft/nbt"
+
+const airState = "minecraft:air"
+
+func stateFromCompound(c nbt.Compound) BlockState {
+	name, _ := c.Get("Name").Data().(nbt.String)
+	state := BlockState{Name: string(name)}
+
+	if props, ok := c.Get("Properties").Data().(nbt.Compound); ok && len(props) > 0 {
+		state.Properties = make(map[string]string, len(props))
+
+		for _, p := range props {
+			if v, ok := p.Data().(nbt.String); ok {
+				state.Properties[p.Name()] = string(v)
+			}
+		}
+	}
+
+	return state
+}
+
+func stateToCompound(state BlockState) nbt.Compound {
+	c := nbt.Compound{nbt.NewTag("Name", nbt.String(state.Name))}
+
+	if len(state.Properties) > 0 {
+		props := make(nbt.Compound, 0, len(state.Properties))
+
+		for _, k := range state.PropertyNames() {
+			props = append(props, nbt.NewTag(k, nbt.String(state.Properties[k])))
+		}
+
+		c = append(c, nbt.NewTag("Properties", props))
+	}
+
+	return c
+}
+
+// blockStates holds the paletted blocks of a section.
+//
+// The palette entries are kept as they were read, so that any properties or
+// tags not understood by the BlockMapper are written back unchanged.
+type blockStates struct {
+	mapper  BlockMapper
+	palette []nbt.Compound
+	keys    []string
+	mapped  []Block
+	indices []uint32
+	changed bool
+}
+
+func newBlockStates(m BlockMapper) *blockStates {
+	return &blockStates{
+		mapper:  m,
+		palette: []nbt.Compound{{nbt.NewTag("Name", nbt.String(airState))}},
+		keys:    []string{airState},
+		indices: make([]uint32, 4096),
+		changed: true,
+	}
+}
+
+func loadBlockStates(c nbt.Compound, paletteName, dataName string, format blockFormat, m BlockMapper) (*blockStates, error) {
+	pTag := c.Get(paletteName)
+	if pTag.TagID() == 0 {
+		return nil, MissingTagError{"[SECTION]->" + paletteName}
+	} else if pTag.TagID() != nbt.TagList {
+		return nil, WrongTypeError{paletteName, nbt.TagList, pTag.TagID()}
+	}
+
+	list := pTag.Data().(nbt.List)
+	if list.Len() == 0 {
+		return nil, ErrOOB
+	} else if list.TagType() != nbt.TagCompound {
+		return nil, WrongTypeError{paletteName + "->Child", nbt.TagCompound, list.TagType()}
+	}
+
+	b := &blockStates{
+		mapper:  m,
+		palette: make([]nbt.Compound, list.Len()),
+		keys:    make([]string, list.Len()),
+	}
+
+	for n := range b.palette {
+		b.palette[n] = list.Get(n).(nbt.Compound)
+
+		if name := b.palette[n].Get("Name"); name.TagID() == 0 {
+			return nil, MissingTagError{paletteName + "->Child->Name"}
+		} else if name.TagID() != nbt.TagString {
+			return nil, WrongTypeError{paletteName + "->Child->Name", nbt.TagString, name.TagID()}
+		}
+
+		b.keys[n] = stateFromCompound(b.palette[n]).String()
+	}
+
+	bits := PackedBits(len(b.palette), 4)
+	if format == containerBlocks && len(b.palette) == 1 {
+		bits = 0
+	}
+
+	var data nbt.LongArray
+
+	if bits > 0 {
+		dTag := c.Get(dataName)
+		if dTag.TagID() == 0 {
+			return nil, MissingTagError{"[SECTION]->" + dataName}
+		} else if dTag.TagID() != nbt.TagLongArray {
+			return nil, WrongTypeError{dataName, nbt.TagLongArray, dTag.TagID()}
+		}
+
+		data = dTag.Data().(nbt.LongArray)
+		bits = packedBitsFromLength(data, bits, format != packedBlocks)
+	}
+
+	var err error
+
+	if b.indices, err = UnpackBits(data, bits, 4096, format != packedBlocks); err != nil {
+		return nil, err
+	}
+
+	for _, i := range b.indices {
+		if int(i) >= len(b.palette) {
+			return nil, ErrOOB
+		}
+	}
+
+	return b, nil
+}
+
+// packedBitsFromLength returns the bits per value implied by the length of
+// the data, which may be larger than the minimum needed for the palette, or
+// the given bits if no width matches.
+func packedBitsFromLength(data []int64, bits uint8, padded bool) uint8 {
+	for b := bits; b <= 32; b++ {
+		if PackedLength(4096, b, padded) == len(data) {
+			return b
+		}
+	}
+
+	return bits
+}
+
+// mapPalette converts the palette entries to Blocks.
+func (b *blockStates) mapPalette() {
+	if b.mapped != nil {
+		return
+	}
+
+	b.mapped = make([]Block, len(b.palette))
+
+	for n, c := range b.palette {
+		b.mapped[n] = b.mapEntry(b.keys[n], c)
+	}
+}
+
+// mapEntry returns the Block for a palette entry, which is unmapped if the
+// BlockMapper has no mapping for its block state.
+func (b *blockStates) mapEntry(key string, c nbt.Compound) Block {
+	if block, ok := b.mapper.BlockFromState(stateFromCompound(c)); ok {
+		return Block{ID: block.ID, Data: block.Data}
+	}
+
+	return Block{state: &unmappedState{key: key, entry: c}}
+}
+
+// get returns the Block at the given index.
+func (b *blockStates) get(i uint32) Block {
+	b.mapPalette()
+
+	return b.mapped[b.indices[i]]
+}
+
+// set sets the block at the given index. Unmapped blocks are stored using
+// their palette entry, unchanged; other blocks with no mapping to a block
+// state are ignored.
+func (b *blockStates) set(i uint32, block Block) {
+	var (
+		key   string
+		entry nbt.Compound
+	)
+
+	if block.state != nil {
+		key, entry = block.state.key, block.state.entry
+	} else if state, ok := b.mapper.StateFromBlock(block); ok {
+		key, entry = state.String(), stateToCompound(state)
+	} else {
+		return
+	}
+
+	if b.keys[b.indices[i]] == key {
+		return
+	}
+
+	p := -1
+
+	for n, k := range b.keys {
+		if k == key {
+			p = n
+
+			break
+		}
+	}
+
+	if p < 0 {
+		if block.state != nil {
+			entry = entry.Copy().(nbt.Compound)
+		}
+
+		p = len(b.palette)
+		b.palette = append(b.palette, entry)
+		b.keys = append(b.keys, key)
+
+		if b.mapped != nil {
+			b.mapped = append(b.mapped, b.mapEntry(key, entry))
+		}
+	}
+
+	b.indices[i] = uint32(p)
+	b.changed = true
+}
+
+// encode removes unused entries from the palette and returns the palette and
+// packed data in the given format. For the container format the data is nil
+// when the palette has a single entry.
+func (b *blockStates) encode(format blockFormat) (nbt.List, nbt.LongArray) {
+	b.compact()
+
+	palette := make(nbt.ListCompound, len(b.palette))
+
+	copy(palette, b.palette)
+
+	if format == containerBlocks && len(b.palette) == 1 {
+		return &palette, nil
+	}
+
+	return &palette, PackBits(b.indices, PackedBits(len(b.palette), 4), format != packedBlocks)
+}
+
+func (b *blockStates) compact() {
+	remap := compactIndices(b.indices, len(b.palette))
+
+	var (
+		palette = b.palette[:0]
+		keys    = b.keys[:0]
+		mapped  []Block
+	)
+
+	for n, r := range remap {
+		if r < 0 {
+			continue
+		}
+
+		palette = append(palette, b.palette[n])
+		keys = append(keys, b.keys[n])
+
+		if b.mapped != nil {
+			mapped = append(mapped, b.mapped[n])
+		}
+	}
+
+	b.palette, b.keys, b.mapped = palette, keys, mapped
+}
+
+// compactIndices rewrites the indices so that unused palette entries are
+// removed, returning the new index of each old entry, or -1 if unused.
+func compactIndices(indices []uint32, size int) []int {
+	remap := make([]int, size)
+
+	for n := range remap {
+		remap[n] = -1
+	}
+
+	for _, i := range indices {
+		remap[i] = 0
+	}
+
+	next := 0
+
+	for n, r := range remap {
+		if r == 0 {
+			remap[n] = next
+			next++
+		}
+	}
+
+	for n, i := range indices {
+		indices[n] = uint32(remap[i])
+	}
+
+	return remap
+}
+
+// biomeStates holds the paletted biomes of a section, stored in 4x4x4 cells.
+type biomeStates struct {
+	palette []string
+	indices []uint32
+	changed bool
+}
+
+func newBiomeStates() *biomeStates {
+	return &biomeStates{
+		palette: []string{biomeToName[Plains]},
+		indices: make([]uint32, 64),
+		changed: true,
+	}
+}
+
+func loadBiomeStates(c nbt.Compound) (*biomeStates, error) {
+	pTag := c.Get("palette")
+	if pTag.TagID() == 0 {
+		return nil, MissingTagError{"biomes->palette"}
+	} else if pTag.TagID() != nbt.TagList {
+		return nil, WrongTypeError{"biomes->palette", nbt.TagList, pTag.TagID()}
+	}
+
+	list := pTag.Data().(nbt.List)
+	if list.Len() == 0 {
+		return nil, ErrOOB
+	} else if list.TagType() != nbt.TagString {
+		return nil, WrongTypeError{"biomes->palette->Child", nbt.TagString, list.TagType()}
+	}
+
+	b := &biomeStates{palette: make([]string, list.Len())}
+
+	for n := range b.palette {
+		b.palette[n] = string(list.Get(n).(nbt.String))
+	}
+
+	var (
+		data nbt.LongArray
+		bits uint8
+	)
+
+	if len(b.palette) > 1 {
+		bits = PackedBits(len(b.palette), 1)
+
+		dTag := c.Get("data")
+		if dTag.TagID() == 0 {
+			return nil, MissingTagError{"biomes->data"}
+		} else if dTag.TagID() != nbt.TagLongArray {
+			return nil, WrongTypeError{"biomes->data", nbt.TagLongArray, dTag.TagID()}
+		}
+
+		data = dTag.Data().(nbt.LongArray)
+	}
+
+	var err error
+
+	if b.indices, err = UnpackBits(data, bits, 64, true); err != nil {
+		return nil, err
+	}
+
+	for _, i := range b.indices {
+		if int(i) >= len(b.palette) {
+			return nil, ErrOOB
+		}
+	}
+
+	return b, nil
+}
+
+func (b *biomeStates) get(i int) Biome {
+	if biome, ok := biomeByName[b.palette[b.indices[i]]]; ok {
+		return biome
+	}
+
+	return AutoBiome
+}
+
+func (b *biomeStates) set(i int, biome Biome) {
+	name, ok := biomeToName[biome]
+	if !ok || b.palette[b.indices[i]] == name {
+		return
+	}
+
+	p := -1
+
+	for n, bn := range b.palette {
+		if bn == name {
+			p = n
+
+			break
+		}
+	}
+
+	if p < 0 {
+		p = len(b.palette)
+		b.palette = append(b.palette, name)
+	}
+
+	b.indices[i] = uint32(p)
+	b.changed = true
+}
+
+func (b *biomeStates) encode() (nbt.List, nbt.LongArray) {
+	remap := compactIndices(b.indices, len(b.palette))
+	palette := b.palette[:0]
+
+	for n, r := range remap {
+		if r >= 0 {
+			palette = append(palette, b.palette[n])
+		}
+	}
+
+	b.palette = palette
+
+	list := nbt.NewEmptyList(nbt.TagString)
+
+	for _, name := range b.palette {
+		list.Append(nbt.String(name))
+	}
+
+	if len(b.palette) == 1 {
+		return list, nil
+	}
+
+	return list, PackBits(b.indices, PackedBits(len(b.palette), 1), true)
+}
+
+// newPalettedSection creates a new, empty, section in the given paletted
+// format.
+func newPalettedSection(y int32, format blockFormat, m BlockMapper) *section {
+	s := &section{
+		format:     format,
+		states:     newBlockStates(m),
+		blockLight: make(nbt.ByteArray, 2048),
+		skyLight:   make(nbt.ByteArray, 2048),
+	}
+
+	for i := range s.skyLight {
+		s.skyLight[i] = -1
+	}
+
+	s.section = nbt.Compound{
+		nbt.NewTag("Y", nbt.Byte(y>>4)),
+		nbt.NewTag("BlockLight", s.blockLight),
+		nbt.NewTag("SkyLight", s.skyLight),
+	}
+
+	if format == containerBlocks {
+		s.biomes = newBiomeStates()
+	}
+
+	return s
+}
+
+// loadPalettedSection loads a section stored in one of the paletted formats.
+// Sections with no block data, such as those that only hold light, are
+// treated as being full of air, and those with no biome data have no biomes.
+func loadPalettedSection(c nbt.Compound, format blockFormat, m BlockMapper) (*section, error) {
+	s := &section{section: c, format: format}
+
+	var err error
+
+	if format == containerBlocks {
+		if bs := c.Get("block_states"); bs.TagID() == 0 {
+			s.states = newBlockStates(m)
+			s.states.changed = false
+		} else if bs.TagID() != nbt.TagCompound {
+			return nil, WrongTypeError{"block_states", nbt.TagCompound, bs.TagID()}
+		} else if s.states, err = loadBlockStates(bs.Data().(nbt.Compound), "palette", "data", format, m); err != nil {
+			return nil, err
+		}
+
+		if bs := c.Get("biomes"); bs.TagID() == nbt.TagCompound {
+			if s.biomes, err = loadBiomeStates(bs.Data().(nbt.Compound)); err != nil {
+				return nil, err
+			}
+		} else if bs.TagID() != 0 {
+			return nil, WrongTypeError{"biomes", nbt.TagCompound, bs.TagID()}
+		}
+	} else if c.Get("Palette").TagID() == 0 {
+		s.states = newBlockStates(m)
+		s.states.changed = false
+	} else if s.states, err = loadBlockStates(c, "Palette", "BlockStates", format, m); err != nil {
+		return nil, err
+	}
+
+	for _, light := range [...]struct {
+		name string
+		data *nbt.ByteArray
+	}{
+		{"BlockLight", &s.blockLight},
+		{"SkyLight", &s.skyLight},
+	} {
+		if tag := c.Get(light.name); tag.TagID() == 0 {
+			continue
+		} else if tag.TagID() != nbt.TagByteArray {
+			return nil, WrongTypeError{light.name, nbt.TagByteArray, tag.TagID()}
+		} else if *light.data = tag.Data().(nbt.ByteArray); len(*light.data) != 2048 {
+			return nil, ErrOOB
+		}
+	}
+
+	if y := c.Get("Y"); y.TagID() == 0 {
+		return nil, MissingTagError{"[SECTION]->Y"}
+	} else if y.TagID() != nbt.TagByte {
+		return nil, WrongTypeError{"Y", nbt.TagByte, y.TagID()}
+	}
+
+	return s, nil
+}
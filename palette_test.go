@@ -0,0 +1,271 @@
+package minecraft
+
+import (
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func palettedChunk(x, z, dataVersion int32, format blockFormat) nbt.Tag {
+	palette := nbt.ListCompound{
+		{nbt.NewTag("Name", nbt.String("minecraft:air"))},
+		{
+			nbt.NewTag("Name", nbt.String("minecraft:stone")),
+			nbt.NewTag("Properties", nbt.Compound{nbt.NewTag("mystery", nbt.String("yes"))}),
+		},
+		{nbt.NewTag("Name", nbt.String("minecraft:unknown_block"))},
+	}
+
+	for _, colour := range [...]string{"white", "orange", "magenta", "light_blue", "yellow", "lime", "pink", "gray", "light_gray", "cyan", "purple", "blue", "brown", "green", "red", "black"} {
+		palette = append(palette, nbt.Compound{nbt.NewTag("Name", nbt.String("minecraft:"+colour+"_wool"))})
+	}
+
+	indices := make([]uint32, 4096)
+
+	for i := range palette {
+		indices[i] = uint32(i)
+	}
+
+	states := nbt.LongArray(PackBits(indices, 5, format != packedBlocks))
+	section := nbt.Compound{
+		nbt.NewTag("Y", nbt.Byte(0)),
+		nbt.NewTag("SkyLight", make(nbt.ByteArray, 2048)),
+	}
+	light := nbt.Compound{
+		nbt.NewTag("Y", nbt.Byte(-1)),
+		nbt.NewTag("SkyLight", make(nbt.ByteArray, 2048)),
+	}
+
+	if format == containerBlocks {
+		section = append(section,
+			nbt.NewTag("block_states", nbt.Compound{
+				nbt.NewTag("palette", &palette),
+				nbt.NewTag("data", states),
+			}),
+			nbt.NewTag("biomes", nbt.Compound{
+				nbt.NewTag("palette", nbt.NewList([]nbt.Data{nbt.String("minecraft:desert")})),
+			}),
+		)
+
+		return nbt.NewTag("", nbt.Compound{
+			nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+			nbt.NewTag("xPos", nbt.Int(x)),
+			nbt.NewTag("zPos", nbt.Int(z)),
+			nbt.NewTag("Status", nbt.String("full")),
+			nbt.NewTag("sections", &nbt.ListCompound{light, section}),
+		})
+	}
+
+	biomes := make(nbt.IntArray, 1024)
+	if dataVersion < dataVersionBiomes3D {
+		biomes = biomes[:256]
+	}
+
+	for n := range biomes {
+		biomes[n] = int32(Desert)
+	}
+
+	section = append(section,
+		nbt.NewTag("Palette", &palette),
+		nbt.NewTag("BlockStates", states),
+	)
+
+	return nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+		nbt.NewTag("Level", nbt.Compound{
+			nbt.NewTag("xPos", nbt.Int(x)),
+			nbt.NewTag("zPos", nbt.Int(z)),
+			nbt.NewTag("Status", nbt.String("full")),
+			nbt.NewTag("Biomes", biomes),
+			nbt.NewTag("Heightmaps", nbt.Compound{}),
+			nbt.NewTag("Sections", &nbt.ListCompound{light, section}),
+		}),
+	})
+}
+
+func TestPalettedChunk(t *testing.T) {
+	for n, test := range [...]struct {
+		dataVersion int32
+		format      blockFormat
+		longs       int
+	}{
+		{1631, packedBlocks, 320},
+		{2230, packedBlocks, 320},
+		{2586, paddedBlocks, 342},
+		{2975, containerBlocks, 342},
+	} {
+		c, err := newChunk(1, -2, palettedChunk(1, -2, test.dataVersion, test.format))
+		if err != nil {
+			t.Fatalf("test %d: %s", n+1, err)
+		} else if c.format != test.format {
+			t.Fatalf("test %d: expecting format %d, got %d", n+1, test.format, c.format)
+		}
+
+		for i, b := range [...]Block{{}, {ID: 1}, UnmappedBlock(BlockState{Name: "minecraft:unknown_block"}), {ID: 35}} {
+			if got := c.GetBlock(int32(i), 0, 0); !got.EqualBlock(b) {
+				t.Errorf("test %d: expecting block %d to be %s, got %s", n+1, i, b, got)
+			}
+		}
+
+		if state, ok := c.GetBlock(2, 0, 0).Unmapped(); !ok || state.String() != "minecraft:unknown_block" {
+			t.Errorf("test %d: expecting unmapped block state, got %s (%v)", n+1, state, ok)
+		} else if h := c.GetHeight(2, 0); h != 1 {
+			t.Errorf("test %d: expecting unknown block to be opaque, got height %d", n+1, h)
+		} else if h := c.GetHeight(1, 0); h != 1 {
+			t.Errorf("test %d: expecting height 1, got %d", n+1, h)
+		} else if b := c.GetBiome(5, 5); b != Desert {
+			t.Errorf("test %d: expecting desert biome, got %s", n+1, b)
+		}
+
+		c.SetBlock(3, 0, 0, Block{ID: 35, Data: 14})
+		c.SetBlock(4, 17, 4, Block{ID: 1})
+		c.SetBiome(5, 5, Forest)
+
+		data := c.GetNBT()
+		root := data.Data().(nbt.Compound)
+		level := root
+
+		if test.format == containerBlocks {
+			if root.Get("Level").TagID() != 0 {
+				t.Errorf("test %d: expecting no Level tag", n+1)
+			}
+		} else if level = root.Get("Level").Data().(nbt.Compound); level.Get("Heightmaps").TagID() != 0 {
+			t.Errorf("test %d: expecting Heightmaps to be removed", n+1)
+		}
+
+		if dv := root.Get("DataVersion").Data().(nbt.Int); int32(dv) != test.dataVersion {
+			t.Errorf("test %d: expecting data version %d, got %d", n+1, test.dataVersion, dv)
+		}
+
+		if c, err = newChunk(1, -2, data); err != nil {
+			t.Fatalf("test %d: %s", n+1, err)
+		}
+
+		for _, b := range [...]struct {
+			x, y, z int32
+			block   Block
+		}{
+			{1, 0, 0, Block{ID: 1}},
+			{3, 0, 0, Block{ID: 35, Data: 14}},
+			{4, 0, 0, Block{ID: 35, Data: 1}},
+			{4, 17, 4, Block{ID: 1}},
+		} {
+			if got := c.GetBlock(b.x, b.y, b.z); !got.EqualBlock(b.block) {
+				t.Errorf("test %d: expecting block at %d,%d,%d to be %s, got %s", n+1, b.x, b.y, b.z, b.block, got)
+			}
+		}
+
+		if b := c.GetBiome(5, 5); b != Forest {
+			t.Errorf("test %d: expecting forest biome, got %s", n+1, b)
+		} else if b = c.GetBiome(10, 10); b != Desert {
+			t.Errorf("test %d: expecting desert biome, got %s", n+1, b)
+		}
+
+		if test.format == containerBlocks {
+			if s := c.getSection(-16); s == nil || s.section.Get("block_states").TagID() != 0 {
+				t.Errorf("test %d: expecting light only section to be loaded unchanged", n+1)
+			}
+		} else if len(c.extraSections) != 1 {
+			t.Errorf("test %d: expecting light only section to be preserved", n+1)
+		}
+
+		var mystery, unknown bool
+
+		for _, p := range c.getSection(0).states.palette {
+			switch stateFromCompound(p).String() {
+			case "minecraft:stone[mystery=yes]":
+				mystery = true
+			case "minecraft:unknown_block":
+				unknown = true
+			}
+		}
+
+		if !mystery || !unknown {
+			t.Errorf("test %d: expecting unknown states to be preserved", n+1)
+		}
+
+		c.SetBlock(2, 0, 0, Block{})
+
+		if state, ok := c.GetBlock(2, 0, 0).Unmapped(); ok {
+			t.Errorf("test %d: expecting unmapped block state to be overwritten with air, got %s", n+1, state)
+		} else if h := c.GetHeight(2, 0); h != c.minY {
+			t.Errorf("test %d: expecting empty column after overwriting unknown block, got height %d", n+1, h)
+		}
+
+		var states nbt.LongArray
+
+		if test.format == containerBlocks {
+			states = c.getSection(0).section.Get("block_states").Data().(nbt.Compound).Get("data").Data().(nbt.LongArray)
+
+			if bs := c.getSection(16).section.Get("block_states").Data().(nbt.Compound); bs.Get("data").TagID() == 0 {
+				t.Errorf("test %d: expecting data for section with air and stone", n+1)
+			}
+		} else {
+			states = c.getSection(0).section.Get("BlockStates").Data().(nbt.LongArray)
+		}
+
+		if len(states) != test.longs {
+			t.Errorf("test %d: expecting %d longs of block states, got %d", n+1, test.longs, len(states))
+		}
+	}
+}
+
+func TestPalettedLevel(t *testing.T) {
+	l, err := NewLevel(NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l.levelData.Set(nbt.NewTag("DataVersion", nbt.Int(2975)))
+
+	if err = l.SetBlock(0, 10, 0, Block{ID: 4000}); err == nil {
+		t.Error("expecting error setting unmapped block")
+	} else if _, ok := err.(UnknownBlock); !ok {
+		t.Errorf("expecting UnknownBlock error, got %s", err)
+	} else if err = l.SetBlock(0, 10, 0, Block{ID: 1}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = l.SetBiome(0, 0, 200); err == nil {
+		t.Error("expecting error setting unnamed biome")
+	} else if err = l.Save(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := l.path.GetChunk(0, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if x, z, err := chunkCoords(data); err != nil {
+		t.Fatal(err.Error())
+	} else if x != 0 || z != 0 {
+		t.Errorf("expecting chunk coords 0,0, got %d,%d", x, z)
+	}
+
+	sections := data.Data().(nbt.Compound).Get("sections").Data().(nbt.List)
+	if sections.Len() == 0 {
+		t.Fatal("expecting sections in 1.18 format")
+	}
+
+	palette := sections.Get(0).(nbt.Compound).Get("block_states").Data().(nbt.Compound).Get("palette").Data().(nbt.List)
+	if palette.Len() != 2 || palette.Get(1).(nbt.Compound).Get("Name").Data().(nbt.String) != "minecraft:stone" {
+		t.Errorf("expecting palette of air and stone, got %s", palette)
+	}
+
+	m := NewBlockStateTable()
+
+	m.Add(BlockState{Name: "minecraft:stone"}, Block{ID: 2})
+
+	l.SetBlockMapper(m)
+
+	if b, err := l.GetBlock(0, 10, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if b.ID != 2 {
+		t.Errorf("expecting new mapper to be used, got %s", b)
+	}
+
+	if b, err := l.GetBlock(0, 11, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if state, ok := b.Unmapped(); !ok || state.String() != "minecraft:air" {
+		t.Errorf("expecting unmapped air, got %s", b)
+	}
+}
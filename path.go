@@ -3,12 +3,16 @@ package minecraft
 import (
 	"compress/gzip"
 	"compress/zlib"
+	"encoding/binary"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"regexp"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"vimagination.zapto.org/byteio"
@@ -31,31 +35,122 @@ type Path interface {
 
 // Compression convenience constants.
 const (
-	GZip byte = 1
-	Zlib byte = 2
+	GZip         byte = 1
+	Zlib         byte = 2
+	Uncompressed byte = 3
+	LZ4          byte = 4
+	Custom       byte = 127
 )
 
+// externalChunk is set on the compression byte of a chunk to indicate that
+// the chunk data is stored in a separate c.X.Z.mcc file as it is too large to
+// fit within a region file.
+const externalChunk byte = 128
+
 // FilePath implements the Path interface and provides a standard minecraft
 // save format.
+//
+// A FilePath is safe for concurrent use by multiple goroutines. Reads from
+// regions run in parallel, while writes to a single region are serialised.
 type FilePath struct {
-	dirname   string
-	lock      int64
-	dimension string
+	root              *FilePath
+	regions           *regionCache
+	dirname           string
+	sessionMu         sync.RWMutex
+	lock              int64
+	lockFile          *os.File
+	legacyLock        bool
+	readOnly          bool
+	dimension         string
+	compression       byte
+	customCompression string
+}
+
+// FilePathOption is a function used to set an option on a FilePath during
+// construction.
+type FilePathOption func(*FilePath) error
+
+// WriteCompression sets the compression scheme used when writing chunks. The
+// default is Zlib.
+//
+// To use a custom compression scheme, use WriteCustomCompression.
+func WriteCompression(compression byte) FilePathOption {
+	return func(p *FilePath) error {
+		switch compression {
+		case GZip, Zlib, Uncompressed, LZ4:
+		default:
+			return UnknownCompression{compression}
+		}
+
+		p.compression = compression
+		p.customCompression = ""
+
+		return nil
+	}
+}
+
+// WriteCustomCompression sets the chunk writing compression to the named
+// custom compression scheme, which must have been registered with
+// RegisterCompression.
+func WriteCustomCompression(name string) FilePathOption {
+	return func(p *FilePath) error {
+		if c, ok := customCompression[name]; !ok || c.Writer == nil {
+			return UnknownCustomCompression{name}
+		}
+
+		p.compression = Custom
+		p.customCompression = name
+
+		return nil
+	}
 }
 
 // NewFilePath constructs a new directory based path to read from.
-func NewFilePath(dirname string) (*FilePath, error) {
+func NewFilePath(dirname string, options ...FilePathOption) (*FilePath, error) {
 	dirname = path.Clean(dirname)
+	p := &FilePath{dirname: dirname, compression: Zlib, regions: newRegionCache()}
+
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := os.MkdirAll(dirname, 0o755); err != nil {
 		return nil, err
 	}
 
-	p := &FilePath{dirname: dirname}
-
 	return p, p.Lock()
 }
 
+// OpenFilePathReadOnly opens an existing minecraft level for reading only.
+//
+// No lock is taken on the level and no files or directories are ever created
+// or modified, making it safe to use on a level that is in use by a running
+// server, though data may be read that is in the process of being written.
+//
+// All methods that would modify the level return ErrReadOnly.
+func OpenFilePathReadOnly(dirname string, options ...FilePathOption) (*FilePath, error) {
+	dirname = path.Clean(dirname)
+
+	fi, err := os.Stat(dirname)
+	if err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: dirname, Err: ErrNotDirectory}
+	}
+
+	p := &FilePath{dirname: dirname, compression: Zlib, readOnly: true, regions: newRegionCache()}
+
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
 type stickyEndianSeeker struct {
 	byteio.StickyBigEndianWriter
 	io.Seeker
@@ -79,8 +174,10 @@ func (s *stickyEndianSeeker) Seek(offset int64, whence int) (int64, error) {
 // Example. Dimension -1 == The Nether
 //
 //	Dimension  1 == The End
-func NewFilePathDimension(dirname string, dimension int) (*FilePath, error) {
-	fp, err := NewFilePath(dirname)
+//
+// For namespaced dimensions, see WithDimension.
+func NewFilePathDimension(dirname string, dimension int, options ...FilePathOption) (*FilePath, error) {
+	fp, err := NewFilePath(dirname, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -92,17 +189,67 @@ func NewFilePathDimension(dirname string, dimension int) (*FilePath, error) {
 	return fp, nil
 }
 
-func (p *FilePath) getRegionPath(x, z int32) string {
-	return path.Join(p.dirname, p.dimension, "region", "r."+strconv.FormatInt(int64(x>>5), 10)+"."+strconv.FormatInt(int64(z>>5), 10)+".mca")
+func (p *FilePath) getRegionDir(s Storage) string {
+	return path.Join(p.dirname, p.dimension, string(s))
+}
+
+func (p *FilePath) getRegionPath(s Storage, x, z int32) string {
+	return path.Join(p.getRegionDir(s), "r."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mca")
+}
+
+// openRegion opens a region file for reading, first replaying any outstanding
+// journal.
+func (p *FilePath) openRegion(s Storage, x, z int32) (*os.File, error) {
+	name := p.getRegionPath(s, x, z)
+
+	if !p.readOnly && hasJournal(name) {
+		if err := p.replayRegion(s, x, z); err != nil {
+			return nil, err
+		}
+	}
+
+	return os.Open(name)
+}
+
+func (p *FilePath) replayRegion(s Storage, x, z int32) error {
+	f, err := os.OpenFile(p.getRegionPath(s, x, z), os.O_RDWR, 0o666)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = replayJournal(f)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (p *FilePath) getExternalChunkPath(s Storage, x, z int32) string {
+	return path.Join(p.getRegionDir(s), "c."+strconv.FormatInt(int64(x), 10)+"."+strconv.FormatInt(int64(z), 10)+".mcc")
 }
 
 // GetChunk returns the chunk at chunk coords x, z.
 func (p *FilePath) GetChunk(x, z int32) (nbt.Tag, error) {
-	if !p.HasLock() {
+	return p.GetStorageChunk(StorageRegion, x, z)
+}
+
+// GetStorageChunk returns the chunk data at chunk coords x, z from the given
+// storage kind.
+func (p *FilePath) GetStorageChunk(s Storage, x, z int32) (nbt.Tag, error) {
+	if !p.canRead() {
 		return nbt.Tag{}, ErrNoLock
 	}
 
-	f, err := os.Open(p.getRegionPath(x>>5, z>>5))
+	l := p.regionLock(s, x>>5, z>>5)
+
+	l.RLock()
+	defer l.RUnlock()
+
+	r, err := p.getRegion(s, x>>5, z>>5, false)
 	if err != nil {
 		if os.IsNotExist(err) {
 			err = nil
@@ -111,70 +258,70 @@ func (p *FilePath) GetChunk(x, z int32) (nbt.Tag, error) {
 		return nbt.Tag{}, err
 	}
 
-	defer f.Close()
-
-	pos := int64((z&31)<<5 | (x & 31))
-
-	if _, err = f.Seek(4*pos, io.SeekStart); err != nil {
-		return nbt.Tag{}, err
-	}
-
-	be := byteio.BigEndianReader{Reader: f}
+	defer p.releaseRegion(r)
 
-	locationSize, _, err := be.ReadUint32()
-	if err != nil {
-		return nbt.Tag{}, err
-	} else if locationSize>>8 == 0 {
+	locationSize := r.locations[(z&31)<<5|(x&31)]
+	if locationSize>>8 == 0 {
 		return nbt.Tag{}, nil
-	} else if _, err = f.Seek(int64(locationSize>>8<<12), io.SeekStart); err != nil {
-		return nbt.Tag{}, err
 	}
 
-	reader := io.LimitReader(f, int64(locationSize&255<<12))
+	return decodeChunk(io.NewSectionReader(r.f, int64(locationSize>>8<<12), int64(locationSize&255<<12)), func() (io.ReadCloser, error) {
+		return os.Open(p.getExternalChunkPath(s, x, z))
+	})
+}
+
+// decodeChunk decodes the chunk stored at the start of the given reader, using
+// the external function to open the .mcc file for chunks that are not stored
+// in the region file.
+func decodeChunk(r io.Reader, external func() (io.ReadCloser, error)) (nbt.Tag, error) {
+	be := byteio.BigEndianReader{Reader: r}
 
 	length, _, err := be.ReadUint32()
 	if err != nil {
 		return nbt.Tag{}, err
 	}
 
-	reader = io.LimitReader(reader, int64(length))
-
 	compression, _, err := be.ReadUint8()
 	if err != nil {
 		return nbt.Tag{}, err
 	}
 
-	switch compression {
-	case GZip:
-		gReader, err := gzip.NewReader(reader)
+	reader := io.LimitReader(r, int64(length)-1)
+
+	if compression&externalChunk != 0 {
+		compression &^= externalChunk
+
+		e, err := external()
 		if err != nil {
 			return nbt.Tag{}, err
 		}
 
-		defer gReader.Close()
+		defer e.Close()
 
-		reader = gReader
-	case Zlib:
-		if reader, err = zlib.NewReader(reader); err != nil {
-			return nbt.Tag{}, err
-		}
-	default:
-		return nbt.Tag{}, UnknownCompression{compression}
+		reader = e
+	}
+
+	dReader, err := decompressChunk(compression, reader)
+	if err != nil {
+		return nbt.Tag{}, err
 	}
 
-	return nbt.Decode(reader)
+	defer dReader.Close()
+
+	return nbt.Decode(dReader)
 }
 
 type rc struct {
-	pos int32
-	buf memio.Buffer
+	pos, x, z   int32
+	compression byte
+	buf         memio.Buffer
 }
 
 // SetChunk saves multiple chunks at once, possibly returning a MultiError if
 // multiple errors were encountered.
 func (p *FilePath) SetChunk(data ...nbt.Tag) error {
-	if !p.HasLock() {
-		return ErrNoLock
+	if err := p.canWrite(); err != nil {
+		return err
 	}
 
 	regions := make(map[uint64][]rc)
@@ -203,12 +350,8 @@ func (p *FilePath) SetChunk(data ...nbt.Tag) error {
 
 		poses = append(poses, pos)
 		r := uint64(z>>5)<<32 | uint64(uint32(x>>5))
-		reg := rc{pos: (z&31)<<5 | (x & 31)}
-		zl := zlib.NewWriter(&reg.buf)
-		err = nbt.Encode(zl, d)
-
-		zl.Close()
 
+		reg, err := p.encodeChunk(x, z, d)
 		if err != nil {
 			errors = append(errors, FilePathSetError{x, z, err})
 
@@ -225,7 +368,7 @@ func (p *FilePath) SetChunk(data ...nbt.Tag) error {
 	for rID, chunks := range regions {
 		x, z := int32(rID&0xffffffff), int32(rID>>32)
 
-		if err := p.setChunks(x, z, chunks); err != nil {
+		if err := p.setChunks(StorageRegion, x, z, chunks); err != nil {
 			errors = append(errors, &FilePathSetError{x, z, err})
 		}
 	}
@@ -237,6 +380,41 @@ func (p *FilePath) SetChunk(data ...nbt.Tag) error {
 	return nil
 }
 
+// SetStorageChunk saves the chunk data at chunk coords x, z to the given
+// storage kind.
+//
+// Unlike SetChunk, the coords are not read from the data, as not all storage
+// kinds record them.
+func (p *FilePath) SetStorageChunk(s Storage, x, z int32, data nbt.Tag) error {
+	if err := p.canWrite(); err != nil {
+		return err
+	}
+
+	reg, err := p.encodeChunk(x, z, data)
+	if err != nil {
+		return err
+	}
+
+	return p.setChunks(s, x>>5, z>>5, []rc{reg})
+}
+
+func (p *FilePath) encodeChunk(x, z int32, data nbt.Tag) (rc, error) {
+	reg := rc{pos: (z&31)<<5 | (x & 31), x: x, z: z, compression: p.compression}
+
+	cw, err := compressChunk(p.compression, p.customCompression, &reg.buf)
+	if err != nil {
+		return reg, err
+	}
+
+	err = nbt.Encode(cw, data)
+
+	if cerr := cw.Close(); err == nil {
+		err = cerr
+	}
+
+	return reg, err
+}
+
 type sia []uint32
 
 func (s sia) Len() int {
@@ -251,52 +429,64 @@ func (s sia) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
-func (p *FilePath) setChunks(x, z int32, chunks []rc) error {
-	if err := os.MkdirAll(path.Join(p.dirname, p.dimension, "region"), 0o755); err != nil {
-		return err
-	}
+// sectors returns the number of 4KiB sectors required to store a chunk of the
+// given (compressed) length, including the length and compression header.
+func sectors(length int) uint32 {
+	return uint32(length+5+4095) >> 12
+}
 
-	f, err := os.OpenFile(p.getRegionPath(x, z), os.O_RDWR|os.O_CREATE, 0o666)
-	if err != nil {
+func (p *FilePath) setChunks(s Storage, x, z int32, chunks []rc) error {
+	l := p.regionLock(s, x, z)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if err := os.MkdirAll(p.getRegionDir(s), 0o755); err != nil {
 		return err
 	}
 
-	defer f.Close()
+	var internal []rc
 
-	var (
-		bytes     [4096]byte
-		positions [1024]uint32
-	)
+	for n := range chunks {
+		chunk := &chunks[n]
+
+		if sectors(len(chunk.buf)) > 255 {
+			if err := writeExternalChunk(p.getExternalChunkPath(s, chunk.x, chunk.z), chunk.buf); err != nil {
+				return err
+			}
 
-	pBytes := memio.Buffer(bytes[:])
+			chunk.compression |= externalChunk
+			chunk.buf = nil
+		} else {
+			internal = append(internal, *chunk)
+		}
+	}
 
-	if _, err = io.ReadFull(f, pBytes); err != nil && err != io.EOF {
+	r, err := p.getRegion(s, x, z, true)
+	if err != nil {
 		return err
 	}
 
-	posr := byteio.BigEndianReader{Reader: &pBytes}
+	defer p.releaseRegion(r)
 
-	for i := 0; i < 1024; i++ {
-		positions[i], _, _ = posr.ReadUint32()
-	}
+	positions := r.locations
 
-	var todoChunks []rc
+	var (
+		todoChunks []rc
+		j          journal
+	)
 
-	bew := stickyEndianSeeker{byteio.StickyBigEndianWriter{Writer: f}, f}
+	bew := stickyEndianSeeker{byteio.StickyBigEndianWriter{Writer: &j}, &j}
 
 	for _, chunk := range chunks {
-		newSize := uint32(len(chunk.buf)+5) >> 12
-
-		if uint32(len(chunk.buf))&4095 > 0 {
-			newSize++
-		}
+		newSize := sectors(len(chunk.buf))
 
 		if positions[chunk.pos]&255 == newSize {
 			bew.Seek(4*int64(chunk.pos)+4096, io.SeekStart) // Write the time, then the data
 			bew.WriteUint32(uint32(time.Now().Unix()))
 			bew.Seek(int64(positions[chunk.pos])>>8<<12, io.SeekStart)
 			bew.WriteUint32(uint32(len(chunk.buf)) + 1)
-			bew.WriteUint8(Zlib)
+			bew.WriteUint8(chunk.compression)
 			bew.Write(chunk.buf)
 		} else {
 			todoChunks = append(todoChunks, chunk)
@@ -319,14 +509,7 @@ func (p *FilePath) setChunks(x, z int32, chunks []rc) error {
 		lastPos := uint32(2)
 		smallest := uint32(0xffffffff)
 		writeLastByte := true
-		newSize := uint32(len(chunk.buf) + 5)
-
-		if newSize&4095 > 0 {
-			newSize >>= 12
-			newSize++
-		} else {
-			newSize >>= 12
-		}
+		newSize := sectors(len(chunk.buf))
 
 		// Find earliest, closest match in size for least fragmentation.
 		for i := 0; i < 1024; i++ {
@@ -352,7 +535,7 @@ func (p *FilePath) setChunks(x, z int32, chunks []rc) error {
 		bew.WriteUint32(uint32(time.Now().Unix()))
 		bew.Seek(int64(newPosition)<<12, io.SeekStart)
 		bew.WriteUint32(uint32(len(chunk.buf)) + 1)
-		bew.WriteUint8(Zlib)
+		bew.WriteUint8(chunk.compression)
 		bew.Write(chunk.buf)
 
 		if writeLastByte { // Make filesize mod 4096 (for minecraft compatibility)
@@ -361,13 +544,45 @@ func (p *FilePath) setChunks(x, z int32, chunks []rc) error {
 		}
 	}
 
-	return bew.Err
+	if bew.Err != nil {
+		return bew.Err
+	} else if err = j.commit(r.f); err == nil {
+		err = r.readLocations()
+	}
+
+	if err != nil {
+		p.uncacheRegion(r)
+
+		return err
+	}
+
+	for _, chunk := range internal {
+		if err := os.Remove(p.getExternalChunkPath(s, chunk.x, chunk.z)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeExternalChunk(name string, data []byte) error {
+	return writeFileAtomic(name, func(w io.Writer) error {
+		_, err := w.Write(data)
+
+		return err
+	})
 }
 
 // RemoveChunk deletes the chunk at chunk coords x, z.
 func (p *FilePath) RemoveChunk(x, z int32) error {
-	if !p.HasLock() {
-		return ErrNoLock
+	return p.RemoveStorageChunk(StorageRegion, x, z)
+}
+
+// RemoveStorageChunk deletes the chunk data at chunk coords x, z from the
+// given storage kind.
+func (p *FilePath) RemoveStorageChunk(s Storage, x, z int32) error {
+	if err := p.canWrite(); err != nil {
+		return err
 	}
 
 	chunkX := x & 31
@@ -375,34 +590,72 @@ func (p *FilePath) RemoveChunk(x, z int32) error {
 	chunkZ := z & 31
 	regionZ := z >> 5
 
-	f, err := os.OpenFile(p.getRegionPath(regionX, regionZ), os.O_WRONLY, 0o666)
+	l := p.regionLock(s, regionX, regionZ)
+
+	l.Lock()
+	defer l.Unlock()
+
+	r, err := p.getRegion(s, regionX, regionZ, false)
 	if os.IsNotExist(err) {
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	defer f.Close()
+	defer p.releaseRegion(r)
+
+	// A single aligned 4 byte write will not be torn.
+	if _, err = r.f.WriteAt([]byte{0, 0, 0, 0}, int64(chunkZ<<5|chunkX)*4); err == nil {
+		err = r.f.Sync()
+	}
+
+	if err != nil {
+		p.uncacheRegion(r)
 
-	if _, err = f.Seek(int64(chunkZ<<5|chunkX)*4, io.SeekStart); err != nil {
 		return err
 	}
 
-	_, err = f.Write([]byte{0, 0, 0, 0})
+	r.locations[chunkZ<<5|chunkX] = 0
+
+	if err = os.Remove(p.getExternalChunkPath(s, x, z)); os.IsNotExist(err) {
+		return nil
+	}
 
 	return err
 }
 
 // ReadLevelDat returns the level data.
+//
+// If level.dat is missing or cannot be read, the backup in level.dat_old will
+// be used instead, if it exists.
 func (p *FilePath) ReadLevelDat() (nbt.Tag, error) {
-	if !p.HasLock() {
+	if !p.canRead() {
 		return nbt.Tag{}, ErrNoLock
 	}
 
-	f, err := os.Open(path.Join(p.dirname, "level.dat"))
-	if os.IsNotExist(err) {
+	return loadLevelDat(os.DirFS(p.dirname))
+}
+
+// loadLevelDat reads the level.dat from the given filesystem, falling back to
+// level.dat_old.
+func loadLevelDat(fsys fs.FS) (nbt.Tag, error) {
+	levelDat, err := readLevelDat(fsys, "level.dat")
+	if err == nil || errors.Is(err, fs.ErrPermission) {
+		return levelDat, err
+	}
+
+	if old, oerr := readLevelDat(fsys, "level.dat_old"); oerr == nil {
+		return old, nil
+	} else if errors.Is(err, fs.ErrNotExist) && errors.Is(oerr, fs.ErrNotExist) {
 		return nbt.Tag{}, nil
-	} else if err != nil {
+	}
+
+	return nbt.Tag{}, err
+}
+
+func readLevelDat(fsys fs.FS, name string) (nbt.Tag, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
 		return nbt.Tag{}, err
 	}
 
@@ -417,34 +670,87 @@ func (p *FilePath) ReadLevelDat() (nbt.Tag, error) {
 }
 
 // WriteLevelDat Writes the level data.
+//
+// The data is written to level.dat_new, the existing level.dat is then moved
+// to level.dat_old and finally level.dat_new is moved to level.dat, ensuring
+// that a valid level.dat or level.dat_old always exists.
 func (p *FilePath) WriteLevelDat(data nbt.Tag) error {
-	if !p.HasLock() {
-		return ErrNoLock
+	if err := p.canWrite(); err != nil {
+		return err
 	}
 
-	f, err := os.OpenFile(path.Join(p.dirname, "level.dat"), os.O_WRONLY|os.O_CREATE, 0o666)
+	return writeLevelDat(p.dirname, data)
+}
+
+func writeLevelDat(dirname string, data nbt.Tag) error {
+	levelDat := path.Join(dirname, "level.dat")
+	levelDatNew := levelDat + "_new"
+
+	f, err := os.Create(levelDatNew)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	defer f.Close()
-
 	g := gzip.NewWriter(f)
 
-	defer g.Close()
+	if err = nbt.Encode(g, data); err == nil {
+		err = g.Close()
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(levelDatNew)
+
+		return err
+	}
+
+	if err = os.Rename(levelDat, levelDat+"_old"); err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err = os.Rename(levelDatNew, levelDat); err != nil {
+		return err
+	}
+
+	syncDir(dirname)
 
-	return nbt.Encode(g, data)
+	return nil
 }
 
 // GetRegions returns a list of region x,z coords of all generated regions.
 func (p *FilePath) GetRegions() [][2]int32 {
-	files, _ := os.ReadDir(path.Join(p.dirname, p.dimension, "region"))
+	return p.getRegions(StorageRegion)
+}
+
+// GetStorageRegions returns a list of region x,z coords of all regions of the
+// given storage kind.
+func (p *FilePath) GetStorageRegions(s Storage) [][2]int32 {
+	return p.getRegions(s)
+}
+
+func (p *FilePath) getRegions(s Storage) [][2]int32 {
+	files, _ := os.ReadDir(p.getRegionDir(s))
+
+	return regionFiles(files)
+}
+
+// regionFiles returns the region coords of all region files in the given
+// directory listing.
+func regionFiles(files []fs.DirEntry) [][2]int32 {
+	return matchRegionFiles(files, filename)
+}
 
+func matchRegionFiles(files []fs.DirEntry, re *regexp.Regexp) [][2]int32 {
 	var toRet [][2]int32
 
 	for _, file := range files {
 		if !file.IsDir() {
-			if nums := filename.FindStringSubmatch(file.Name()); nums != nil {
+			if nums := re.FindStringSubmatch(file.Name()); nums != nil {
 				x, _ := strconv.ParseInt(nums[1], 10, 32)
 				z, _ := strconv.ParseInt(nums[2], 10, 32)
 				toRet = append(toRet, [2]int32{int32(x), int32(z)})
@@ -457,31 +763,35 @@ func (p *FilePath) GetRegions() [][2]int32 {
 
 // GetChunks returns a list of all chunks within a region with coords x,z.
 func (p *FilePath) GetChunks(x, z int32) ([][2]int32, error) {
-	if !p.HasLock() {
-		return nil, ErrNoLock
-	}
+	return p.GetStorageChunks(StorageRegion, x, z)
+}
 
-	f, err := os.Open(p.getRegionPath(x, z))
-	if err != nil {
-		return nil, err
+// GetStorageChunks returns a list of all chunks of the given storage kind
+// within a region with coords x,z.
+func (p *FilePath) GetStorageChunks(s Storage, x, z int32) ([][2]int32, error) {
+	if !p.canRead() {
+		return nil, ErrNoLock
 	}
 
-	defer f.Close()
+	l := p.regionLock(s, x, z)
 
-	pBytes := make(memio.Buffer, 4096)
+	l.RLock()
+	defer l.RUnlock()
 
-	if _, err = io.ReadFull(f, pBytes); err != nil {
+	r, err := p.getRegion(s, x, z, false)
+	if err != nil {
 		return nil, err
 	}
 
+	defer p.releaseRegion(r)
+
 	baseX := x << 5
 	baseZ := z << 5
-	posr := byteio.BigEndianReader{Reader: &pBytes}
 
 	var toRet [][2]int32
 
-	for i := 0; i < 1024; i++ {
-		if n, _, _ := posr.ReadUint32(); n > 0 {
+	for i, n := range r.locations {
+		if n > 0 {
 			toRet = append(toRet, [2]int32{baseX + int32(i&31), baseZ + int32(i>>5)})
 		}
 	}
@@ -489,120 +799,78 @@ func (p *FilePath) GetChunks(x, z int32) ([][2]int32, error) {
 	return toRet, nil
 }
 
-// HasLock returns whether or not another program has taken the lock.
-func (p *FilePath) HasLock() bool {
-	r, err := os.Open(path.Join(p.dirname, "session.lock"))
-	if err != nil {
-		return false
-	}
-
-	defer r.Close()
-
-	buf := make(memio.Buffer, 9)
-
-	n, err := io.ReadFull(r, buf)
-	if n != 8 || err != io.ErrUnexpectedEOF {
-		return false
-	}
-
-	bew := byteio.BigEndianReader{Reader: &buf}
-	b, _, _ := bew.ReadInt64()
-
-	return b == p.lock
-}
-
-// Lock will retake the lock file if it has been lost. May cause corruption.
-func (p *FilePath) Lock() error {
-	if p.HasLock() {
-		return nil
-	}
-
-	p.lock = time.Now().UnixNano() / 1000000 // ms
-	session := path.Join(p.dirname, "session.lock")
-
-	f, err := os.Create(session)
-	if err != nil {
+// Defrag rewrites a region file to reduce wasted space.
+func (p *FilePath) Defrag(x, z int32) error {
+	if err := p.canWrite(); err != nil {
 		return err
 	}
 
-	bew := byteio.BigEndianWriter{Writer: f}
-	_, err = bew.WriteUint64(uint64(p.lock))
-
-	f.Close()
+	l := p.regionLock(StorageRegion, x, z)
 
-	return err
-}
+	l.Lock()
+	defer l.Unlock()
 
-// Defrag rewrites a region file to reduce wasted space.
-func (p *FilePath) Defrag(x, z int32) error {
-	if !p.HasLock() {
-		return ErrNoLock
-	}
+	p.closeRegion(StorageRegion, x, z)
 
-	f, err := os.OpenFile(p.getRegionPath(x, z), os.O_RDWR|os.O_CREATE, 0o666)
+	f, err := p.openRegion(StorageRegion, x, z)
 	if err != nil {
 		return err
 	}
 
 	defer f.Close()
 
-	locationSizes := make(memio.Buffer, 4096)
+	var header [8192]byte
 
-	if _, err = io.ReadFull(f, locationSizes); err != nil {
+	if _, err = io.ReadFull(f, header[:]); err != nil {
 		return err
 	}
 
 	var (
 		data       [1024][]byte
-		locations  [4096]byte
 		currSector uint32 = 2
 	)
 
-	locationr := byteio.BigEndianReader{Reader: &locationSizes}
-	l := memio.Buffer(locations[:0])
-	locationw := byteio.BigEndianWriter{Writer: &l}
-
 	for i := 0; i < 1024; i++ {
-		locationSize, _, _ := locationr.ReadUint32()
+		locationSize := binary.BigEndian.Uint32(header[i<<2:])
 		if locationSize>>8 == 0 {
 			continue
-		} else if _, err = f.Seek(int64(locationSize>>8<<12), io.SeekStart); err != nil {
-			return err
 		}
 
 		data[i] = make([]byte, locationSize&255<<12)
 
-		if _, err := io.ReadFull(f, data[i]); err != nil {
+		if _, err := f.ReadAt(data[i], int64(locationSize>>8<<12)); err != nil {
 			return err
 		}
 
-		locationw.WriteUint32(currSector<<8 | locationSize&255)
+		binary.BigEndian.PutUint32(header[i<<2:], currSector<<8|locationSize&255)
 
 		currSector += locationSize & 255
 	}
 
-	if _, err = f.Seek(0, io.SeekStart); err != nil {
-		return err
-	} else if _, err = f.Write(locations[:]); err != nil {
-		return err
-	} else if _, err = f.Seek(8192, io.SeekStart); err != nil {
-		return err // Try and recover first?
-	}
+	f.Close()
 
-	for _, d := range data {
-		if len(d) > 0 {
-			if _, err = f.Write(d); err != nil {
-				return err
+	return writeFileAtomic(f.Name(), func(w io.Writer) error {
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+
+		for _, d := range data {
+			if len(d) > 0 {
+				if _, err := w.Write(d); err != nil {
+					return err
+				}
 			}
 		}
-	}
-	return nil
+
+		return nil
+	})
 }
 
 // MemPath is an in memory minecraft level format that implements the Path interface.
 type MemPath struct {
-	level  memio.Buffer
-	chunks map[uint64]memio.Buffer
+	level   memio.Buffer
+	chunks  map[uint64]memio.Buffer
+	storage map[Storage]map[uint64]memio.Buffer
 }
 
 // NewMemPath creates a new MemPath implementation.
@@ -688,29 +956,34 @@ func chunkCoords(data nbt.Tag) (int32, int32, error) {
 		return 0, 0, WrongTypeError{"[Chunk Base]", nbt.TagCompound, data.TagID()}
 	}
 
-	lTag := data.Data().(nbt.Compound).Get("Level")
-	if lTag.TagID() == 0 {
-		return 0, 0, MissingTagError{"[Chunk Base]->Level"}
+	lCmp := data.Data().(nbt.Compound)
+	name := "[Chunk Base]"
+
+	if lTag := lCmp.Get("Level"); lTag.TagID() == 0 {
+		if lCmp.Get("xPos").TagID() == 0 { // chunks from 1.18 onwards have no Level tag
+			return 0, 0, MissingTagError{"[Chunk Base]->Level"}
+		}
 	} else if lTag.TagID() != nbt.TagCompound {
 		return 0, 0, WrongTypeError{"[Chunk Base]->Level", nbt.TagCompound, lTag.TagID()}
+	} else {
+		lCmp = lTag.Data().(nbt.Compound)
+		name = "[Chunk Base]->Level"
 	}
 
-	lCmp := lTag.Data().(nbt.Compound)
-
 	xPos := lCmp.Get("xPos")
 	if xPos.TagID() == 0 {
-		return 0, 0, MissingTagError{"[Chunk Base]->Level->xPos"}
+		return 0, 0, MissingTagError{name + "->xPos"}
 	} else if xPos.TagID() != nbt.TagInt {
-		return 0, 0, WrongTypeError{"[Chunk Base]->Level->xPos", nbt.TagInt, xPos.TagID()}
+		return 0, 0, WrongTypeError{name + "->xPos", nbt.TagInt, xPos.TagID()}
 	}
 
 	x := int32(xPos.Data().(nbt.Int))
 
 	zPos := lCmp.Get("zPos")
 	if zPos.TagID() == 0 {
-		return 0, 0, MissingTagError{"[Chunk Base]->Level->zPos"}
+		return 0, 0, MissingTagError{name + "->zPos"}
 	} else if zPos.TagID() != nbt.TagInt {
-		return 0, 0, WrongTypeError{"[Chunk Base]->Level->zPos", nbt.TagInt, zPos.TagID()}
+		return 0, 0, WrongTypeError{name + "->zPos", nbt.TagInt, zPos.TagID()}
 	}
 
 	z := int32(zPos.Data().(nbt.Int))
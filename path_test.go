@@ -1,6 +1,7 @@
 package minecraft
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"os"
@@ -246,17 +247,149 @@ func TestFilePath(t *testing.T) {
 	}
 }
 
+func TestFilePathDefragSparse(t *testing.T) {
+	f, err := NewFilePath(t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chunks := []nbt.Tag{addPos(3, 0, 1), addPos(10, 4, 0), addPos(31, 31, 2)}
+
+	if err = f.SetChunk(chunks...); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.RemoveChunk(3, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Defrag(0, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for n, chunk := range chunks[1:] {
+		x, z, _ := chunkCoords(chunk)
+
+		if c, err := f.GetChunk(x, z); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if !c.Equal(chunk) {
+			t.Errorf("test %d: returned chunk not equal to set chunk", n+1)
+		}
+	}
+}
+
+func TestFilePathLevelDatBackup(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	first := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{nbt.NewTag("Version", nbt.Int(1))})})
+	second := nbt.NewTag("", nbt.Compound{nbt.NewTag("Data", nbt.Compound{})})
+
+	if err = f.WriteLevelDat(first); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.WriteLevelDat(second); err != nil {
+		t.Fatal(err.Error())
+	} else if l, err := f.ReadLevelDat(); err != nil {
+		t.Fatal(err.Error())
+	} else if !l.Equal(second) {
+		t.Errorf("expecting level.dat to contain second write")
+	} else if _, err = os.Stat(path.Join(tempDir, "level.dat_new")); !os.IsNotExist(err) {
+		t.Errorf("expecting level.dat_new to not exist")
+	}
+
+	if err = os.WriteFile(path.Join(tempDir, "level.dat"), []byte("not a gzip file at all"), 0o666); err != nil {
+		t.Fatal(err.Error())
+	} else if l, err := f.ReadLevelDat(); err != nil {
+		t.Fatal(err.Error())
+	} else if !l.Equal(first) {
+		t.Errorf("expecting corrupt level.dat to fall back to level.dat_old")
+	}
+
+	if err = os.Remove(path.Join(tempDir, "level.dat")); err != nil {
+		t.Fatal(err.Error())
+	} else if l, err := f.ReadLevelDat(); err != nil {
+		t.Fatal(err.Error())
+	} else if !l.Equal(first) {
+		t.Errorf("expecting missing level.dat to fall back to level.dat_old")
+	}
+}
+
+func TestFilePathExternalChunk(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir, WriteCompression(Uncompressed))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data := make(nbt.ByteArray, 1<<20)
+
+	for i := range data {
+		data[i] = int8(i * 7)
+	}
+
+	level := addPos(40, 70, 0).Data().(nbt.Compound).Get("Level").Data().(nbt.Compound)
+	level.Set(nbt.NewTag("Large", data))
+
+	large := nbt.NewTag("", nbt.Compound{nbt.NewTag("Level", level)})
+
+	small := addPos(40, 70, 1)
+	external := path.Join(tempDir, "region", "c.40.70.mcc")
+
+	for n, test := range [...]struct {
+		chunk    nbt.Tag
+		external bool
+	}{
+		{large, true},
+		{small, false},
+		{large, true},
+	} {
+		if err = f.SetChunk(test.chunk); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if c, err := f.GetChunk(40, 70); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if !c.Equal(test.chunk) {
+			t.Fatalf("test %d: returned chunk not equal to set chunk", n+1)
+		} else if _, err = os.Stat(external); os.IsNotExist(err) == test.external {
+			t.Errorf("test %d: expecting external chunk file to exist: %v", n+1, test.external)
+		}
+	}
+
+	file, err := os.Open(path.Join(tempDir, "region", "r.1.2.mca"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var positions [1024]uint32
+
+	err = binary.Read(file, binary.BigEndian, positions[:])
+
+	file.Close()
+
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if pos := positions[6<<5|8]; pos&255 != 1 {
+		t.Errorf("expecting external chunk to use 1 sector, got %d", pos&255)
+	}
+
+	if err = f.RemoveChunk(40, 70); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = os.Stat(external); !os.IsNotExist(err) {
+		t.Errorf("expecting external chunk file to be removed")
+	}
+}
+
 func TestFilePathLock(t *testing.T) {
 	var (
 		err  error
 		f, g *FilePath
 	)
 	tempDir := t.TempDir()
-	if f, err = NewFilePath(tempDir); err != nil {
+	if f, err = NewFilePath(tempDir, LegacyLock()); err != nil {
 		t.Error(err.Error())
 	}
 	<-time.After(time.Millisecond * 2)
-	if g, err = NewFilePath(tempDir); err != nil {
+	if g, err = NewFilePath(tempDir, LegacyLock()); err != nil {
 		t.Error(err.Error())
 	}
 
@@ -294,6 +427,124 @@ func TestFilePathLock(t *testing.T) {
 	}
 }
 
+func TestFilePathModernLock(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if !f.HasLock() {
+		t.Fatal("expecting lock to be held")
+	}
+
+	if data, err := os.ReadFile(path.Join(tempDir, "session.lock")); err != nil {
+		t.Fatal(err.Error())
+	} else if string(data) != sessionLockDummy {
+		t.Errorf("expecting session.lock to contain %q, got %q", sessionLockDummy, data)
+	}
+
+	if _, err = NewFilePath(tempDir); err != ErrLocked {
+		t.Errorf("expecting ErrLocked, got %v", err)
+	}
+
+	if err = f.Unlock(); err != nil {
+		t.Fatal(err.Error())
+	} else if f.HasLock() {
+		t.Error("expecting lock to be released")
+	} else if _, err = f.GetChunk(0, 0); err != ErrNoLock {
+		t.Errorf("expecting ErrNoLock, got %v", err)
+	}
+
+	g, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Lock(); err != ErrLocked {
+		t.Errorf("expecting ErrLocked, got %v", err)
+	}
+
+	l, err := NewLevel(g)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = l.Close(); err != nil {
+		t.Fatal(err.Error())
+	} else if g.HasLock() {
+		t.Error("expecting Level.Close to release the lock")
+	} else if err = f.Lock(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	f.Unlock()
+}
+
+func TestFilePathReadOnly(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if _, err := OpenFilePathReadOnly(path.Join(tempDir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expecting not exist error, got %v", err)
+	} else if _, err = os.Stat(path.Join(tempDir, "missing")); !os.IsNotExist(err) {
+		t.Error("expecting directory to not be created")
+	}
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.SetChunk(addPos(0, 0, 0)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.WriteLevelDat(nbt.NewTag("", nbt.Compound{})); err != nil {
+		t.Fatal(err.Error())
+	} else if err = f.Unlock(); err != nil {
+		t.Fatal(err.Error())
+	} else if err = os.Remove(path.Join(tempDir, "session.lock")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	region, err := os.ReadFile(path.Join(tempDir, "region", "r.0.0.mca"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	r, err := OpenFilePathReadOnly(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if chunk, err := r.GetChunk(0, 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !chunk.Equal(addPos(0, 0, 0)) {
+		t.Error("chunk data does not match")
+	}
+
+	if _, err = r.ReadLevelDat(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if err = r.SetChunk(addPos(1, 0, 0)); err != ErrReadOnly {
+		t.Errorf("SetChunk: expecting ErrReadOnly, got %v", err)
+	}
+
+	if err = r.RemoveChunk(0, 0); err != ErrReadOnly {
+		t.Errorf("RemoveChunk: expecting ErrReadOnly, got %v", err)
+	}
+
+	if err = r.WriteLevelDat(nbt.NewTag("", nbt.Compound{})); err != ErrReadOnly {
+		t.Errorf("WriteLevelDat: expecting ErrReadOnly, got %v", err)
+	}
+
+	if err = r.Lock(); err != ErrReadOnly {
+		t.Errorf("Lock: expecting ErrReadOnly, got %v", err)
+	}
+
+	if _, err = os.Stat(path.Join(tempDir, "session.lock")); !os.IsNotExist(err) {
+		t.Error("expecting no session.lock to be created")
+	}
+
+	if data, err := os.ReadFile(path.Join(tempDir, "region", "r.0.0.mca")); err != nil {
+		t.Fatal(err.Error())
+	} else if !bytes.Equal(data, region) {
+		t.Error("expecting region file to be unchanged")
+	}
+}
+
 var chunksNBT [4]nbt.Tag
 
 func init() {
@@ -307,6 +558,9 @@ func init() {
 		*c,
 		*d,
 	}
+	for n := range chunks {
+		chunks[n].sections = make([]*section, len(chunks[n].sections))
+	}
 	for i := 0; i < 16; i++ {
 		for j := 0; j < 16; j++ {
 			for k := 0; k < 256; k++ {
@@ -322,10 +576,11 @@ func init() {
 							nbt.NewTag("testMD", nbt.Int(i*j*k)),
 						},
 						tick,
+						nil,
 					})
 				}
 				if k < 250 {
-					chunks[2].SetBlock(int32(i), int32(k), int32(j), Block{1, 0, nil, nil})
+					chunks[2].SetBlock(int32(i), int32(k), int32(j), Block{1, 0, nil, nil, nil})
 				} else {
 					chunks[2].SetBlock(int32(i), int32(k), int32(j), Block{
 						1,
@@ -334,6 +589,7 @@ func init() {
 							nbt.NewTag("testMD", nbt.Int(i*j*k)),
 						},
 						nil,
+						nil,
 					})
 				}
 			}
@@ -348,9 +604,10 @@ func init() {
 					nbt.NewTag("testMD5", nbt.Int(i+4)),
 				},
 				[]Tick{{int32(i*j+i+j) % 4096, 1, -1}},
+				nil,
 			})
 		}
-		chunks[0].SetBlock(int32(i), int32(i), int32(i), Block{uint16(i), uint8(i), nil, nil})
+		chunks[0].SetBlock(int32(i), int32(i), int32(i), Block{uint16(i), uint8(i), nil, nil, nil})
 	}
 	chunksNBT = [4]nbt.Tag{
 		a.GetNBT(),
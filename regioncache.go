@@ -0,0 +1,351 @@
+package minecraft
+
+import (
+	"container/list"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultRegionCacheSize is the number of region files a FilePath will keep
+// open by default.
+const DefaultRegionCacheSize = 16
+
+// regionFile is an open region file along with its parsed location table.
+//
+// The location table must only be accessed while holding the region lock.
+type regionFile struct {
+	name      string
+	f         *os.File
+	locations [1024]uint32
+	elem      *list.Element
+	refs      int
+
+	// ready is closed once the file has been opened, after which err holds
+	// any error from opening it.
+	ready chan struct{}
+	err   error
+
+	// info is the state of the file when its location table was read, used
+	// to detect changes made by other processes to read-only regions.
+	info os.FileInfo
+}
+
+// unchanged returns true if the given file info, from the path of the region
+// file, matches that of the file when its location table was read.
+func (r *regionFile) unchanged(info os.FileInfo) bool {
+	return info != nil && r.info != nil && os.SameFile(info, r.info) && info.Size() == r.info.Size() && info.ModTime().Equal(r.info.ModTime())
+}
+
+func (r *regionFile) readLocations() error {
+	var header [4096]byte
+
+	if _, err := r.f.ReadAt(header[:], 0); err != nil && err != io.EOF {
+		return err
+	}
+
+	for i := range r.locations {
+		r.locations[i] = binary.BigEndian.Uint32(header[i<<2:])
+	}
+
+	return nil
+}
+
+// open opens the region file, replaying any journal left by an interrupted
+// write, and reads its location table.
+func (r *regionFile) open(readOnly, create bool) error {
+	flags := os.O_RDWR
+
+	if readOnly {
+		flags = os.O_RDONLY
+	} else if create {
+		flags |= os.O_CREATE
+	}
+
+	f, err := os.OpenFile(r.name, flags, 0o666)
+	if err != nil {
+		return err
+	}
+
+	if readOnly {
+		r.info, err = f.Stat()
+	} else {
+		err = replayJournal(f)
+	}
+
+	r.f = f
+
+	if err == nil {
+		err = r.readLocations()
+	}
+
+	if err != nil {
+		f.Close()
+	}
+
+	return err
+}
+
+// regionCache is an LRU cache of open region files, along with the locks for
+// each region, shared between all of the FilePaths for a single level.
+type regionCache struct {
+	mu      sync.Mutex
+	limit   int
+	files   map[string]*regionFile
+	opening map[string]*regionFile
+	lru     list.List
+	locks   map[string]*regionMutex
+}
+
+func newRegionCache() *regionCache {
+	return &regionCache{
+		limit:   DefaultRegionCacheSize,
+		files:   make(map[string]*regionFile),
+		opening: make(map[string]*regionFile),
+		locks:   make(map[string]*regionMutex),
+	}
+}
+
+// regionMutex is the lock for a single region, which is removed from the
+// cache once it has been unlocked by every caller of regionLock.
+type regionMutex struct {
+	sync.RWMutex
+	c    *regionCache
+	name string
+	refs int
+}
+
+// Unlock unlocks the write lock and releases the region lock.
+func (l *regionMutex) Unlock() {
+	l.RWMutex.Unlock()
+	l.release()
+}
+
+// RUnlock unlocks the read lock and releases the region lock.
+func (l *regionMutex) RUnlock() {
+	l.RWMutex.RUnlock()
+	l.release()
+}
+
+func (l *regionMutex) release() {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+
+	if l.refs--; l.refs == 0 {
+		delete(l.c.locks, l.name)
+	}
+}
+
+// regionLock returns the lock for the given storage kind at region coords x,
+// z.
+//
+// Reading from a region requires the read lock, and modifying the region, or
+// its external chunk files, requires the write lock. The lock must be locked
+// and unlocked exactly once for each call.
+func (p *FilePath) regionLock(s Storage, x, z int32) *regionMutex {
+	name := p.getRegionPath(s, x, z)
+	c := p.regions
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.locks[name]
+	if !ok {
+		l = &regionMutex{c: c, name: name}
+		c.locks[name] = l
+	}
+
+	l.refs++
+
+	return l
+}
+
+// RegionCacheSize sets the maximum number of region files, along with their
+// location tables, that are kept open between calls. A size of zero disables
+// the cache, opening and closing the region file on every call.
+//
+// The default is DefaultRegionCacheSize.
+func RegionCacheSize(size int) FilePathOption {
+	return func(p *FilePath) error {
+		if size < 0 {
+			size = 0
+		}
+
+		p.regions.limit = size
+
+		return nil
+	}
+}
+
+// getRegion returns the open region file for the given storage kind at region
+// coords x, z, opening it if it is not already cached. If create is true, the
+// region file will be created if it does not exist.
+//
+// As the regions of a read-only FilePath may be modified by another process,
+// such as a running server, a cached read-only region is reopened whenever the
+// file has changed since its location table was read.
+//
+// Files are opened without holding the cache lock, so that regions can be
+// opened concurrently; readers of the same region wait for a single open.
+//
+// The region lock must be held by the caller, and the returned region file
+// must be released with releaseRegion.
+func (p *FilePath) getRegion(s Storage, x, z int32, create bool) (*regionFile, error) {
+	name := p.getRegionPath(s, x, z)
+	c := p.regions
+
+	var info os.FileInfo
+
+	if p.readOnly {
+		info, _ = os.Stat(name)
+	}
+
+	c.mu.Lock()
+
+	if r, ok := c.files[name]; ok {
+		if !p.readOnly || r.unchanged(info) {
+			c.lru.MoveToFront(r.elem)
+
+			r.refs++
+
+			c.mu.Unlock()
+
+			return r, nil
+		}
+
+		c.remove(r)
+	}
+
+	if r, ok := c.opening[name]; ok {
+		r.refs++
+
+		c.mu.Unlock()
+
+		<-r.ready
+
+		if r.err != nil {
+			c.mu.Lock()
+			r.refs--
+			c.mu.Unlock()
+
+			return nil, r.err
+		}
+
+		return r, nil
+	}
+
+	r := &regionFile{name: name, refs: 1, ready: make(chan struct{})}
+	c.opening[name] = r
+
+	c.mu.Unlock()
+
+	r.err = r.open(p.readOnly, create)
+
+	c.mu.Lock()
+
+	delete(c.opening, name)
+
+	if r.err != nil {
+		r.refs--
+	} else if c.limit > 0 {
+		r.elem = c.lru.PushFront(r)
+		c.files[name] = r
+
+		for c.lru.Len() > c.limit {
+			c.remove(c.lru.Back().Value.(*regionFile))
+		}
+	}
+
+	c.mu.Unlock()
+
+	close(r.ready)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return r, nil
+}
+
+// releaseRegion closes the region file if it is no longer in use and is not
+// being kept in the cache.
+func (p *FilePath) releaseRegion(r *regionFile) {
+	c := p.regions
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.refs--; r.refs == 0 && r.elem == nil {
+		r.f.Close()
+	}
+}
+
+// closeRegion closes and removes from the cache any open region file for the
+// given storage kind at region coords x, z. This must be called before the
+// region file is replaced or when its cached state can no longer be trusted.
+func (p *FilePath) closeRegion(s Storage, x, z int32) {
+	c := p.regions
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r, ok := c.files[p.getRegionPath(s, x, z)]; ok {
+		c.remove(r)
+	}
+}
+
+// uncacheRegion removes the region file from the cache, so that it will be
+// closed when released.
+func (p *FilePath) uncacheRegion(r *regionFile) {
+	c := p.regions
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.elem != nil {
+		c.remove(r)
+	}
+}
+
+// remove removes the region file from the cache, closing it if it is not in
+// use.
+func (c *regionCache) remove(r *regionFile) error {
+	c.lru.Remove(r.elem)
+	delete(c.files, r.name)
+
+	r.elem = nil
+
+	if r.refs > 0 {
+		return nil
+	}
+
+	return r.f.Close()
+}
+
+// Flush closes all region files held open by the cache.
+//
+// All writes are synced to disk as they are made, so this is only needed to
+// release the file handles and memory used by the cache. Region files that
+// are in use by other goroutines will be closed once they are finished with.
+func (p *FilePath) Flush() error {
+	c := p.regions
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+
+	for c.lru.Len() > 0 {
+		if cerr := c.remove(c.lru.Front().Value.(*regionFile)); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Close closes all open region files and releases the lock on the level.
+func (p *FilePath) Close() error {
+	return p.Unlock()
+}
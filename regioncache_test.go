@@ -0,0 +1,171 @@
+package minecraft
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFilePathRegionCache(t *testing.T) {
+	for _, size := range [...]int{0, 1, 2, DefaultRegionCacheSize} {
+		f, err := NewFilePath(t.TempDir(), RegionCacheSize(size))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		testPathChunkSetGet(t, f)
+
+		if open := f.regions.lru.Len(); open > size {
+			t.Errorf("size %d: expecting at most %d open regions, got %d", size, size, open)
+		} else if size == DefaultRegionCacheSize && open != len(f.GetRegions()) {
+			t.Errorf("size %d: expecting all %d regions to be open, got %d", size, len(f.GetRegions()), open)
+		}
+
+		if err = f.Defrag(0, 0); err != nil {
+			t.Fatal(err.Error())
+		} else if chunk, err := f.GetChunk(1, 0); err != nil {
+			t.Fatal(err.Error())
+		} else if !chunk.Equal(addPos(1, 0, 2)) {
+			t.Errorf("size %d: chunk data does not match after defrag", size)
+		}
+
+		if locks := len(f.regions.locks); locks != 0 {
+			t.Errorf("size %d: expecting no region locks to be kept, got %d", size, locks)
+		}
+
+		if err = f.Close(); err != nil {
+			t.Fatal(err.Error())
+		} else if open := f.regions.lru.Len(); open != 0 {
+			t.Errorf("size %d: expecting no open regions after close, got %d", size, open)
+		} else if f.HasLock() {
+			t.Errorf("size %d: expecting lock to be released", size)
+		}
+	}
+}
+
+func TestFilePathConcurrent(t *testing.T) {
+	f, err := NewFilePath(t.TempDir(), RegionCacheSize(1))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var wg sync.WaitGroup
+
+	for g := int32(0); g < 8; g++ {
+		wg.Add(1)
+
+		go func(g int32) {
+			defer wg.Done()
+
+			for i := int32(0); i < 8; i++ {
+				x, z := g*4+i%4, i/4*32
+
+				if err := f.SetChunk(addPos(x, z, uint8(i%3))); err != nil {
+					t.Error(err.Error())
+
+					return
+				} else if chunk, err := f.GetChunk(x, z); err != nil {
+					t.Error(err.Error())
+				} else if !chunk.Equal(addPos(x, z, uint8(i%3))) {
+					t.Errorf("chunk %d,%d: chunk data does not match", x, z)
+				}
+
+				if i == 4 {
+					if err := f.Defrag(0, 0); err != nil {
+						t.Error(err.Error())
+					}
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+
+	if locks := len(f.regions.locks); locks != 0 {
+		t.Errorf("expecting no region locks to be kept, got %d", locks)
+	}
+
+	reports, err := f.CheckDimension()
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(reports) != 0 {
+		t.Fatalf("expecting no problems, got %v", reports)
+	}
+
+	for g := int32(0); g < 8; g++ {
+		for i := int32(0); i < 8; i++ {
+			x, z := g*4+i%4, i/4*32
+
+			if chunk, err := f.GetChunk(x, z); err != nil {
+				t.Error(err.Error())
+			} else if !chunk.Equal(addPos(x, z, uint8(i%3))) {
+				t.Errorf("chunk %d,%d: chunk data does not match", x, z)
+			}
+		}
+	}
+}
+
+func TestFilePathReadOnlyRegionCache(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer w.Close()
+
+	if err = w.SetChunk(addPos(0, 0, 0), addPos(1, 0, 1)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	r, err := OpenFilePathReadOnly(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if chunk, err := r.GetChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(addPos(1, 0, 1)) {
+		t.Fatal("chunk data does not match")
+	}
+
+	if err = w.SetChunk(addPos(1, 0, 2)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = w.Defrag(0, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if chunk, err := r.GetChunk(1, 0); err != nil {
+		t.Fatal(err.Error())
+	} else if !chunk.Equal(addPos(1, 0, 2)) {
+		t.Error("expecting chunk data to be read from the modified region")
+	} else if open := r.regions.lru.Len(); open != 1 {
+		t.Errorf("expecting 1 open region, got %d", open)
+	}
+
+	if err = r.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if chunk, err := r.GetChunk(1, 0); err != nil {
+				t.Error(err.Error())
+			} else if !chunk.Equal(addPos(1, 0, 2)) {
+				t.Error("chunk data does not match")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if open := r.regions.lru.Len(); open != 1 {
+		t.Errorf("expecting 1 open region after concurrent reads, got %d", open)
+	}
+}
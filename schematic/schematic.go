@@ -0,0 +1,399 @@
+// Package schematic implements reading and writing of the schematic formats
+// used to share builds between levels.
+package schematic // import "vimagination.zapto.org/minecraft/schematic"
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// Schematic is a cuboid of blocks, as stored in the MCEdit .schematic format.
+//
+// Blocks are addressed by coords relative to the corner of the schematic.
+type Schematic struct {
+	width, height, length int32
+	blocks                []minecraft.Block
+
+	// Offset is the position of the schematic relative to the point it was
+	// copied from, as recorded by the editor that created it.
+	Offset [3]int32
+
+	// DataVersion is the data version of the level the schematic was
+	// created from. It is only stored in Sponge schematics.
+	DataVersion int32
+
+	// Entities contains the entities within the schematic, with positions
+	// relative to the corner of the schematic.
+	Entities []nbt.Compound
+}
+
+// New creates a new Schematic of the given size, filled with air.
+func New(width, height, length int32) *Schematic {
+	if width < 0 || height < 0 || length < 0 {
+		width, height, length = 0, 0, 0
+	}
+
+	return &Schematic{
+		width:  width,
+		height: height,
+		length: length,
+		blocks: make([]minecraft.Block, int(width)*int(height)*int(length)),
+	}
+}
+
+// Export copies the blocks within the given area of src to a new Schematic.
+func Export(src minecraft.BlockReader, area minecraft.Area) (*Schematic, error) {
+	s := New(area.Width, area.Height, area.Length)
+
+	if err := minecraft.CopyArea(s, 0, 0, 0, src, area); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Paste copies all of the blocks in the schematic, including air, to dst,
+// with the corner of the schematic placed at block coords x, y, z.
+func (s *Schematic) Paste(dst minecraft.BlockWriter, x, y, z int32) error {
+	return minecraft.CopyArea(dst, x, y, z, s, s.Area())
+}
+
+// Area returns the area covered by the schematic.
+func (s *Schematic) Area() minecraft.Area {
+	return minecraft.Area{Width: s.width, Height: s.height, Length: s.length}
+}
+
+func (s *Schematic) index(x, y, z int32) (int, bool) {
+	if x < 0 || y < 0 || z < 0 || x >= s.width || y >= s.height || z >= s.length {
+		return 0, false
+	}
+
+	return (int(y)*int(s.length)+int(z))*int(s.width) + int(x), true
+}
+
+func (s *Schematic) coords(i int) (x, y, z int32) {
+	return int32(i % int(s.width)), int32(i / int(s.width) / int(s.length)), int32(i / int(s.width) % int(s.length))
+}
+
+// GetBlock returns the block at the given coords within the schematic.
+func (s *Schematic) GetBlock(x, y, z int32) (minecraft.Block, error) {
+	i, ok := s.index(x, y, z)
+	if !ok {
+		return minecraft.Block{}, minecraft.ErrOOB
+	}
+
+	return s.blocks[i], nil
+}
+
+// SetBlock sets the block at the given coords within the schematic.
+func (s *Schematic) SetBlock(x, y, z int32, b minecraft.Block) error {
+	i, ok := s.index(x, y, z)
+	if !ok {
+		return minecraft.ErrOOB
+	}
+
+	s.blocks[i] = b
+
+	return nil
+}
+
+// Decode reads a gzipped .schematic file.
+func Decode(r io.Reader) (*Schematic, error) {
+	g, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := nbt.Decode(g)
+	if err != nil {
+		return nil, err
+	} else if data.TagID() != nbt.TagCompound {
+		return nil, minecraft.WrongTypeError{TagName: "[Schematic]", Expecting: nbt.TagCompound, Got: data.TagID()}
+	}
+
+	return decodeSchematic(data.Data().(nbt.Compound))
+}
+
+func getTag(c nbt.Compound, name string, tagType nbt.TagID) (nbt.Data, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, minecraft.MissingTagError{TagName: name}
+	} else if tag.TagID() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name, Expecting: tagType, Got: tag.TagID()}
+	}
+
+	return tag.Data(), nil
+}
+
+func getCompoundList(c nbt.Compound, name string) ([]nbt.Compound, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, nil
+	} else if tag.TagID() != nbt.TagList {
+		return nil, minecraft.WrongTypeError{TagName: name, Expecting: nbt.TagList, Got: tag.TagID()}
+	}
+
+	list := tag.Data().(nbt.List)
+	if list.Len() == 0 {
+		return nil, nil
+	}
+
+	compounds, ok := list.(*nbt.ListCompound)
+	if !ok {
+		return nil, minecraft.WrongTypeError{TagName: name + "->Child", Expecting: nbt.TagCompound, Got: list.TagType()}
+	}
+
+	return *compounds, nil
+}
+
+func getCoords(c nbt.Compound) (x, y, z int32, err error) {
+	for _, coord := range [...]struct {
+		name string
+		v    *int32
+	}{{"x", &x}, {"y", &y}, {"z", &z}} {
+		d, err := getTag(c, coord.name, nbt.TagInt)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		*coord.v = int32(d.(nbt.Int))
+	}
+
+	return x, y, z, nil
+}
+
+func decodeSchematic(c nbt.Compound) (*Schematic, error) {
+	var size [3]int32
+
+	for n, name := range [...]string{"Width", "Height", "Length"} {
+		d, err := getTag(c, name, nbt.TagShort)
+		if err != nil {
+			return nil, err
+		}
+
+		size[n] = int32(uint16(d.(nbt.Short)))
+	}
+
+	if materials := c.Get("Materials"); materials.TagID() != 0 {
+		if m, ok := materials.Data().(nbt.String); !ok || m != "Alpha" {
+			return nil, minecraft.UnexpectedValue{TagName: "Materials", Expecting: "Alpha", Got: materials.Data().String()}
+		}
+	}
+
+	s := New(size[0], size[1], size[2])
+
+	blocks, err := getTag(c, "Blocks", nbt.TagByteArray)
+	if err != nil {
+		return nil, err
+	} else if len(blocks.(nbt.ByteArray)) != len(s.blocks) {
+		return nil, minecraft.UnexpectedValue{TagName: "Blocks", Expecting: strconv.Itoa(len(s.blocks)) + " blocks", Got: strconv.Itoa(len(blocks.(nbt.ByteArray)))}
+	}
+
+	data, err := getTag(c, "Data", nbt.TagByteArray)
+	if err != nil {
+		return nil, err
+	} else if len(data.(nbt.ByteArray)) != len(s.blocks) {
+		return nil, minecraft.UnexpectedValue{TagName: "Data", Expecting: strconv.Itoa(len(s.blocks)) + " values", Got: strconv.Itoa(len(data.(nbt.ByteArray)))}
+	}
+
+	var add nbt.ByteArray
+
+	if c.Get("AddBlocks").TagID() != 0 {
+		a, err := getTag(c, "AddBlocks", nbt.TagByteArray)
+		if err != nil {
+			return nil, err
+		}
+
+		add = a.(nbt.ByteArray)
+	}
+
+	for i, b := range blocks.(nbt.ByteArray) {
+		id := uint16(byte(b))
+
+		// AddBlocks holds the high bits of each block ID, with the first
+		// block of each pair in the low nibble.
+		if i>>1 < len(add) {
+			if i&1 == 0 {
+				id |= uint16(byte(add[i>>1])&15) << 8
+			} else {
+				id |= uint16(byte(add[i>>1])>>4) << 8
+			}
+		}
+
+		s.blocks[i] = minecraft.Block{ID: id, Data: byte(data.(nbt.ByteArray)[i]) & 15}
+	}
+
+	tileEntities, err := getCompoundList(c, "TileEntities")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, te := range tileEntities {
+		x, y, z, err := getCoords(te)
+		if err != nil {
+			return nil, err
+		} else if i, ok := s.index(x, y, z); ok {
+			s.blocks[i].SetMetadata(te)
+		}
+	}
+
+	tileTicks, err := getCompoundList(c, "TileTicks")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tt := range tileTicks {
+		x, y, z, err := getCoords(tt)
+		if err != nil {
+			return nil, err
+		}
+
+		var tick [3]int32
+
+		for n, name := range [...]string{"i", "t", "p"} {
+			d, err := getTag(tt, name, nbt.TagInt)
+			if err != nil {
+				return nil, err
+			}
+
+			tick[n] = int32(d.(nbt.Int))
+		}
+
+		if i, ok := s.index(x, y, z); ok {
+			s.blocks[i].AddTicks(minecraft.Tick{I: tick[0], T: tick[1], P: tick[2]})
+		}
+	}
+
+	if s.Entities, err = getCompoundList(c, "Entities"); err != nil {
+		return nil, err
+	}
+
+	for n, name := range [...]string{"WEOffsetX", "WEOffsetY", "WEOffsetZ"} {
+		if offset, ok := c.Get(name).Data().(nbt.Int); ok {
+			s.Offset[n] = int32(offset)
+		}
+	}
+
+	return s, nil
+}
+
+// Encode writes the schematic, gzipped, in the .schematic format.
+//
+// Unmapped blocks cannot be stored in this format, and cause an
+// UnknownBlockState error.
+func (s *Schematic) Encode(w io.Writer) error {
+	data, err := s.encode()
+	if err != nil {
+		return err
+	}
+
+	g := gzip.NewWriter(w)
+
+	if err = nbt.Encode(g, nbt.NewTag("Schematic", data)); err != nil {
+		return err
+	}
+
+	return g.Close()
+}
+
+func (s *Schematic) encode() (nbt.Compound, error) {
+	if s.width > 65535 || s.height > 65535 || s.length > 65535 {
+		return nil, minecraft.ErrOOB
+	}
+
+	var (
+		blocks       = make(nbt.ByteArray, len(s.blocks))
+		data         = make(nbt.ByteArray, len(s.blocks))
+		add          = make(nbt.ByteArray, (len(s.blocks)+1)>>1)
+		hasAdd       bool
+		tileEntities = nbt.NewEmptyList(nbt.TagCompound)
+		tileTicks    = nbt.NewEmptyList(nbt.TagCompound)
+		entities     = nbt.NewEmptyList(nbt.TagCompound)
+	)
+
+	for i, b := range s.blocks {
+		if state, ok := b.Unmapped(); ok {
+			return nil, minecraft.UnknownBlockState{State: state.String()}
+		} else if b.ID > 4095 {
+			return nil, minecraft.ErrOOB
+		}
+
+		blocks[i] = int8(b.ID)
+		data[i] = int8(b.Data & 15)
+
+		if high := byte(b.ID >> 8); high != 0 {
+			hasAdd = true
+
+			if i&1 == 0 {
+				add[i>>1] |= int8(high)
+			} else {
+				add[i>>1] |= int8(high << 4)
+			}
+		}
+
+		if !b.HasMetadata() && !b.HasTicks() {
+			continue
+		}
+
+		x, y, z := s.coords(i)
+
+		if b.HasMetadata() {
+			te := b.GetMetadata()
+
+			te.Set(nbt.NewTag("x", nbt.Int(x)))
+			te.Set(nbt.NewTag("y", nbt.Int(y)))
+			te.Set(nbt.NewTag("z", nbt.Int(z)))
+
+			if err := tileEntities.Append(te); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, tick := range b.GetTicks() {
+			if err := tileTicks.Append(nbt.Compound{
+				nbt.NewTag("i", nbt.Int(tick.I)),
+				nbt.NewTag("p", nbt.Int(tick.P)),
+				nbt.NewTag("t", nbt.Int(tick.T)),
+				nbt.NewTag("x", nbt.Int(x)),
+				nbt.NewTag("y", nbt.Int(y)),
+				nbt.NewTag("z", nbt.Int(z)),
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, e := range s.Entities {
+		if err := entities.Append(e); err != nil {
+			return nil, err
+		}
+	}
+
+	c := nbt.Compound{
+		nbt.NewTag("Width", nbt.Short(uint16(s.width))),
+		nbt.NewTag("Height", nbt.Short(uint16(s.height))),
+		nbt.NewTag("Length", nbt.Short(uint16(s.length))),
+		nbt.NewTag("Materials", nbt.String("Alpha")),
+		nbt.NewTag("Blocks", blocks),
+		nbt.NewTag("Data", data),
+		nbt.NewTag("TileEntities", tileEntities),
+		nbt.NewTag("Entities", entities),
+		nbt.NewTag("WEOffsetX", nbt.Int(s.Offset[0])),
+		nbt.NewTag("WEOffsetY", nbt.Int(s.Offset[1])),
+		nbt.NewTag("WEOffsetZ", nbt.Int(s.Offset[2])),
+	}
+
+	if hasAdd {
+		c.Set(nbt.NewTag("AddBlocks", add))
+	}
+
+	if tileTicks.Len() > 0 {
+		c.Set(nbt.NewTag("TileTicks", tileTicks))
+	}
+
+	return c, nil
+}
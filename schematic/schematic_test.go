@@ -0,0 +1,112 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestSchematic(t *testing.T) {
+	src, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chest := minecraft.Block{ID: 54, Data: 2}
+	chest.SetMetadata(nbt.Compound{
+		nbt.NewTag("id", nbt.String("Chest")),
+		nbt.NewTag("Items", nbt.NewEmptyList(nbt.TagCompound)),
+	})
+
+	ticking := minecraft.Block{ID: 8}
+	ticking.AddTicks(minecraft.Tick{I: 8, T: 5, P: 0})
+
+	blocks := []struct {
+		x, y, z int32
+		block   minecraft.Block
+	}{
+		{10, 64, -5, minecraft.Block{ID: 1}},
+		{12, 65, -4, minecraft.Block{ID: 35, Data: 14}},
+		{11, 66, -3, chest},
+		{13, 64, -2, minecraft.Block{ID: 300, Data: 1}},
+		{10, 66, -2, ticking},
+	}
+
+	for _, b := range blocks {
+		if err := src.SetBlock(b.x, b.y, b.z, b.block); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	s, err := Export(src, minecraft.Area{X: 10, Y: 64, Z: -5, Width: 4, Height: 3, Length: 4})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s.Entities = append(s.Entities, nbt.Compound{nbt.NewTag("id", nbt.String("Pig"))})
+
+	var buf bytes.Buffer
+
+	if err = s.Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	raw, err := nbt.Decode(g)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c := raw.Data().(nbt.Compound)
+
+	// Blocks are stored in YZX order.
+	if id := c.Get("Blocks").Data().(nbt.ByteArray)[(1*4+1)*4+2]; id != 35 {
+		t.Errorf("expecting wool at YZX index, got %d", id)
+	} else if add := c.Get("AddBlocks").Data().(nbt.ByteArray)[((0*4+3)*4+3)>>1]; add != 1<<4 {
+		t.Errorf("expecting high bits in AddBlocks, got %d", add)
+	}
+
+	if s, err = Decode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(s.Entities) != 1 {
+		t.Errorf("expecting 1 entity, got %d", len(s.Entities))
+	}
+
+	dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = dst.SetBlock(-100, 11, 200, minecraft.Block{ID: 3}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = s.Paste(dst, -100, 10, 200); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, b := range blocks {
+		if got, err := dst.GetBlock(b.x-110, b.y-54, b.z+205); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if !got.EqualBlock(b.block) {
+			t.Errorf("%d,%d,%d: expecting block %s, got %s", b.x, b.y, b.z, b.block, got)
+		}
+	}
+
+	if got, err := dst.GetBlock(-100, 11, 200); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !got.EqualBlock(minecraft.Block{}) {
+		t.Errorf("expecting pasted air, got %s", got)
+	}
+
+	if err = s.SetBlock(4, 0, 0, minecraft.Block{}); err != minecraft.ErrOOB {
+		t.Errorf("expecting ErrOOB, got %v", err)
+	}
+}
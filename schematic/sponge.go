@@ -0,0 +1,373 @@
+package schematic
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// DecodeSponge reads a gzipped Sponge .schem file, of version 2 or 3, using
+// the given mapper to convert its block states to Blocks. Block states the
+// mapper has no mapping for are decoded as unmapped blocks.
+func DecodeSponge(r io.Reader, m minecraft.BlockMapper) (*Schematic, error) {
+	g, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := nbt.Decode(g)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := data.Data().(nbt.Compound)
+	if !ok {
+		return nil, minecraft.WrongTypeError{TagName: "[Schematic]", Expecting: nbt.TagCompound, Got: data.TagID()}
+	}
+
+	// Version 3 schematics wrap the schematic in an unnamed root compound.
+	if sch, ok := c.Get("Schematic").Data().(nbt.Compound); ok {
+		c = sch
+	}
+
+	version, err := getTag(c, "Version", nbt.TagInt)
+	if err != nil {
+		return nil, err
+	}
+
+	var size [3]int32
+
+	for n, name := range [...]string{"Width", "Height", "Length"} {
+		d, err := getTag(c, name, nbt.TagShort)
+		if err != nil {
+			return nil, err
+		}
+
+		size[n] = int32(uint16(d.(nbt.Short)))
+	}
+
+	s := New(size[0], size[1], size[2])
+
+	dataVersion, err := getTag(c, "DataVersion", nbt.TagInt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.DataVersion = int32(dataVersion.(nbt.Int))
+
+	if offset, ok := c.Get("Offset").Data().(nbt.IntArray); ok && len(offset) == 3 {
+		copy(s.Offset[:], offset)
+	}
+
+	var (
+		blocks    = c
+		blockData = "BlockData"
+		nested    bool
+	)
+
+	switch version.(nbt.Int) {
+	case 2:
+	case 3:
+		nested = true
+		blockData = "Data"
+
+		if blocks, ok = c.Get("Blocks").Data().(nbt.Compound); !ok {
+			if s.Entities, err = decodeSpongeEntities(c, true); err != nil {
+				return nil, err
+			}
+
+			return s, nil
+		}
+	default:
+		return nil, minecraft.UnexpectedValue{TagName: "Version", Expecting: "2 or 3", Got: strconv.FormatInt(int64(version.(nbt.Int)), 10)}
+	}
+
+	palette, err := decodePalette(blocks, m)
+	if err != nil {
+		return nil, err
+	} else if err = s.decodeBlockData(blocks, blockData, palette); err != nil {
+		return nil, err
+	}
+
+	blockEntities, err := getCompoundList(blocks, "BlockEntities")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, be := range blockEntities {
+		pos, metadata, err := splitSpongeObject(be, nested)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pos) != 3 {
+			return nil, minecraft.UnexpectedValue{TagName: "BlockEntities->Child->Pos", Expecting: "3 coords", Got: strconv.Itoa(len(pos))}
+		} else if i, ok := s.index(pos[0], pos[1], pos[2]); ok {
+			s.blocks[i].SetMetadata(metadata)
+		}
+	}
+
+	if s.Entities, err = decodeSpongeEntities(c, nested); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func decodePalette(c nbt.Compound, m minecraft.BlockMapper) ([]minecraft.Block, error) {
+	p, err := getTag(c, "Palette", nbt.TagCompound)
+	if err != nil {
+		return nil, err
+	}
+
+	palette := p.(nbt.Compound)
+	blocks := make([]minecraft.Block, len(palette))
+	set := make([]bool, len(palette))
+
+	for _, entry := range palette {
+		idx, ok := entry.Data().(nbt.Int)
+		if !ok {
+			return nil, minecraft.WrongTypeError{TagName: "Palette->" + entry.Name(), Expecting: nbt.TagInt, Got: entry.TagID()}
+		} else if idx < 0 || int(idx) >= len(blocks) || set[idx] {
+			return nil, minecraft.UnexpectedValue{TagName: "Palette->" + entry.Name(), Expecting: "unique index less than " + strconv.Itoa(len(blocks)), Got: strconv.FormatInt(int64(idx), 10)}
+		}
+
+		state, err := minecraft.ParseBlockState(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		blocks[idx] = minecraft.BlockOf(m, state)
+		set[idx] = true
+	}
+
+	return blocks, nil
+}
+
+func (s *Schematic) decodeBlockData(c nbt.Compound, name string, palette []minecraft.Block) error {
+	d, err := getTag(c, name, nbt.TagByteArray)
+	if err != nil {
+		return err
+	}
+
+	data := d.(nbt.ByteArray).Bytes()
+
+	for i := range s.blocks {
+		idx, n := binary.Uvarint(data)
+		if n <= 0 {
+			return minecraft.UnexpectedValue{TagName: name, Expecting: strconv.Itoa(len(s.blocks)) + " blocks", Got: strconv.Itoa(i)}
+		} else if idx >= uint64(len(palette)) {
+			return minecraft.UnexpectedValue{TagName: name, Expecting: "palette index less than " + strconv.Itoa(len(palette)), Got: strconv.FormatUint(idx, 10)}
+		}
+
+		s.blocks[i] = palette[idx]
+		data = data[n:]
+	}
+
+	return nil
+}
+
+// splitSpongeObject separates the position of a block entity or entity from
+// the rest of its data, which, in version 3, is stored within a Data tag.
+func splitSpongeObject(c nbt.Compound, nested bool) ([]int32, nbt.Compound, error) {
+	var pos []int32
+
+	switch p := c.Get("Pos").Data().(type) {
+	case nbt.IntArray:
+		pos = p
+	case nil:
+		return nil, nil, minecraft.MissingTagError{TagName: "Pos"}
+	}
+
+	data := nbt.Compound{}
+
+	if id, ok := c.Get("Id").Data().(nbt.String); ok {
+		data = append(data, nbt.NewTag("id", id))
+	}
+
+	if nested {
+		if d, ok := c.Get("Data").Data().(nbt.Compound); ok {
+			for _, t := range d {
+				if t.Name() != "id" || len(data) == 0 {
+					data = append(data, t)
+				}
+			}
+		}
+	} else {
+		for _, t := range c {
+			if name := t.Name(); name != "Pos" && name != "Id" {
+				data = append(data, t)
+			}
+		}
+	}
+
+	return pos, data, nil
+}
+
+func decodeSpongeEntities(c nbt.Compound, nested bool) ([]nbt.Compound, error) {
+	entities, err := getCompoundList(c, "Entities")
+	if err != nil || !nested {
+		return entities, err
+	}
+
+	// Version 3 entities are flattened, so that all versions are stored
+	// alike.
+	for n, e := range entities {
+		flat := nbt.Compound{}
+
+		if pos := e.Get("Pos"); pos.TagID() != 0 {
+			flat = append(flat, pos)
+		}
+
+		if id := e.Get("Id"); id.TagID() != 0 {
+			flat = append(flat, id)
+		}
+
+		if d, ok := e.Get("Data").Data().(nbt.Compound); ok {
+			for _, t := range d {
+				if name := t.Name(); name != "Pos" && name != "Id" {
+					flat = append(flat, t)
+				}
+			}
+		}
+
+		entities[n] = flat
+	}
+
+	return entities, nil
+}
+
+// EncodeSponge writes the schematic, gzipped, in the Sponge .schem format of
+// the given version, 2 or 3, using the given mapper to convert Blocks to block
+// states. Unmapped blocks are written with their own block states.
+func (s *Schematic) EncodeSponge(w io.Writer, version int, m minecraft.BlockMapper) error {
+	if version != 2 && version != 3 {
+		return minecraft.UnexpectedValue{TagName: "Version", Expecting: "2 or 3", Got: strconv.Itoa(version)}
+	} else if s.width > 65535 || s.height > 65535 || s.length > 65535 {
+		return minecraft.ErrOOB
+	}
+
+	var (
+		palette       = nbt.Compound{}
+		indices       = make(map[string]int32)
+		data          []byte
+		buf           [binary.MaxVarintLen32]byte
+		blockEntities = nbt.NewEmptyList(nbt.TagCompound)
+		entities      = nbt.NewEmptyList(nbt.TagCompound)
+	)
+
+	for i, b := range s.blocks {
+		state, ok := minecraft.StateOf(m, b)
+		if !ok {
+			return minecraft.UnknownBlock{ID: b.ID, Data: b.Data}
+		}
+
+		key := state.String()
+
+		idx, ok := indices[key]
+		if !ok {
+			idx = int32(len(indices))
+			indices[key] = idx
+			palette = append(palette, nbt.NewTag(key, nbt.Int(idx)))
+		}
+
+		data = append(data, buf[:binary.PutUvarint(buf[:], uint64(idx))]...)
+
+		if !b.HasMetadata() {
+			continue
+		}
+
+		x, y, z := s.coords(i)
+		be := nbt.Compound{nbt.NewTag("Pos", nbt.IntArray{x, y, z})}
+		metadata := b.GetMetadata()
+
+		if id := metadata.Get("id"); id.TagID() == nbt.TagString {
+			be = append(be, nbt.NewTag("Id", id.Data()))
+
+			metadata.Remove("id")
+		}
+
+		if version == 3 {
+			be = append(be, nbt.NewTag("Data", metadata))
+		} else {
+			be = append(be, metadata...)
+		}
+
+		if err := blockEntities.Append(be); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range s.Entities {
+		if version == 3 {
+			entity := nbt.Compound{}
+			rest := nbt.Compound{}
+
+			for _, t := range e {
+				if name := t.Name(); name == "Pos" || name == "Id" {
+					entity = append(entity, t)
+				} else {
+					rest = append(rest, t)
+				}
+			}
+
+			e = append(entity, nbt.NewTag("Data", rest))
+		}
+
+		if err := entities.Append(e); err != nil {
+			return err
+		}
+	}
+
+	dataVersion := s.DataVersion
+	if dataVersion == 0 {
+		dataVersion = minecraft.DefaultDataVersion
+	}
+
+	c := nbt.Compound{
+		nbt.NewTag("Version", nbt.Int(version)),
+		nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+		nbt.NewTag("Width", nbt.Short(uint16(s.width))),
+		nbt.NewTag("Height", nbt.Short(uint16(s.height))),
+		nbt.NewTag("Length", nbt.Short(uint16(s.length))),
+		nbt.NewTag("Offset", nbt.IntArray{s.Offset[0], s.Offset[1], s.Offset[2]}),
+	}
+
+	blockData := make(nbt.ByteArray, len(data))
+
+	for n, b := range data {
+		blockData[n] = int8(b)
+	}
+
+	var root nbt.Tag
+
+	if version == 3 {
+		c = append(c, nbt.NewTag("Blocks", nbt.Compound{
+			nbt.NewTag("Palette", palette),
+			nbt.NewTag("Data", blockData),
+			nbt.NewTag("BlockEntities", blockEntities),
+		}), nbt.NewTag("Entities", entities))
+		root = nbt.NewTag("", nbt.Compound{nbt.NewTag("Schematic", c)})
+	} else {
+		c = append(c,
+			nbt.NewTag("PaletteMax", nbt.Int(len(palette))),
+			nbt.NewTag("Palette", palette),
+			nbt.NewTag("BlockData", blockData),
+			nbt.NewTag("BlockEntities", blockEntities),
+			nbt.NewTag("Entities", entities),
+		)
+		root = nbt.NewTag("Schematic", c)
+	}
+
+	g := gzip.NewWriter(w)
+
+	if err := nbt.Encode(g, root); err != nil {
+		return err
+	}
+
+	return g.Close()
+}
@@ -0,0 +1,166 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"testing"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestSponge(t *testing.T) {
+	chest := minecraft.Block{ID: 54, Data: 5}
+	chest.SetMetadata(nbt.Compound{
+		nbt.NewTag("id", nbt.String("minecraft:chest")),
+		nbt.NewTag("CustomName", nbt.String("loot")),
+	})
+
+	s := New(3, 2, 2)
+	s.Offset = [3]int32{-1, 0, 2}
+	s.Entities = []nbt.Compound{{
+		nbt.NewTag("Pos", nbt.NewList([]nbt.Data{nbt.Double(0.5), nbt.Double(1), nbt.Double(0.5)})),
+		nbt.NewTag("Id", nbt.String("minecraft:pig")),
+		nbt.NewTag("Health", nbt.Float(10)),
+	}}
+
+	blocks := map[[3]int32]minecraft.Block{
+		{0, 0, 0}: {ID: 1},
+		{2, 0, 1}: {ID: 35, Data: 14},
+		{1, 1, 0}: chest,
+		{2, 1, 1}: {ID: 9},
+	}
+
+	for pos, b := range blocks {
+		if err := s.SetBlock(pos[0], pos[1], pos[2], b); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	for _, version := range [...]int{2, 3} {
+		var buf bytes.Buffer
+
+		if err := s.EncodeSponge(&buf, version, minecraft.DefaultBlockStates); err != nil {
+			t.Fatalf("version %d: %s", version, err)
+		}
+
+		g, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		raw, err := nbt.Decode(g)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		c := raw.Data().(nbt.Compound)
+
+		if version == 3 {
+			if raw.Name() != "" {
+				t.Errorf("version 3: expecting unnamed root, got %q", raw.Name())
+			} else if c = c.Get("Schematic").Data().(nbt.Compound); c.Get("Blocks").TagID() != nbt.TagCompound {
+				t.Error("version 3: expecting Blocks compound")
+			}
+		} else if raw.Name() != "Schematic" || c.Get("BlockData").TagID() != nbt.TagByteArray {
+			t.Error("version 2: expecting root Schematic with BlockData")
+		}
+
+		if v := c.Get("Version").Data(); v != nbt.Int(version) {
+			t.Errorf("version %d: got Version tag %v", version, v)
+		} else if dv := c.Get("DataVersion").Data(); dv != nbt.Int(minecraft.DefaultDataVersion) {
+			t.Errorf("version %d: got DataVersion %v", version, dv)
+		}
+
+		d, err := DecodeSponge(&buf, minecraft.DefaultBlockStates)
+		if err != nil {
+			t.Fatalf("version %d: %s", version, err)
+		}
+
+		if d.Offset != s.Offset {
+			t.Errorf("version %d: expecting offset %v, got %v", version, s.Offset, d.Offset)
+		} else if len(d.Entities) != 1 || !d.Entities[0].Equal(s.Entities[0]) {
+			t.Errorf("version %d: entities do not match: %v", version, d.Entities)
+		}
+
+		for x := int32(0); x < 3; x++ {
+			for y := int32(0); y < 2; y++ {
+				for z := int32(0); z < 2; z++ {
+					got, _ := d.GetBlock(x, y, z)
+					if expected := blocks[[3]int32{x, y, z}]; !got.EqualBlock(expected) {
+						t.Errorf("version %d: %d,%d,%d: expecting %s, got %s", version, x, y, z, expected, got)
+					}
+				}
+			}
+		}
+	}
+
+	if err := s.SetBlock(0, 0, 1, minecraft.Block{ID: 4000}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = s.EncodeSponge(new(bytes.Buffer), 2, minecraft.DefaultBlockStates); err != (minecraft.UnknownBlock{ID: 4000}) {
+		t.Errorf("expecting UnknownBlock error, got %v", err)
+	}
+}
+
+func TestSpongeLargePalette(t *testing.T) {
+	table := minecraft.NewBlockStateTable()
+	s := New(20, 10, 1)
+
+	for i := int32(0); i < 200; i++ {
+		b := minecraft.Block{ID: 1000 + uint16(i)}
+
+		table.Add(minecraft.BlockState{Name: "test:block_" + strconv.Itoa(int(i))}, b)
+
+		if err := s.SetBlock(i%20, i/20, 0, b); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := s.EncodeSponge(&buf, 3, table); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	d, err := DecodeSponge(&buf, table)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := int32(0); i < 200; i++ {
+		if b, _ := d.GetBlock(i%20, i/20, 0); b.ID != 1000+uint16(i) {
+			t.Errorf("block %d: expecting id %d, got %d", i, 1000+i, b.ID)
+		}
+	}
+
+	buf.Reset()
+
+	if err = s.EncodeSponge(&buf, 2, table); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Block states with no mapping are decoded as unmapped blocks, which are
+	// encoded with their own block states.
+	if d, err = DecodeSponge(&buf, minecraft.DefaultBlockStates); err != nil {
+		t.Fatal(err.Error())
+	} else if b, _ := d.GetBlock(0, 0, 0); b.ID != 0 {
+		t.Errorf("expecting unmapped block, got %s", b)
+	} else if state, ok := b.Unmapped(); !ok || state.String() != "test:block_0" {
+		t.Errorf("expecting unmapped state test:block_0, got %s (%v)", state, ok)
+	}
+
+	buf.Reset()
+
+	if err = d.EncodeSponge(&buf, 3, minecraft.DefaultBlockStates); err != nil {
+		t.Fatal(err.Error())
+	} else if d, err = DecodeSponge(&buf, table); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := int32(0); i < 200; i++ {
+		if b, _ := d.GetBlock(i%20, i/20, 0); b.ID != 1000+uint16(i) {
+			t.Errorf("block %d: expecting id %d after round trip, got %d", i, 1000+i, b.ID)
+		}
+	}
+}
@@ -4,6 +4,51 @@ import (
 	"vimagination.zapto.org/minecraft/nbt"
 )
 
+// blockFormat is the way in which the blocks of a chunk are stored.
+type blockFormat uint8
+
+const (
+	// legacyBlocks stores block IDs and data in the Blocks, Add and Data
+	// byte arrays.
+	legacyBlocks blockFormat = iota
+	// packedBlocks stores block states in a Palette and a BlockStates long
+	// array, with values spanning longs, as used by Minecraft 1.13 to 1.15.
+	packedBlocks
+	// paddedBlocks is as packedBlocks, but values do not span longs, as used
+	// by Minecraft 1.16 and 1.17.
+	paddedBlocks
+	// containerBlocks stores block states and biomes in block_states and
+	// biomes compounds, each with a palette and padded data, as used by
+	// Minecraft 1.18 onwards.
+	containerBlocks
+)
+
+// Data versions at which the chunk format changed.
+const (
+	dataVersionPalette   = 1451 // 17w47a
+	dataVersionBiomes3D  = 2203 // 19w36a
+	dataVersionPadded    = 2529 // 20w17a
+	dataVersionContainer = 2844 // 21w43a
+)
+
+// DefaultDataVersion is the data version of Minecraft 1.13, the first release
+// to store blocks as block states. It is written by the schematic and
+// structure formats when no data version has been set.
+const DefaultDataVersion = 1519
+
+func formatFromDataVersion(dataVersion int32) blockFormat {
+	switch {
+	case dataVersion >= dataVersionContainer:
+		return containerBlocks
+	case dataVersion >= dataVersionPadded:
+		return paddedBlocks
+	case dataVersion >= dataVersionPalette:
+		return packedBlocks
+	}
+
+	return legacyBlocks
+}
+
 func yzx(x, y, z int32) uint32 {
 	return (uint32(y&15) << 8) | (uint32(z&15) << 4) | uint32(x&15)
 }
@@ -34,9 +79,12 @@ func setNibble(arr nbt.ByteArray, x, y, z int32, data byte) {
 
 type section struct {
 	section    nbt.Compound
+	format     blockFormat
 	blocks     nbt.ByteArray
 	add        nbt.ByteArray
 	data       nbt.ByteArray
+	states     *blockStates
+	biomes     *biomeStates
 	blockLight nbt.ByteArray
 	skyLight   nbt.ByteArray
 }
@@ -116,6 +164,10 @@ func loadSection(c nbt.Compound) (*section, error) {
 }
 
 func (s *section) GetBlock(x, y, z int32) Block {
+	if s.states != nil {
+		return s.states.get(yzx(x, y, z))
+	}
+
 	return Block{
 		ID:   uint16(getNibble(s.add, x, y, z))<<8 | uint16(byte(s.blocks[yzx(x, y, z)])),
 		Data: getNibble(s.data, x, y, z),
@@ -123,31 +175,120 @@ func (s *section) GetBlock(x, y, z int32) Block {
 }
 
 func (s *section) SetBlock(x, y, z int32, b Block) {
+	if s.states != nil {
+		s.states.set(yzx(x, y, z), b)
+
+		return
+	}
+
 	s.blocks[yzx(x, y, z)] = int8(b.ID & 255)
 	setNibble(s.add, x, y, z, byte(b.ID>>8))
 	setNibble(s.data, x, y, z, b.Data)
 }
 
+// GetOpacity returns the opacity of the block at the given coords.
 func (s *section) GetOpacity(x, y, z int32) uint8 {
 	return s.GetBlock(x, y, z).Opacity()
 }
 
+// Sections stored in the paletted formats may have no light data, in which
+// case all block light is 0 and all sky light is 15 until set.
+
 func (s *section) GetBlockLight(x, y, z int32) uint8 {
+	if s.blockLight == nil {
+		return 0
+	}
+
 	return getNibble(s.blockLight, x, y, z)
 }
 
 func (s *section) SetBlockLight(x, y, z int32, l uint8) {
+	if s.blockLight == nil {
+		s.blockLight = make(nbt.ByteArray, 2048)
+		s.section.Set(nbt.NewTag("BlockLight", s.blockLight))
+	}
+
 	setNibble(s.blockLight, x, y, z, l)
 }
 
 func (s *section) GetSkyLight(x, y, z int32) uint8 {
+	if s.skyLight == nil {
+		return 15
+	}
+
 	return getNibble(s.skyLight, x, y, z)
 }
 
 func (s *section) SetSkyLight(x, y, z int32, l uint8) {
+	if s.skyLight == nil {
+		s.skyLight = make(nbt.ByteArray, 2048)
+
+		for i := range s.skyLight {
+			s.skyLight[i] = -1
+		}
+
+		s.section.Set(nbt.NewTag("SkyLight", s.skyLight))
+	}
+
 	setNibble(s.skyLight, x, y, z, l)
 }
 
 func (s *section) SetY(y int32) {
 	s.section.Set(nbt.NewTag("Y", nbt.Byte(y>>4)))
 }
+
+// compound returns the NBT of the section, updated with any changes to its
+// blocks or biomes.
+func (s *section) compound() nbt.Compound {
+	if s.states != nil && s.states.changed {
+		palette, data := s.states.encode(s.format)
+
+		switch s.format {
+		case containerBlocks:
+			container, _ := s.section.Get("block_states").Data().(nbt.Compound)
+			container = append(nbt.Compound{}, container...)
+
+			container.Set(nbt.NewTag("palette", palette))
+
+			if data == nil {
+				container.Remove("data")
+			} else {
+				container.Set(nbt.NewTag("data", data))
+			}
+
+			s.section.Set(nbt.NewTag("block_states", container))
+		default:
+			s.section.Set(nbt.NewTag("Palette", palette))
+			s.section.Set(nbt.NewTag("BlockStates", data))
+		}
+
+		s.states.changed = false
+	}
+
+	if s.biomes != nil && s.biomes.changed {
+		container, _ := s.section.Get("biomes").Data().(nbt.Compound)
+		container = append(nbt.Compound{}, container...)
+		palette, data := s.biomes.encode()
+
+		container.Set(nbt.NewTag("palette", palette))
+
+		if data == nil {
+			container.Remove("data")
+		} else {
+			container.Set(nbt.NewTag("data", data))
+		}
+
+		s.section.Set(nbt.NewTag("biomes", container))
+
+		s.biomes.changed = false
+	}
+
+	return s.section
+}
+
+func (s *section) setMapper(m BlockMapper) {
+	if s.states != nil {
+		s.states.mapper = m
+		s.states.mapped = nil
+	}
+}
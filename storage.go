@@ -0,0 +1,101 @@
+package minecraft
+
+import (
+	"vimagination.zapto.org/memio"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// Storage is the kind of chunk data stored in a set of region files, and is
+// the name of the directory those region files are stored in.
+type Storage string
+
+// Storage kinds.
+const (
+	StorageRegion   Storage = "region"
+	StorageEntities Storage = "entities"
+	StoragePOI      Storage = "poi"
+)
+
+// The StoragePath interface is implemented by Path types that can store all
+// of the kinds of chunk data used by Minecraft 1.17+, such as entities and
+// points of interest, alongside the terrain.
+type StoragePath interface {
+	Path
+	// Returns an empty nbt.Tag (TagEnd) when chunk does not exists.
+	GetStorageChunk(Storage, int32, int32) (nbt.Tag, error)
+	SetStorageChunk(Storage, int32, int32, nbt.Tag) error
+	RemoveStorageChunk(Storage, int32, int32) error
+}
+
+// The StorageLister interface is implemented by StoragePath types that can
+// list the chunks they contain for each storage kind.
+type StorageLister interface {
+	StoragePath
+	// GetStorageRegions returns a list of region x,z coords of all regions
+	// of the given storage kind.
+	GetStorageRegions(Storage) [][2]int32
+	// GetStorageChunks returns a list of all chunks of the given storage
+	// kind within a region with coords x,z.
+	GetStorageChunks(Storage, int32, int32) ([][2]int32, error)
+}
+
+// storageKinds are the storage kinds, other than StorageRegion, that are
+// copied between paths.
+var storageKinds = [...]Storage{StorageEntities, StoragePOI}
+
+// GetStorageChunk returns the chunk data at chunk coords x, z from the given
+// storage kind.
+func (m *MemPath) GetStorageChunk(s Storage, x, z int32) (nbt.Tag, error) {
+	if s == StorageRegion {
+		return m.GetChunk(x, z)
+	}
+
+	c := m.storage[s][uint64(z)<<32|uint64(uint32(x))]
+	if c == nil {
+		return nbt.Tag{}, nil
+	}
+
+	return m.read(c)
+}
+
+// SetStorageChunk saves the chunk data at chunk coords x, z to the given
+// storage kind.
+func (m *MemPath) SetStorageChunk(s Storage, x, z int32, data nbt.Tag) error {
+	var buf memio.Buffer
+
+	if err := m.write(data, &buf); err != nil {
+		return err
+	}
+
+	pos := uint64(z)<<32 | uint64(uint32(x))
+
+	if s == StorageRegion {
+		m.chunks[pos] = buf
+
+		return nil
+	}
+
+	if m.storage == nil {
+		m.storage = make(map[Storage]map[uint64]memio.Buffer)
+	}
+
+	if m.storage[s] == nil {
+		m.storage[s] = make(map[uint64]memio.Buffer)
+	}
+
+	m.storage[s][pos] = buf
+
+	return nil
+}
+
+// RemoveStorageChunk deletes the chunk data at chunk coords x, z from the
+// given storage kind.
+func (m *MemPath) RemoveStorageChunk(s Storage, x, z int32) error {
+	if s == StorageRegion {
+		return m.RemoveChunk(x, z)
+	}
+
+	delete(m.storage[s], uint64(z)<<32|uint64(uint32(x)))
+
+	return nil
+}
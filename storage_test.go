@@ -0,0 +1,79 @@
+package minecraft
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func testStoragePath(t *testing.T, p StoragePath) {
+	entities := nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Position", nbt.IntArray{3, -40}),
+		nbt.NewTag("Entities", nbt.NewEmptyList(nbt.TagCompound)),
+	})
+	poi := nbt.NewTag("", nbt.Compound{
+		nbt.NewTag("Sections", nbt.Compound{}),
+	})
+
+	if err := p.SetChunk(addPos(3, -40, 0)); err != nil {
+		t.Fatal(err.Error())
+	} else if err = p.SetStorageChunk(StorageEntities, 3, -40, entities); err != nil {
+		t.Fatal(err.Error())
+	} else if err = p.SetStorageChunk(StoragePOI, 3, -40, poi); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, test := range [...]struct {
+		Storage Storage
+		Data    nbt.Tag
+	}{
+		{StorageRegion, addPos(3, -40, 0)},
+		{StorageEntities, entities},
+		{StoragePOI, poi},
+	} {
+		if chunk, err := p.GetStorageChunk(test.Storage, 3, -40); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.Storage, err)
+		} else if !chunk.Equal(test.Data) {
+			t.Errorf("%s: chunk data does not match", test.Storage)
+		}
+	}
+
+	if err := p.RemoveStorageChunk(StorageEntities, 3, -40); err != nil {
+		t.Fatal(err.Error())
+	} else if chunk, err := p.GetStorageChunk(StorageEntities, 3, -40); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if chunk.TagID() != 0 {
+		t.Error("expecting entities chunk to be removed")
+	} else if chunk, err = p.GetChunk(3, -40); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if chunk.TagID() == 0 {
+		t.Error("expecting terrain chunk to remain")
+	} else if chunk, err = p.GetStorageChunk(StoragePOI, 3, -40); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if chunk.TagID() == 0 {
+		t.Error("expecting poi chunk to remain")
+	}
+}
+
+func TestMemPathStorage(t *testing.T) {
+	testStoragePath(t, NewMemPath())
+}
+
+func TestFilePathStorage(t *testing.T) {
+	tempDir := t.TempDir()
+
+	f, err := NewFilePath(tempDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testStoragePath(t, f)
+
+	for _, dir := range [...]string{"region", "entities", "poi"} {
+		if _, err := os.Stat(path.Join(tempDir, dir, "r.0.-2.mca")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package structure
+
+import (
+	"math/rand"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft"
+)
+
+// Rotation is a clockwise rotation, around the vertical axis, applied to a
+// structure when it is placed.
+type Rotation uint8
+
+// Rotations.
+const (
+	RotateNone Rotation = iota
+	RotateClockwise90
+	RotateClockwise180
+	RotateCounterclockwise90
+)
+
+// Mirror is a reflection applied to a structure when it is placed.
+type Mirror uint8
+
+// Mirrors.
+const (
+	MirrorNone Mirror = iota
+	// MirrorLeftRight flips the structure along the Z axis, swapping north
+	// and south.
+	MirrorLeftRight
+	// MirrorFrontBack flips the structure along the X axis, swapping east
+	// and west.
+	MirrorFrontBack
+)
+
+// PlaceOptions are the options used when placing a structure.
+type PlaceOptions struct {
+	Rotation Rotation
+	Mirror   Mirror
+
+	// Integrity is the chance, from 0 to 1, that each block is placed. A
+	// zero value is treated as 1, placing every block.
+	Integrity float64
+	// Seed seeds the random selection of blocks when Integrity is less
+	// than 1.
+	Seed int64
+
+	// Palette selects which of the palettes of the structure is used.
+	Palette int
+}
+
+// PlacedSize returns the size the structure will occupy once rotated.
+func (s *Structure) PlacedSize(r Rotation) [3]int32 {
+	if r == RotateClockwise90 || r == RotateCounterclockwise90 {
+		return [3]int32{s.Size[2], s.Size[1], s.Size[0]}
+	}
+
+	return s.Size
+}
+
+// Place writes the blocks of the structure to dst, with the lowest corner of
+// the transformed structure at the given coordinates. The given mapper is
+// used to convert the block states of the structure into blocks; block states
+// it has no mapping for are placed as unmapped blocks.
+//
+// Mirroring is applied before rotation. Entities are not placed.
+func (s *Structure) Place(dst minecraft.BlockWriter, x, y, z int32, m minecraft.BlockMapper, opts PlaceOptions) error {
+	if opts.Palette < 0 || opts.Palette >= len(s.Palettes) {
+		return minecraft.UnexpectedValue{TagName: "Palette", Expecting: "index less than " + strconv.Itoa(len(s.Palettes)), Got: strconv.Itoa(opts.Palette)}
+	}
+
+	palette := s.Palettes[opts.Palette]
+	blocks := make([]minecraft.Block, len(palette))
+
+	for n, state := range palette {
+		state = transformState(state, opts.Mirror, opts.Rotation)
+
+		blocks[n] = minecraft.BlockOf(m, state)
+	}
+
+	var rnd *rand.Rand
+
+	if opts.Integrity > 0 && opts.Integrity < 1 {
+		rnd = rand.New(rand.NewSource(opts.Seed))
+	}
+
+	for _, b := range s.Blocks {
+		if rnd != nil && rnd.Float64() > opts.Integrity {
+			continue
+		}
+
+		if b.State < 0 || b.State >= len(blocks) {
+			return minecraft.ErrOOB
+		}
+
+		bx, bz := s.transformPos(b.Pos[0], b.Pos[2], opts.Mirror, opts.Rotation)
+		block := blocks[b.State]
+
+		if b.NBT != nil {
+			block.SetMetadata(b.NBT)
+		}
+
+		if err := dst.SetBlock(x+bx, y+b.Pos[1], z+bz, block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Structure) transformPos(x, z int32, m Mirror, r Rotation) (int32, int32) {
+	sx, sz := s.Size[0], s.Size[2]
+
+	switch m {
+	case MirrorLeftRight:
+		z = sz - 1 - z
+	case MirrorFrontBack:
+		x = sx - 1 - x
+	}
+
+	switch r {
+	case RotateClockwise90:
+		return sz - 1 - z, x
+	case RotateClockwise180:
+		return sx - 1 - x, sz - 1 - z
+	case RotateCounterclockwise90:
+		return z, sx - 1 - x
+	}
+
+	return x, z
+}
+
+var (
+	horizontal = [...]string{"north", "east", "south", "west"}
+	railShapes = map[string][2]string{
+		"north_south":     {"north", "south"},
+		"east_west":       {"east", "west"},
+		"ascending_north": {"ascending", "north"},
+		"ascending_east":  {"ascending", "east"},
+		"ascending_south": {"ascending", "south"},
+		"ascending_west":  {"ascending", "west"},
+		"north_east":      {"north", "east"},
+		"south_east":      {"south", "east"},
+		"south_west":      {"south", "west"},
+		"north_west":      {"north", "west"},
+	}
+)
+
+func horizontalIndex(dir string) int {
+	for n, d := range horizontal {
+		if d == dir {
+			return n
+		}
+	}
+
+	return -1
+}
+
+func transformDirection(dir string, m Mirror, r Rotation) string {
+	n := horizontalIndex(dir)
+	if n < 0 {
+		return dir
+	}
+
+	switch m {
+	case MirrorLeftRight:
+		if n == 0 || n == 2 {
+			n = 2 - n
+		}
+	case MirrorFrontBack:
+		if n == 1 || n == 3 {
+			n = 4 - n
+		}
+	}
+
+	return horizontal[(n+int(r))&3]
+}
+
+func transformRailShape(shape string, m Mirror, r Rotation) string {
+	parts, ok := railShapes[shape]
+	if !ok {
+		return shape
+	}
+
+	if parts[0] == "ascending" {
+		return "ascending_" + transformDirection(parts[1], m, r)
+	}
+
+	a, b := transformDirection(parts[0], m, r), transformDirection(parts[1], m, r)
+
+	for name, p := range railShapes {
+		if (p[0] == a && p[1] == b) || (p[0] == b && p[1] == a) {
+			return name
+		}
+	}
+
+	return shape
+}
+
+func swapLeftRight(v string) string {
+	switch v {
+	case "left":
+		return "right"
+	case "right":
+		return "left"
+	}
+
+	return v
+}
+
+func swapStairShape(v string) string {
+	switch v {
+	case "inner_left":
+		return "inner_right"
+	case "inner_right":
+		return "inner_left"
+	case "outer_left":
+		return "outer_right"
+	case "outer_right":
+		return "outer_left"
+	}
+
+	return v
+}
+
+// transformState applies the given mirror and then rotation to the
+// directional properties of a block state.
+func transformState(state minecraft.BlockState, m Mirror, r Rotation) minecraft.BlockState {
+	if len(state.Properties) == 0 || (m == MirrorNone && r == RotateNone) {
+		return state
+	}
+
+	props := make(map[string]string, len(state.Properties))
+
+	for k, v := range state.Properties {
+		switch k {
+		case "facing":
+			v = transformDirection(v, m, r)
+		case "axis":
+			if r == RotateClockwise90 || r == RotateCounterclockwise90 {
+				switch v {
+				case "x":
+					v = "z"
+				case "z":
+					v = "x"
+				}
+			}
+		case "rotation":
+			if rot, err := strconv.Atoi(v); err == nil && rot >= 0 && rot < 16 {
+				switch m {
+				case MirrorLeftRight:
+					rot = (8 - rot) & 15
+				case MirrorFrontBack:
+					rot = (16 - rot) & 15
+				}
+
+				v = strconv.Itoa((rot + 4*int(r)) & 15)
+			}
+		case "shape":
+			if _, ok := railShapes[v]; ok {
+				v = transformRailShape(v, m, r)
+			} else if m != MirrorNone {
+				v = swapStairShape(v)
+			}
+		case "hinge", "type":
+			if m != MirrorNone {
+				v = swapLeftRight(v)
+			}
+		case "north", "east", "south", "west":
+			continue
+		}
+
+		props[k] = v
+	}
+
+	for _, dir := range horizontal {
+		if v, ok := state.Properties[dir]; ok {
+			props[transformDirection(dir, m, r)] = v
+		}
+	}
+
+	return minecraft.BlockState{Name: state.Name, Properties: props}
+}
@@ -0,0 +1,407 @@
+// Package structure implements reading and writing of the structure files
+// saved by structure blocks and used by data packs.
+package structure // import "vimagination.zapto.org/minecraft/structure"
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+// Block is a single block within a structure.
+type Block struct {
+	// Pos is the position of the block relative to the corner of the
+	// structure.
+	Pos [3]int32
+	// State is the index of the block's state within the palette.
+	State int
+	// NBT is the block entity data of the block, if any.
+	NBT nbt.Compound
+}
+
+// Entity is an entity within a structure.
+type Entity struct {
+	// Pos is the exact position of the entity relative to the corner of the
+	// structure.
+	Pos [3]float64
+	// BlockPos is the position of the block containing the entity.
+	BlockPos [3]int32
+	// NBT is the data of the entity.
+	NBT nbt.Compound
+}
+
+// Structure is a structure, as saved by a structure block.
+//
+// Positions within the structure that have no Block are structure voids,
+// which are left unchanged when the structure is placed.
+type Structure struct {
+	Size        [3]int32
+	DataVersion int32
+
+	// Palettes contains the block states of the blocks of the structure.
+	// Most structures have a single palette, but some, such as shipwrecks,
+	// have many, of which one is chosen when placed.
+	Palettes [][]minecraft.BlockState
+
+	Blocks   []Block
+	Entities []Entity
+}
+
+// Save creates a structure containing all of the blocks, including air, in
+// the given area of src, using the given mapper to convert the blocks to block
+// states. Unmapped blocks are saved with their own block states.
+func Save(src minecraft.BlockReader, area minecraft.Area, m minecraft.BlockMapper) (*Structure, error) {
+	s := &Structure{
+		Size:     [3]int32{area.Width, area.Height, area.Length},
+		Palettes: [][]minecraft.BlockState{nil},
+	}
+
+	indices := make(map[string]int)
+
+	for y := int32(0); y < area.Height; y++ {
+		for z := int32(0); z < area.Length; z++ {
+			for x := int32(0); x < area.Width; x++ {
+				b, err := src.GetBlock(area.X+x, area.Y+y, area.Z+z)
+				if err != nil {
+					return nil, err
+				}
+
+				state, ok := minecraft.StateOf(m, b)
+				if !ok {
+					return nil, minecraft.UnknownBlock{ID: b.ID, Data: b.Data}
+				}
+
+				key := state.String()
+
+				idx, ok := indices[key]
+				if !ok {
+					idx = len(s.Palettes[0])
+					indices[key] = idx
+					s.Palettes[0] = append(s.Palettes[0], state)
+				}
+
+				s.Blocks = append(s.Blocks, Block{
+					Pos:   [3]int32{x, y, z},
+					State: idx,
+					NBT:   b.GetMetadata(),
+				})
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func getTag(c nbt.Compound, name string, tagType nbt.TagID) (nbt.Data, error) {
+	tag := c.Get(name)
+	if tag.TagID() == 0 {
+		return nil, minecraft.MissingTagError{TagName: name}
+	} else if tag.TagID() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name, Expecting: tagType, Got: tag.TagID()}
+	}
+
+	return tag.Data(), nil
+}
+
+func getList(c nbt.Compound, name string, tagType nbt.TagID, length int) (nbt.List, error) {
+	d, err := getTag(c, name, nbt.TagList)
+	if err != nil {
+		return nil, err
+	}
+
+	list := d.(nbt.List)
+	if list.Len() == 0 && length <= 0 {
+		return list, nil
+	} else if list.TagType() != tagType {
+		return nil, minecraft.WrongTypeError{TagName: name + "->Child", Expecting: tagType, Got: list.TagType()}
+	} else if length > 0 && list.Len() != length {
+		return nil, minecraft.UnexpectedValue{TagName: name, Expecting: strconv.Itoa(length) + " values", Got: strconv.Itoa(list.Len())}
+	}
+
+	return list, nil
+}
+
+func getIntCoords(c nbt.Compound, name string) ([3]int32, error) {
+	var pos [3]int32
+
+	list, err := getList(c, name, nbt.TagInt, 3)
+	if err != nil {
+		return pos, err
+	}
+
+	for n := range pos {
+		pos[n] = int32(list.Get(n).(nbt.Int))
+	}
+
+	return pos, nil
+}
+
+func intCoords(pos [3]int32) nbt.List {
+	return &nbt.ListInt{nbt.Int(pos[0]), nbt.Int(pos[1]), nbt.Int(pos[2])}
+}
+
+// Decode reads a gzipped structure file.
+func Decode(r io.Reader) (*Structure, error) {
+	g, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := nbt.Decode(g)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := data.Data().(nbt.Compound)
+	if !ok {
+		return nil, minecraft.WrongTypeError{TagName: "[Structure]", Expecting: nbt.TagCompound, Got: data.TagID()}
+	}
+
+	s := new(Structure)
+
+	if s.Size, err = getIntCoords(c, "size"); err != nil {
+		return nil, err
+	}
+
+	if dv, ok := c.Get("DataVersion").Data().(nbt.Int); ok {
+		s.DataVersion = int32(dv)
+	}
+
+	if c.Get("palettes").TagID() != 0 {
+		palettes, err := getList(c, "palettes", nbt.TagList, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < palettes.Len(); i++ {
+			palette, err := decodePalette(palettes.Get(i).(nbt.List), "palettes->Child")
+			if err != nil {
+				return nil, err
+			}
+
+			s.Palettes = append(s.Palettes, palette)
+		}
+	} else {
+		list, err := getList(c, "palette", nbt.TagCompound, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		palette, err := decodePalette(list, "palette")
+		if err != nil {
+			return nil, err
+		}
+
+		s.Palettes = [][]minecraft.BlockState{palette}
+	}
+
+	blocks, err := getList(c, "blocks", nbt.TagCompound, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < blocks.Len(); i++ {
+		b := blocks.Get(i).(nbt.Compound)
+
+		pos, err := getIntCoords(b, "pos")
+		if err != nil {
+			return nil, err
+		}
+
+		state, err := getTag(b, "state", nbt.TagInt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, palette := range s.Palettes {
+			if int(state.(nbt.Int)) >= len(palette) || state.(nbt.Int) < 0 {
+				return nil, minecraft.UnexpectedValue{TagName: "blocks->Child->state", Expecting: "index less than " + strconv.Itoa(len(palette)), Got: strconv.FormatInt(int64(state.(nbt.Int)), 10)}
+			}
+		}
+
+		block := Block{Pos: pos, State: int(state.(nbt.Int))}
+
+		if data, ok := b.Get("nbt").Data().(nbt.Compound); ok {
+			block.NBT = data
+		}
+
+		s.Blocks = append(s.Blocks, block)
+	}
+
+	if c.Get("entities").TagID() != 0 {
+		entities, err := getList(c, "entities", nbt.TagCompound, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < entities.Len(); i++ {
+			e := entities.Get(i).(nbt.Compound)
+
+			var entity Entity
+
+			pos, err := getList(e, "pos", nbt.TagDouble, 3)
+			if err != nil {
+				return nil, err
+			}
+
+			for n := range entity.Pos {
+				entity.Pos[n] = float64(pos.Get(n).(nbt.Double))
+			}
+
+			if entity.BlockPos, err = getIntCoords(e, "blockPos"); err != nil {
+				return nil, err
+			}
+
+			if data, ok := e.Get("nbt").Data().(nbt.Compound); ok {
+				entity.NBT = data
+			}
+
+			s.Entities = append(s.Entities, entity)
+		}
+	}
+
+	return s, nil
+}
+
+func decodePalette(list nbt.List, name string) ([]minecraft.BlockState, error) {
+	if list.Len() > 0 && list.TagType() != nbt.TagCompound {
+		return nil, minecraft.WrongTypeError{TagName: name + "->Child", Expecting: nbt.TagCompound, Got: list.TagType()}
+	}
+
+	palette := make([]minecraft.BlockState, list.Len())
+
+	for i := range palette {
+		c := list.Get(i).(nbt.Compound)
+
+		n, err := getTag(c, "Name", nbt.TagString)
+		if err != nil {
+			return nil, err
+		}
+
+		palette[i].Name = string(n.(nbt.String))
+
+		if props, ok := c.Get("Properties").Data().(nbt.Compound); ok {
+			palette[i].Properties = make(map[string]string, len(props))
+
+			for _, p := range props {
+				v, ok := p.Data().(nbt.String)
+				if !ok {
+					return nil, minecraft.WrongTypeError{TagName: "Properties->" + p.Name(), Expecting: nbt.TagString, Got: p.TagID()}
+				}
+
+				palette[i].Properties[p.Name()] = string(v)
+			}
+		}
+	}
+
+	return palette, nil
+}
+
+func encodePalette(palette []minecraft.BlockState) (nbt.List, error) {
+	list := nbt.NewEmptyList(nbt.TagCompound)
+
+	for _, state := range palette {
+		c := nbt.Compound{nbt.NewTag("Name", nbt.String(state.Name))}
+
+		if len(state.Properties) > 0 {
+			props := make(nbt.Compound, 0, len(state.Properties))
+
+			for _, k := range state.PropertyNames() {
+				props = append(props, nbt.NewTag(k, nbt.String(state.Properties[k])))
+			}
+
+			c = append(c, nbt.NewTag("Properties", props))
+		}
+
+		if err := list.Append(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// Encode writes the structure, gzipped, in the structure file format.
+func (s *Structure) Encode(w io.Writer) error {
+	dataVersion := s.DataVersion
+	if dataVersion == 0 {
+		dataVersion = minecraft.DefaultDataVersion
+	}
+
+	c := nbt.Compound{
+		nbt.NewTag("DataVersion", nbt.Int(dataVersion)),
+		nbt.NewTag("size", intCoords(s.Size)),
+	}
+
+	switch len(s.Palettes) {
+	case 0:
+		c = append(c, nbt.NewTag("palette", nbt.NewEmptyList(nbt.TagCompound)))
+	case 1:
+		palette, err := encodePalette(s.Palettes[0])
+		if err != nil {
+			return err
+		}
+
+		c = append(c, nbt.NewTag("palette", palette))
+	default:
+		palettes := nbt.NewEmptyList(nbt.TagList)
+
+		for _, p := range s.Palettes {
+			palette, err := encodePalette(p)
+			if err != nil {
+				return err
+			} else if err = palettes.Append(palette); err != nil {
+				return err
+			}
+		}
+
+		c = append(c, nbt.NewTag("palettes", palettes))
+	}
+
+	blocks := nbt.NewEmptyList(nbt.TagCompound)
+
+	for _, b := range s.Blocks {
+		block := nbt.Compound{
+			nbt.NewTag("pos", intCoords(b.Pos)),
+			nbt.NewTag("state", nbt.Int(b.State)),
+		}
+
+		if b.NBT != nil {
+			block = append(block, nbt.NewTag("nbt", b.NBT))
+		}
+
+		if err := blocks.Append(block); err != nil {
+			return err
+		}
+	}
+
+	entities := nbt.NewEmptyList(nbt.TagCompound)
+
+	for _, e := range s.Entities {
+		entity := nbt.Compound{
+			nbt.NewTag("pos", &nbt.ListDouble{nbt.Double(e.Pos[0]), nbt.Double(e.Pos[1]), nbt.Double(e.Pos[2])}),
+			nbt.NewTag("blockPos", intCoords(e.BlockPos)),
+		}
+
+		if e.NBT != nil {
+			entity = append(entity, nbt.NewTag("nbt", e.NBT))
+		}
+
+		if err := entities.Append(entity); err != nil {
+			return err
+		}
+	}
+
+	c = append(c, nbt.NewTag("blocks", blocks), nbt.NewTag("entities", entities))
+
+	g := gzip.NewWriter(w)
+
+	if err := nbt.Encode(g, nbt.NewTag("", c)); err != nil {
+		return err
+	}
+
+	return g.Close()
+}
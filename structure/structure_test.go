@@ -0,0 +1,259 @@
+package structure
+
+import (
+	"bytes"
+	"testing"
+
+	"vimagination.zapto.org/minecraft"
+	"vimagination.zapto.org/minecraft/nbt"
+)
+
+func TestStructure(t *testing.T) {
+	src, err := minecraft.NewLevel(minecraft.NewMemPath())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chest := minecraft.Block{ID: 54, Data: 2}
+	chest.SetMetadata(nbt.Compound{
+		nbt.NewTag("id", nbt.String("Chest")),
+		nbt.NewTag("Items", nbt.NewEmptyList(nbt.TagCompound)),
+	})
+
+	for _, b := range [...]struct {
+		x, y, z int32
+		block   minecraft.Block
+	}{
+		{0, 64, 0, minecraft.Block{ID: 1}},
+		{1, 64, 2, minecraft.Block{ID: 35, Data: 14}},
+		{0, 64, 1, chest},
+		{1, 64, 0, minecraft.Block{ID: 17, Data: 4}},
+	} {
+		if err := src.SetBlock(b.x, b.y, b.z, b.block); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	s, err := Save(src, minecraft.Area{X: 0, Y: 64, Z: 0, Width: 2, Height: 1, Length: 3}, minecraft.DefaultBlockStates)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(s.Blocks) != 6 {
+		t.Fatalf("expecting 6 blocks, got %d", len(s.Blocks))
+	} else if len(s.Palettes) != 1 || len(s.Palettes[0]) != 5 {
+		t.Fatalf("expecting single palette of 5 states, got %v", s.Palettes)
+	}
+
+	s.Entities = append(s.Entities, Entity{
+		Pos:      [3]float64{0.5, 0, 1.5},
+		BlockPos: [3]int32{0, 0, 1},
+		NBT:      nbt.Compound{nbt.NewTag("id", nbt.String("minecraft:pig"))},
+	})
+
+	var buf bytes.Buffer
+
+	if err = s.Encode(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s, err = Decode(&buf); err != nil {
+		t.Fatal(err.Error())
+	} else if s.Size != [3]int32{2, 1, 3} {
+		t.Fatalf("expecting size [2 1 3], got %v", s.Size)
+	} else if s.DataVersion != minecraft.DefaultDataVersion {
+		t.Errorf("expecting data version %d, got %d", minecraft.DefaultDataVersion, s.DataVersion)
+	} else if len(s.Blocks) != 6 || len(s.Palettes) != 1 {
+		t.Fatalf("expecting 6 blocks and 1 palette, got %d and %d", len(s.Blocks), len(s.Palettes))
+	} else if len(s.Entities) != 1 || s.Entities[0].Pos != [3]float64{0.5, 0, 1.5} || s.Entities[0].NBT.Get("id").Data().(nbt.String) != "minecraft:pig" {
+		t.Errorf("entity not decoded correctly: %v", s.Entities)
+	}
+
+	for n, test := range [...]struct {
+		opts   PlaceOptions
+		blocks []struct {
+			x, z  int32
+			block minecraft.Block
+		}
+	}{
+		{
+			opts: PlaceOptions{},
+			blocks: []struct {
+				x, z  int32
+				block minecraft.Block
+			}{
+				{0, 0, minecraft.Block{ID: 1}},
+				{1, 2, minecraft.Block{ID: 35, Data: 14}},
+				{0, 1, minecraft.Block{ID: 54, Data: 2}},
+				{1, 0, minecraft.Block{ID: 17, Data: 4}},
+			},
+		},
+		{
+			opts: PlaceOptions{Rotation: RotateClockwise90},
+			blocks: []struct {
+				x, z  int32
+				block minecraft.Block
+			}{
+				{2, 0, minecraft.Block{ID: 1}},
+				{0, 1, minecraft.Block{ID: 35, Data: 14}},
+				{1, 0, minecraft.Block{ID: 54, Data: 5}},
+				{2, 1, minecraft.Block{ID: 17, Data: 8}},
+			},
+		},
+		{
+			opts: PlaceOptions{Rotation: RotateClockwise180},
+			blocks: []struct {
+				x, z  int32
+				block minecraft.Block
+			}{
+				{1, 2, minecraft.Block{ID: 1}},
+				{0, 0, minecraft.Block{ID: 35, Data: 14}},
+				{1, 1, minecraft.Block{ID: 54, Data: 3}},
+				{0, 2, minecraft.Block{ID: 17, Data: 4}},
+			},
+		},
+		{
+			opts: PlaceOptions{Mirror: MirrorFrontBack},
+			blocks: []struct {
+				x, z  int32
+				block minecraft.Block
+			}{
+				{1, 0, minecraft.Block{ID: 1}},
+				{0, 2, minecraft.Block{ID: 35, Data: 14}},
+				{1, 1, minecraft.Block{ID: 54, Data: 2}},
+				{0, 0, minecraft.Block{ID: 17, Data: 4}},
+			},
+		},
+		{
+			opts: PlaceOptions{Mirror: MirrorLeftRight, Rotation: RotateCounterclockwise90},
+			blocks: []struct {
+				x, z  int32
+				block minecraft.Block
+			}{
+				{2, 1, minecraft.Block{ID: 1}},
+				{0, 0, minecraft.Block{ID: 35, Data: 14}},
+				{1, 1, minecraft.Block{ID: 54, Data: 5}},
+				{2, 0, minecraft.Block{ID: 17, Data: 8}},
+			},
+		},
+	} {
+		dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err = s.Place(dst, 100, 70, 100, minecraft.DefaultBlockStates, test.opts); err != nil {
+			t.Fatalf("test %d: %s", n+1, err)
+		}
+
+		for m, b := range test.blocks {
+			got, err := dst.GetBlock(100+b.x, 70, 100+b.z)
+			if err != nil {
+				t.Fatalf("test %d.%d: %s", n+1, m+1, err)
+			} else if got.ID != b.block.ID || got.Data != b.block.Data {
+				t.Errorf("test %d.%d: expecting block %d:%d at %d,%d, got %d:%d", n+1, m+1, b.block.ID, b.block.Data, b.x, b.z, got.ID, got.Data)
+			} else if got.ID == 54 && !got.HasMetadata() {
+				t.Errorf("test %d.%d: expecting chest to keep its metadata", n+1, m+1)
+			}
+		}
+	}
+}
+
+func TestStructureIntegrity(t *testing.T) {
+	s := &Structure{
+		Size:     [3]int32{16, 1, 16},
+		Palettes: [][]minecraft.BlockState{{{Name: "minecraft:stone"}}},
+	}
+
+	for x := int32(0); x < 16; x++ {
+		for z := int32(0); z < 16; z++ {
+			s.Blocks = append(s.Blocks, Block{Pos: [3]int32{x, 0, z}})
+		}
+	}
+
+	count := func(seed int64) int {
+		dst, err := minecraft.NewLevel(minecraft.NewMemPath())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err = s.Place(dst, 0, 10, 0, minecraft.DefaultBlockStates, PlaceOptions{Integrity: 0.5, Seed: seed}); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var placed int
+
+		for x := int32(0); x < 16; x++ {
+			for z := int32(0); z < 16; z++ {
+				if b, err := dst.GetBlock(x, 10, z); err != nil {
+					t.Fatal(err.Error())
+				} else if b.ID == 1 {
+					placed++
+				}
+			}
+		}
+
+		return placed
+	}
+
+	if placed := count(1); placed == 0 || placed == len(s.Blocks) {
+		t.Errorf("expecting some, but not all, blocks to be placed, got %d", placed)
+	} else if again := count(1); again != placed {
+		t.Errorf("expecting same seed to place the same number of blocks, got %d and %d", placed, again)
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	state, err := minecraft.ParseBlockState("minecraft:oak_stairs[facing=east,half=top,shape=outer_left,waterlogged=false]")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s := &Structure{
+		Size:     [3]int32{1, 1, 1},
+		Palettes: [][]minecraft.BlockState{{state}},
+		Blocks:   []Block{{}},
+	}
+
+	var first []byte
+
+	for n := 0; n < 10; n++ {
+		var buf bytes.Buffer
+
+		if err := s.Encode(&buf); err != nil {
+			t.Fatal(err.Error())
+		} else if first == nil {
+			first = buf.Bytes()
+		} else if !bytes.Equal(buf.Bytes(), first) {
+			t.Fatalf("encoding %d differs from the first", n+1)
+		}
+	}
+}
+
+func TestTransformState(t *testing.T) {
+	for n, test := range [...]struct {
+		state    string
+		mirror   Mirror
+		rotation Rotation
+		expected string
+	}{
+		{"oak_stairs[facing=north,shape=inner_left]", MirrorNone, RotateClockwise90, "minecraft:oak_stairs[facing=east,shape=inner_left]"},
+		{"oak_stairs[facing=north,shape=inner_left]", MirrorLeftRight, RotateNone, "minecraft:oak_stairs[facing=south,shape=inner_right]"},
+		{"oak_sign[rotation=1]", MirrorNone, RotateClockwise180, "minecraft:oak_sign[rotation=9]"},
+		{"oak_sign[rotation=1]", MirrorLeftRight, RotateNone, "minecraft:oak_sign[rotation=7]"},
+		{"oak_sign[rotation=1]", MirrorFrontBack, RotateNone, "minecraft:oak_sign[rotation=15]"},
+		{"oak_sign[rotation=0]", MirrorLeftRight, RotateNone, "minecraft:oak_sign[rotation=8]"},
+		{"rail[shape=north_east]", MirrorNone, RotateClockwise90, "minecraft:rail[shape=south_east]"},
+		{"rail[shape=ascending_west]", MirrorFrontBack, RotateNone, "minecraft:rail[shape=ascending_east]"},
+		{"oak_fence[east=true,north=false,south=false,west=false]", MirrorNone, RotateCounterclockwise90, "minecraft:oak_fence[east=false,north=true,south=false,west=false]"},
+		{"oak_door[facing=east,hinge=left]", MirrorFrontBack, RotateNone, "minecraft:oak_door[facing=west,hinge=right]"},
+		{"oak_log[axis=x]", MirrorNone, RotateCounterclockwise90, "minecraft:oak_log[axis=z]"},
+	} {
+		state, err := minecraft.ParseBlockState(test.state)
+		if err != nil {
+			t.Fatalf("test %d: %s", n+1, err)
+		}
+
+		if got := transformState(state, test.mirror, test.rotation).String(); got != test.expected {
+			t.Errorf("test %d: expecting %s, got %s", n+1, test.expected, got)
+		}
+	}
+}